@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/poixeai/proxify/infra/config"
+)
+
+// commonFlags are shared by commands that need the server configuration
+type commonFlags struct {
+	envFile    string
+	routesFile string
+	routesDir  string
+}
+
+func (f *commonFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.envFile, "config", ".env", "path to the .env file")
+	fs.StringVar(&f.routesFile, "routes", config.DefaultRoutesFile(), "path to the routes config file (.json, .yaml or .yml)")
+	fs.StringVar(&f.routesDir, "routes-dir", "", "directory of extra route files merged into the config (default: routes.d next to --routes)")
+}
+
+// routesDirPath returns the routes.d directory to use
+func (f *commonFlags) routesDirPath() string {
+	if f.routesDir != "" {
+		return f.routesDir
+	}
+	return config.RoutesDirFor(f.routesFile)
+}
+
+// loadEnv loads the .env file. A missing default file is not an error,
+// but an explicitly given file must exist.
+func (f *commonFlags) loadEnv(explicit bool) error {
+	if err := godotenv.Load(f.envFile); err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return nil
+		}
+		return fmt.Errorf("load %s: %w", f.envFile, err)
+	}
+	return nil
+}
+
+// envSnapshot captures the current process env
+func envSnapshot() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	return env
+}
+
+// newFlagSet creates a flag set that reports errors instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// isFlagSet reports whether the named flag was passed explicitly
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	return exitOK, true
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/poixeai/proxify/infra/history"
+	"github.com/poixeai/proxify/infra/watcher"
+)
+
+// openHistory loads .env (for ROUTES_HISTORY_*) and returns the history store
+func openHistory(flags *commonFlags, explicitEnv bool) (*history.Store, bool) {
+	if err := flags.loadEnv(explicitEnv); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return nil, false
+	}
+	store := history.Default()
+	if !store.Enabled() {
+		fmt.Fprint(os.Stderr, "error: routes history is disabled (ROUTES_HISTORY_LIMIT=0)\n")
+		return nil, false
+	}
+	return store, true
+}
+
+func getRevision(store *history.Store, value string) (*history.Revision, bool) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: invalid revision id %q\n", value)
+		return nil, false
+	}
+	rev, err := store.Get(id)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: revision %d: %v\n", id, err)
+		return nil, false
+	}
+	return rev, true
+}
+
+func cmdRoutesHistory(args []string) int {
+	var flags commonFlags
+	fs := newFlagSet("routes history")
+	flags.register(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	store, ok := openHistory(&flags, isFlagSet(fs, "config"))
+	if !ok {
+		return exitError
+	}
+
+	list, err := store.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitError
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tSOURCE\tROUTES")
+	for _, s := range list {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\n", s.ID, s.CreatedAt.Local().Format("2006-01-02 15:04:05"), s.Source, s.RouteCount)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+func cmdRoutesDiff(args []string) int {
+	var flags commonFlags
+	fs := newFlagSet("routes diff")
+	flags.register(fs)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: proxify routes diff [flags] <from-id> <to-id>\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	store, ok := openHistory(&flags, isFlagSet(fs, "config"))
+	if !ok {
+		return exitError
+	}
+	from, ok := getRevision(store, fs.Arg(0))
+	if !ok {
+		return exitError
+	}
+	to, ok := getRevision(store, fs.Arg(1))
+	if !ok {
+		return exitError
+	}
+
+	fmt.Print(history.Diff(from, to))
+	return exitOK
+}
+
+// cmdRoutesRollback writes a revision back to the routes file.
+// A running server picks the change up through its file watcher.
+func cmdRoutesRollback(args []string) int {
+	var flags commonFlags
+	fs := newFlagSet("routes rollback")
+	flags.register(fs)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: proxify routes rollback [flags] <id>\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	store, ok := openHistory(&flags, isFlagSet(fs, "config"))
+	if !ok {
+		return exitError
+	}
+	rev, ok := getRevision(store, fs.Arg(0))
+	if !ok {
+		return exitError
+	}
+
+	routes, err := rev.MainRoutes(flags.routesFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: rollback refused: %v\n", err)
+		return exitError
+	}
+
+	cfg, err := watcher.WriteRoutesFile(flags.routesFile, flags.routesDirPath(), routes)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: rollback rejected:\n%v\n", err)
+		return exitError
+	}
+
+	if _, err := store.Record(cfg, history.SourceRollback); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: failed to record history: %v\n", err)
+	}
+
+	fmt.Printf("rolled back %s to revision %d (%d routes)\n", flags.routesFile, rev.ID, len(cfg.Routes))
+	return exitOK
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/poixeai/proxify/infra/keys"
+)
+
+func cmdKeys(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, "usage: proxify keys <create|revoke|list> [flags]\n")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return cmdKeysCreate(args[1:])
+	case "revoke":
+		return cmdKeysRevoke(args[1:])
+	case "list":
+		return cmdKeysList(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown keys command %q\n", args[0])
+		return exitUsage
+	}
+}
+
+// keysFlags selects the key store, defaulting to AUTH_KEYS_FILE from .env
+type keysFlags struct {
+	commonFlags
+	keysFile string
+}
+
+func (f *keysFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.envFile, "config", ".env", "path to the .env file")
+	fs.StringVar(&f.keysFile, "keys-file", "", "path to the key store (default $AUTH_KEYS_FILE or keys.json)")
+}
+
+func (f *keysFlags) open(fs *flag.FlagSet) (*keys.Store, bool) {
+	if err := f.loadEnv(isFlagSet(fs, "config")); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return nil, false
+	}
+
+	path := f.keysFile
+	if path == "" {
+		path = keys.DefaultPath(os.Getenv)
+	}
+
+	store, err := keys.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: open key store: %v\n", err)
+		return nil, false
+	}
+	return store, true
+}
+
+func cmdKeysCreate(args []string) int {
+	var flags keysFlags
+	var name, allow, deny string
+	fs := newFlagSet("keys create")
+	flags.register(fs)
+	fs.StringVar(&name, "name", "", "human readable key name (required)")
+	fs.StringVar(&allow, "models-allow", "", "comma separated model globs the key may use (default: all)")
+	fs.StringVar(&deny, "models-deny", "", "comma separated model globs the key may not use")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if name == "" {
+		fmt.Fprint(os.Stderr, "error: --name is required\n")
+		return exitUsage
+	}
+
+	store, ok := flags.open(fs)
+	if !ok {
+		return exitError
+	}
+
+	policy := keys.ModelPolicy{
+		ModelsAllow: splitList(allow),
+		ModelsDeny:  splitList(deny),
+	}
+	if err := policy.Validate(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		return exitUsage
+	}
+
+	key, secret, err := store.Create(name, policy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: create key: %v\n", err)
+		return exitError
+	}
+
+	fmt.Printf("id:     %s\n", key.ID)
+	fmt.Printf("name:   %s\n", key.Name)
+	fmt.Printf("secret: %s\n", secret)
+	fmt.Fprint(os.Stderr, "store the secret now, it cannot be shown again\n")
+	return exitOK
+}
+
+func cmdKeysRevoke(args []string) int {
+	var flags keysFlags
+	fs := newFlagSet("keys revoke")
+	flags.register(fs)
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: proxify keys revoke [flags] <id>\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	store, ok := flags.open(fs)
+	if !ok {
+		return exitError
+	}
+
+	id := fs.Arg(0)
+	if err := store.Revoke(id); err != nil {
+		if errors.Is(err, keys.ErrNotFound) {
+			fmt.Fprintf(os.Stderr, "error: key %s not found\n", id)
+		} else {
+			fmt.Fprintf(os.Stderr, "error: revoke key: %v\n", err)
+		}
+		return exitError
+	}
+
+	fmt.Printf("revoked %s\n", id)
+	return exitOK
+}
+
+func cmdKeysList(args []string) int {
+	var flags keysFlags
+	fs := newFlagSet("keys list")
+	flags.register(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	store, ok := flags.open(fs)
+	if !ok {
+		return exitError
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tHINT\tCREATED\tSTATUS\tMODELS")
+	for _, k := range store.List() {
+		status := "active"
+		if !k.Active() {
+			status = "revoked " + k.RevokedAt.Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID, k.Name, k.Hint, k.CreatedAt.Format("2006-01-02 15:04"), status, modelPolicyString(k.ModelPolicy))
+	}
+	tw.Flush()
+	return exitOK
+}
+
+func modelPolicyString(p keys.ModelPolicy) string {
+	var parts []string
+	if len(p.ModelsAllow) > 0 {
+		parts = append(parts, "allow "+strings.Join(p.ModelsAllow, ","))
+	}
+	if len(p.ModelsDeny) > 0 {
+		parts = append(parts, "deny "+strings.Join(p.ModelsDeny, ","))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// splitList splits a comma separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
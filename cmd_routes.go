@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/util"
+)
+
+func cmdRoutes(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, "usage: proxify routes <list|test|history|diff|rollback> [flags]\n")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "list":
+		return cmdRoutesList(args[1:])
+	case "test":
+		return cmdRoutesTest(args[1:])
+	case "history":
+		return cmdRoutesHistory(args[1:])
+	case "diff":
+		return cmdRoutesDiff(args[1:])
+	case "rollback":
+		return cmdRoutesRollback(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown routes command %q\n", args[0])
+		return exitUsage
+	}
+}
+
+// loadRoutesForCLI loads and validates the routes config, printing errors to stderr
+func loadRoutesForCLI(flags *commonFlags) (*config.RoutesConfig, bool) {
+	cfg, err := config.LoadRoutes(flags.routesFile, flags.routesDirPath())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: routes config: %v\n", err)
+		return nil, false
+	}
+	if err := config.ValidateRoutes(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s:\n%v\n", flags.routesFile, err)
+		return nil, false
+	}
+	return cfg, true
+}
+
+func cmdRoutesList(args []string) int {
+	var flags commonFlags
+	var asJSON bool
+	fs := newFlagSet("routes list")
+	flags.register(fs)
+	fs.BoolVar(&asJSON, "json", false, "print routes as JSON")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	cfg, ok := loadRoutesForCLI(&flags)
+	if !ok {
+		return exitError
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cfg.PublicRoutes()); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tNAME\tTARGET\tTRANSFORM\tMODEL_MAP\tSOURCE")
+	for _, r := range cfg.PublicRoutes() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\n",
+			r.Path, dash(r.Name), r.Target, dash(r.Transform), len(r.ModelMap), r.Source)
+	}
+	tw.Flush()
+	return exitOK
+}
+
+// cmdRoutesTest resolves a request path (and optionally a model) the same way the proxy does
+func cmdRoutesTest(args []string) int {
+	var flags commonFlags
+	var model string
+	fs := newFlagSet("routes test")
+	flags.register(fs)
+	fs.StringVar(&model, "model", "", "model name to run through the route's model_map")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "usage: proxify routes test [flags] <request-path>\n")
+		fs.PrintDefaults()
+	}
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	// allow flags after the path: `routes test /openai/v1/models --model x`
+	rest := fs.Args()
+	if len(rest) > 1 {
+		if code, ok := parseFlags(fs, rest[1:]); !ok {
+			return code
+		}
+		rest = append(rest[:1], fs.Args()...)
+	}
+	if len(rest) != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	reqPath := rest[0]
+	if !strings.HasPrefix(reqPath, "/") {
+		reqPath = "/" + reqPath
+	}
+
+	cfg, ok := loadRoutesForCLI(&flags)
+	if !ok {
+		return exitError
+	}
+
+	top, sub := util.ExtractRoute(reqPath)
+
+	var route *config.Route
+	switch {
+	case top == config.UnifiedTopRoute && cfg.UnifiedEndpoint():
+		// unified endpoint, routed by model
+		if model == "" {
+			fmt.Fprintln(os.Stderr, "error: --model is required for the unified /v1 endpoint")
+			return exitUsage
+		}
+		var upstreamModel string
+		var ok bool
+		route, upstreamModel, ok = cfg.MatchModel(model)
+		if !ok {
+			fmt.Printf("no route serves model %s\n", model)
+			return exitError
+		}
+		if upstreamModel != model {
+			fmt.Printf("provider:  %s -> %s\n", model, upstreamModel)
+			model = upstreamModel
+		}
+		sub = "/" + config.UnifiedTopRoute + sub
+	case config.ReservedTopRoutes[top]:
+		fmt.Printf("%s is a system route, not proxied\n", reqPath)
+		return exitError
+	default:
+		for i := range cfg.Routes {
+			if cfg.Routes[i].Path == "/"+top {
+				route = &cfg.Routes[i]
+				break
+			}
+		}
+		if route == nil {
+			fmt.Printf("no route matches %s\n", reqPath)
+			return exitError
+		}
+	}
+
+	newSub, pathModel, pathRewritten := util.RewritePathModel(sub, route.MapModel)
+	sub = newSub
+
+	fmt.Printf("route:     %s (%s)\n", route.Path, dash(route.Name))
+	fmt.Printf("target:    %s\n", util.JoinURL(route.Public().Target, sub))
+	if route.Transform != "" {
+		fmt.Printf("transform: %s\n", route.Transform)
+	}
+	if pathRewritten {
+		mapped, _ := route.MapModel(pathModel)
+		fmt.Printf("model:     %s -> %s (path)\n", pathModel, mapped)
+	}
+
+	if model != "" {
+		body, _ := json.Marshal(map[string]string{"model": model})
+		newBody, rewritten, err := util.RewriteChatCompletionModelFunc(body, route.MapModel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			return exitError
+		}
+		if rewritten {
+			var out map[string]string
+			json.Unmarshal(newBody, &out)
+			fmt.Printf("model:     %s -> %s\n", model, out["model"])
+		} else {
+			fmt.Printf("model:     %s (unchanged)\n", model)
+		}
+	}
+
+	return exitOK
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/graceful"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/watcher"
+	"github.com/poixeai/proxify/router"
+	"github.com/poixeai/proxify/util"
+)
+
+func cmdServe(args []string) int {
+	var flags commonFlags
+	fs := newFlagSet("serve")
+	flags.register(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	// load .env, remembering the real process env for settings reloads
+	baseEnv := envSnapshot()
+	envErr := flags.loadEnv(isFlagSet(fs, "config"))
+
+	// init logger
+	logger.InitLogger()
+
+	if envErr != nil {
+		logger.Errorf("Env config error: %v", envErr)
+		return exitError
+	}
+
+	// check .env, reloaded later on SIGHUP or file change
+	if err := watcher.InitSettingsWatcher(flags.envFile, baseEnv); err != nil {
+		logger.Errorf("Settings error: %v, refused to start", err)
+		return exitError
+	}
+
+	authCfg := watcher.GetSettings().Auth
+	if authCfg.TokenAuthEnabled() {
+		logger.Infof("Token auth enabled, header=%s", authCfg.TokenHeader)
+	}
+	if len(authCfg.IPNets) > 0 {
+		logger.Infof("IP whitelist enabled, rules=%d", len(authCfg.IPNets))
+	}
+
+	// init routes watcher
+	if err := watcher.InitRoutesWatcher(flags.routesFile, flags.routesDirPath()); err != nil {
+		logger.Errorf("Failed to load routes config: %v", err)
+		return exitError
+	}
+
+	// init gin
+	r := gin.New()
+	r.SetTrustedProxies(nil)
+
+	// setup routes
+	router.SetRoutes(r)
+
+	// setup frontend static files
+	MountFrontend(r)
+
+	// start server, reusing the parent's socket after a SIGUSR2 handoff
+	port := util.GetEnvPort()
+	ln, err := graceful.Listen(":" + port)
+	if err != nil {
+		logger.Errorf("Failed to listen on port %s: %v", port, err)
+		return exitError
+	}
+
+	logger.Infof("Server running on port %s", port)
+
+	srv := &http.Server{Handler: r}
+	if err := graceful.Serve(srv, ln); err != nil {
+		logger.Errorf("Server stopped with error: %v", err)
+		return exitError
+	}
+
+	return exitOK
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/poixeai/proxify/infra/config"
+)
+
+// cmdValidate checks .env and the routes config without starting the server
+func cmdValidate(args []string) int {
+	var flags commonFlags
+	fs := newFlagSet("validate")
+	flags.register(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	failed := false
+	fail := func(format string, a ...interface{}) {
+		failed = true
+		fmt.Fprintf(os.Stderr, "error: "+format+"\n", a...)
+	}
+
+	// 1. env + auth
+	if err := flags.loadEnv(isFlagSet(fs, "config")); err != nil {
+		fail("%v", err)
+	}
+
+	if _, err := config.LoadSettings(); err != nil {
+		fail("settings: %v", err)
+	}
+
+	// 2. routes
+	cfg, err := config.LoadRoutes(flags.routesFile, flags.routesDirPath())
+	if err != nil {
+		fail("routes config: %v", err)
+	} else if err := config.ValidateRoutes(cfg); err != nil {
+		fail("%s:\n%v", flags.routesFile, err)
+	}
+
+	if failed {
+		return exitError
+	}
+
+	fmt.Printf("OK: %s is valid (%d routes)\n", flags.routesFile, len(cfg.Routes))
+	return exitOK
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/history"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+	"github.com/poixeai/proxify/infra/watcher"
+)
+
+// AdminListHistoryHandler GET /api/admin/history
+func AdminListHistoryHandler(c *gin.Context) {
+	list, err := watcher.History().List()
+	if err != nil {
+		logger.Errorf("[admin] failed to list history: %v", err)
+		response.RespondInternalError(c)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": list})
+}
+
+// AdminGetRevisionHandler GET /api/admin/history/:id
+func AdminGetRevisionHandler(c *gin.Context) {
+	rev, ok := revisionParam(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rev})
+}
+
+// AdminDiffHistoryHandler GET /api/admin/history/diff?from=1&to=2
+// to defaults to the latest revision
+func AdminDiffHistoryHandler(c *gin.Context) {
+	from, ok := revisionParam(c, c.Query("from"))
+	if !ok {
+		return
+	}
+
+	toID := c.Query("to")
+	if toID == "" {
+		list, err := watcher.History().List()
+		if err != nil || len(list) == 0 {
+			respondRevisionNotFound(c, "latest")
+			return
+		}
+		toID = strconv.Itoa(list[0].ID)
+	}
+	to, ok := revisionParam(c, toID)
+	if !ok {
+		return
+	}
+
+	c.String(http.StatusOK, history.Diff(from, to))
+}
+
+// AdminRollbackHandler POST /api/admin/history/:id/rollback
+func AdminRollbackHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.RespondBadRequestError(c)
+		return
+	}
+
+	cfg, err := watcher.Rollback(id)
+	if err != nil {
+		if errors.Is(err, history.ErrNotFound) {
+			respondRevisionNotFound(c, c.Param("id"))
+			return
+		}
+		respondAdminError(c, err)
+		return
+	}
+
+	logger.Infof("[admin] routes rolled back to revision %d", id)
+	c.JSON(http.StatusOK, gin.H{"data": cfg.PublicRoutes()})
+}
+
+func revisionParam(c *gin.Context, value string) (*history.Revision, bool) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		response.RespondError(c, http.StatusBadRequest,
+			fmt.Sprintf("Invalid revision id %q.", value), response.INVALID_REQUEST_ERROR)
+		return nil, false
+	}
+
+	rev, err := watcher.History().Get(id)
+	if err != nil {
+		if errors.Is(err, history.ErrNotFound) {
+			respondRevisionNotFound(c, value)
+		} else {
+			logger.Errorf("[admin] failed to read revision %d: %v", id, err)
+			response.RespondInternalError(c)
+		}
+		return nil, false
+	}
+	return rev, true
+}
+
+func respondRevisionNotFound(c *gin.Context, id string) {
+	response.RespondError(c, http.StatusNotFound,
+		fmt.Sprintf("Revision %s not found.", id), response.NOT_FOUND_ERROR)
+}
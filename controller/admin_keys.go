@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/keys"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+	"github.com/poixeai/proxify/infra/watcher"
+)
+
+// keyView is a key as returned by the admin API, without the hash of the secret
+type keyView struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hint      string     `json:"hint"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	keys.ModelPolicy
+}
+
+func newKeyView(k *keys.Key) keyView {
+	return keyView{
+		ID:          k.ID,
+		Name:        k.Name,
+		Hint:        k.Hint,
+		CreatedAt:   k.CreatedAt,
+		RevokedAt:   k.RevokedAt,
+		ModelPolicy: k.ModelPolicy,
+	}
+}
+
+// createdKey is returned once when a key is created or rotated
+type createdKey struct {
+	keyView
+	Secret string `json:"secret"`
+}
+
+// AdminListKeysHandler GET /api/admin/keys
+func AdminListKeysHandler(c *gin.Context) {
+	store, ok := keyStore(c)
+	if !ok {
+		return
+	}
+	list := store.List()
+	views := make([]keyView, 0, len(list))
+	for i := range list {
+		views = append(views, newKeyView(&list[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": views})
+}
+
+// AdminCreateKeyHandler POST /api/admin/keys
+func AdminCreateKeyHandler(c *gin.Context) {
+	store, ok := keyStore(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+		keys.ModelPolicy
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			response.RespondError(c, http.StatusBadRequest, "Invalid request body: "+err.Error(), response.INVALID_REQUEST_ERROR)
+			return
+		}
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		response.RespondError(c, http.StatusBadRequest, "The key `name` is required.", response.INVALID_REQUEST_ERROR)
+		return
+	}
+	if err := req.ModelPolicy.Validate(); err != nil {
+		response.RespondError(c, http.StatusBadRequest, err.Error(), response.INVALID_REQUEST_ERROR)
+		return
+	}
+
+	key, secret, err := store.Create(req.Name, req.ModelPolicy)
+	if err != nil {
+		respondKeyError(c, err)
+		return
+	}
+
+	logger.Infof("[admin] key %s created", key.ID)
+	c.JSON(http.StatusCreated, gin.H{"data": createdKey{keyView: newKeyView(key), Secret: secret}})
+}
+
+// AdminRevokeKeyHandler DELETE /api/admin/keys/:id
+func AdminRevokeKeyHandler(c *gin.Context) {
+	store, ok := keyStore(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	if err := store.Revoke(id); err != nil {
+		respondKeyError(c, err)
+		return
+	}
+
+	logger.Infof("[admin] key %s revoked", id)
+	c.Status(http.StatusNoContent)
+}
+
+// AdminRotateKeyHandler POST /api/admin/keys/:id/rotate
+func AdminRotateKeyHandler(c *gin.Context) {
+	store, ok := keyStore(c)
+	if !ok {
+		return
+	}
+
+	id := c.Param("id")
+	key, secret, err := store.Rotate(id)
+	if err != nil {
+		respondKeyError(c, err)
+		return
+	}
+
+	logger.Infof("[admin] key %s rotated to %s", id, key.ID)
+	c.JSON(http.StatusOK, gin.H{"data": createdKey{keyView: newKeyView(key), Secret: secret}})
+}
+
+func keyStore(c *gin.Context) (*keys.Store, bool) {
+	store := watcher.GetSettings().Auth.Keys
+	if store == nil {
+		response.RespondError(c, http.StatusServiceUnavailable, "Key store is not available.", response.SERVICE_UNAVAILABLE)
+		return nil, false
+	}
+	return store, true
+}
+
+func respondKeyError(c *gin.Context, err error) {
+	if errors.Is(err, keys.ErrNotFound) {
+		response.RespondError(c, http.StatusNotFound, "Key not found.", response.NOT_FOUND_ERROR)
+		return
+	}
+	logger.Errorf("[admin] key store error: %v", err)
+	response.RespondError(c, http.StatusInternalServerError, "Key store error.", response.INTERNAL_ERROR)
+}
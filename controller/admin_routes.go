@@ -0,0 +1,209 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/history"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+	"github.com/poixeai/proxify/infra/watcher"
+)
+
+var (
+	errRouteNotFound = errors.New("route not found")
+	errRouteExists   = errors.New("route already exists")
+	errRouteReadOnly = errors.New("route is defined outside the main routes file and cannot be changed through the API")
+)
+
+// adminRoute is a route as shown by the admin API
+type adminRoute struct {
+	config.Route
+	Source   string `json:"source"`
+	Editable bool   `json:"editable"`
+}
+
+func toAdminRoute(r config.Route) adminRoute {
+	file := watcher.RoutesFile()
+	return adminRoute{
+		Route:    r.Public(),
+		Source:   r.Source,
+		Editable: r.Source == file || r.Source == "",
+	}
+}
+
+// AdminListRoutesHandler GET /api/admin/routes
+func AdminListRoutesHandler(c *gin.Context) {
+	cfg := watcher.GetRoutes()
+	data := make([]adminRoute, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		data = append(data, toAdminRoute(r))
+	}
+	c.JSON(http.StatusOK, gin.H{"data": data})
+}
+
+// AdminGetRouteHandler GET /api/admin/routes/:name
+func AdminGetRouteHandler(c *gin.Context) {
+	path := routePathParam(c)
+	for _, r := range watcher.GetRoutes().Routes {
+		if r.Path == path {
+			c.JSON(http.StatusOK, gin.H{"data": toAdminRoute(r)})
+			return
+		}
+	}
+	respondAdminError(c, errRouteNotFound)
+}
+
+// AdminCreateRouteHandler POST /api/admin/routes
+func AdminCreateRouteHandler(c *gin.Context) {
+	route, ok := bindRoute(c)
+	if !ok {
+		return
+	}
+	if err := config.CheckSubmittedReferences(route, nil); err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	_, err := watcher.UpdateRoutesFile(history.SourceAdmin, func(routes []config.Route) ([]config.Route, error) {
+		for _, r := range watcher.GetRoutes().Routes {
+			if r.Path == route.Path {
+				return nil, errRouteExists
+			}
+		}
+		return append(routes, route), nil
+	})
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	logger.Infof("[admin] route %s created", route.Path)
+	c.JSON(http.StatusCreated, gin.H{"data": route})
+}
+
+// AdminUpdateRouteHandler PUT /api/admin/routes/:name
+func AdminUpdateRouteHandler(c *gin.Context) {
+	path := routePathParam(c)
+	route, ok := bindRoute(c)
+	if !ok {
+		return
+	}
+	if route.Path == "" {
+		route.Path = path
+	}
+
+	err := updateRoute(path, func(routes []config.Route, i int) ([]config.Route, error) {
+		if err := config.CheckSubmittedReferences(route, &routes[i]); err != nil {
+			return nil, err
+		}
+		routes[i] = route
+		return routes, nil
+	})
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	logger.Infof("[admin] route %s updated", path)
+	c.JSON(http.StatusOK, gin.H{"data": route})
+}
+
+// AdminDeleteRouteHandler DELETE /api/admin/routes/:name
+func AdminDeleteRouteHandler(c *gin.Context) {
+	path := routePathParam(c)
+
+	err := updateRoute(path, func(routes []config.Route, i int) ([]config.Route, error) {
+		return append(routes[:i], routes[i+1:]...), nil
+	})
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	logger.Infof("[admin] route %s deleted", path)
+	c.Status(http.StatusNoContent)
+}
+
+// AdminEnableRouteHandler POST /api/admin/routes/:name/enable
+func AdminEnableRouteHandler(c *gin.Context) {
+	setRouteDisabled(c, false)
+}
+
+// AdminDisableRouteHandler POST /api/admin/routes/:name/disable
+func AdminDisableRouteHandler(c *gin.Context) {
+	setRouteDisabled(c, true)
+}
+
+func setRouteDisabled(c *gin.Context, disabled bool) {
+	path := routePathParam(c)
+
+	var updated config.Route
+	err := updateRoute(path, func(routes []config.Route, i int) ([]config.Route, error) {
+		routes[i].Disabled = disabled
+		updated = routes[i]
+		return routes, nil
+	})
+	if err != nil {
+		respondAdminError(c, err)
+		return
+	}
+
+	logger.Infof("[admin] route %s disabled=%v", path, disabled)
+	c.JSON(http.StatusOK, gin.H{"data": updated})
+}
+
+// updateRoute runs fn on the route with the given path in the main routes file
+func updateRoute(path string, fn func(routes []config.Route, i int) ([]config.Route, error)) error {
+	_, err := watcher.UpdateRoutesFile(history.SourceAdmin, func(routes []config.Route) ([]config.Route, error) {
+		for i := range routes {
+			if routes[i].Path == path {
+				return fn(routes, i)
+			}
+		}
+
+		// exists, but in routes.d
+		for _, r := range watcher.GetRoutes().Routes {
+			if r.Path == path {
+				return nil, errRouteReadOnly
+			}
+		}
+		return nil, errRouteNotFound
+	})
+	return err
+}
+
+// routePathParam turns the :name URL parameter into a route path, "openai" -> "/openai"
+func routePathParam(c *gin.Context) string {
+	return "/" + c.Param("name")
+}
+
+// bindRoute decodes a route from the request body, rejecting unknown fields
+func bindRoute(c *gin.Context) (config.Route, bool) {
+	var route config.Route
+	dec := json.NewDecoder(c.Request.Body)
+	dec.DisallowUnknownFields()
+	dec.UseNumber()
+	if err := dec.Decode(&route); err != nil {
+		response.RespondError(c, http.StatusBadRequest,
+			fmt.Sprintf("Invalid route JSON: %v", err), response.INVALID_REQUEST_ERROR)
+		return route, false
+	}
+	return route, true
+}
+
+func respondAdminError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errRouteNotFound):
+		response.RespondError(c, http.StatusNotFound, err.Error(), response.NOT_FOUND_ERROR)
+	case errors.Is(err, errRouteExists), errors.Is(err, errRouteReadOnly):
+		response.RespondError(c, http.StatusConflict, err.Error(), response.CONFLICT_ERROR)
+	default:
+		// validation and interpolation errors
+		response.RespondError(c, http.StatusBadRequest, err.Error(), response.INVALID_REQUEST_ERROR)
+	}
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/watcher"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.ZapLog = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
+
+func adminRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/routes/:name", AdminGetRouteHandler)
+	r.POST("/routes", AdminCreateRouteHandler)
+	r.PUT("/routes/:name", AdminUpdateRouteHandler)
+	r.DELETE("/routes/:name", AdminDeleteRouteHandler)
+	r.POST("/routes/:name/enable", AdminEnableRouteHandler)
+	r.POST("/routes/:name/disable", AdminDisableRouteHandler)
+	return r
+}
+
+func TestAdminRoutes(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ROUTES_HISTORY_DIR", filepath.Join(dir, "history"))
+	file := filepath.Join(dir, "routes.json")
+	routesDir := filepath.Join(dir, "routes.d")
+	os.WriteFile(file, []byte(`{"routes":[{"path":"/openai","target":"https://api.openai.com"}]}`), 0644)
+	os.Mkdir(routesDir, 0755)
+	os.WriteFile(filepath.Join(routesDir, "claude.json"),
+		[]byte(`{"routes":[{"path":"/claude","target":"https://api.anthropic.com"}]}`), 0644)
+	if err := watcher.InitRoutesWatcher(file, routesDir); err != nil {
+		t.Fatal(err)
+	}
+
+	r := adminRouter()
+	steps := []struct {
+		name, method, path, body string
+		status                   int
+	}{
+		{"create", http.MethodPost, "/routes", `{"path":"/groq","target":"https://api.groq.com/openai"}`, http.StatusCreated},
+		{"create existing", http.MethodPost, "/routes", `{"path":"/groq","target":"https://api.groq.com"}`, http.StatusConflict},
+		{"create in routes.d", http.MethodPost, "/routes", `{"path":"/claude","target":"https://x.example.com"}`, http.StatusConflict},
+		{"create reserved", http.MethodPost, "/routes", `{"path":"/api","target":"https://x.example.com"}`, http.StatusBadRequest},
+		{"unknown field", http.MethodPost, "/routes", `{"path":"/x","target":"https://x.example.com","tagret":""}`, http.StatusBadRequest},
+		{"get", http.MethodGet, "/routes/groq", "", http.StatusOK},
+		{"update", http.MethodPut, "/routes/groq", `{"target":"https://api.groq.com/openai","models":["llama-*"]}`, http.StatusOK},
+		{"disable", http.MethodPost, "/routes/openai/disable", "", http.StatusOK},
+		{"read-only", http.MethodPost, "/routes/claude/disable", "", http.StatusConflict},
+		{"delete", http.MethodDelete, "/routes/groq", "", http.StatusNoContent},
+		{"get deleted", http.MethodGet, "/routes/groq", "", http.StatusNotFound},
+		{"delete missing", http.MethodDelete, "/routes/groq", "", http.StatusNotFound},
+	}
+	for _, s := range steps {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(s.method, s.path, strings.NewReader(s.body)))
+		if rec.Code != s.status {
+			t.Errorf("%s: status = %d, want %d: %s", s.name, rec.Code, s.status, rec.Body)
+		}
+	}
+
+	// changes are written to the routes file and applied without a restart
+	saved, err := config.LoadRoutesConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Routes) != 1 || saved.Routes[0].Path != "/openai" || !saved.Routes[0].Disabled {
+		t.Errorf("routes file = %+v", saved.Routes)
+	}
+	var live []string
+	for _, route := range watcher.GetRoutes().Routes {
+		live = append(live, route.Path)
+	}
+	if strings.Join(live, " ") != "/openai /claude" || !watcher.GetRoutes().Routes[0].Disabled {
+		t.Errorf("live routes = %v", live)
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/keys"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/models"
+	"github.com/poixeai/proxify/infra/watcher"
+)
+
+// ModelsHandler GET /v1/models, the models of all routes in OpenAI format
+func ModelsHandler(c *gin.Context) {
+	// a route with path /v1 replaces the unified endpoint
+	if c.GetBool(ctx.Proxified) {
+		ProxyHandler(c)
+		return
+	}
+
+	settings := watcher.GetSettings()
+	list, unlisted := models.List(c.Request.Context(), watcher.GetRoutes(), settings.ModelsCacheTTL, clientKey(c, settings.Auth))
+	if len(unlisted) > 0 {
+		logger.Warnf("[models] listing without the upstream models of %s", strings.Join(unlisted, ", "))
+	}
+
+	// only show what the API key may use
+	if v, ok := c.Get(ctx.APIKey); ok {
+		key := v.(*keys.Key)
+		filtered := list[:0]
+		for _, m := range list {
+			if key.ModelAllowed(strings.TrimPrefix(m.ID, m.OwnedBy+"/")) {
+				filtered = append(filtered, m)
+			}
+		}
+		list = filtered
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   list,
+	})
+}
+
+// clientKey returns the upstream API key sent by the client, as a bearer token,
+// x-api-key or x-goog-api-key. The header holding the proxy token is skipped.
+func clientKey(c *gin.Context, auth *config.AuthConfig) string {
+	for _, h := range []string{"Authorization", "x-api-key", "x-goog-api-key"} {
+		if auth.TokenAuthEnabled() && strings.EqualFold(h, auth.TokenHeader) {
+			continue
+		}
+		if v := strings.TrimSpace(c.GetHeader(h)); v != "" {
+			return strings.TrimSpace(strings.TrimPrefix(v, "Bearer "))
+		}
+	}
+	return ""
+}
@@ -1,9 +1,9 @@
 package controller
 
 import (
+	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +11,7 @@ import (
 	"github.com/poixeai/proxify/infra/logger"
 	"github.com/poixeai/proxify/infra/response"
 	"github.com/poixeai/proxify/infra/stream"
+	"github.com/poixeai/proxify/infra/watcher"
 	"github.com/poixeai/proxify/util"
 )
 
@@ -19,22 +20,54 @@ func ProxyHandler(c *gin.Context) {
 	targetEndpoint := c.GetString(ctx.TargetEndpoint)
 	subPath := c.GetString(ctx.SubPath)
 	targetURL := util.JoinURL(targetEndpoint, subPath)
+	route := ctx.GetRoute(c)
+	requestedModel := c.GetString(ctx.RequestedModel)
+
+	// disabled routes stay in the config but are not proxied
+	if route != nil && route.Disabled {
+		response.RespondError(c, http.StatusServiceUnavailable,
+			fmt.Sprintf("The route [%s] is currently disabled.", route.Path),
+			response.SERVICE_UNAVAILABLE)
+		return
+	}
+
 	c.Set(ctx.TargetURL, targetURL)
 
+	// the target as configured, interpolated secrets must stay out of logs
+	logURL := targetURL
+	if route != nil {
+		logURL = util.JoinURL(route.Public().Target, subPath)
+	}
+
 	// construct new request
 	ctx := c.Request.Context()
 	req, err := http.NewRequestWithContext(ctx, c.Request.Method, targetURL, c.Request.Body)
 	if err != nil {
-		logger.Errorf("Failed to create new request: %v", err)
+		logger.Errorf("Failed to create new request to %s: %s", logURL, util.RedactURLError(err, targetURL))
 		response.RespondInternalError(c)
 		return
 	}
+	// keep the length of rewritten bodies, otherwise the body is sent chunked
+	req.ContentLength = c.Request.ContentLength
 
 	// copy headers
 	for k, v := range c.Request.Header {
 		req.Header[k] = v
 	}
 
+	// route-level upstream headers override client headers
+	if route != nil {
+		for k, v := range route.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+
+	// the response body is rewritten, so it must not be compressed
+	restoreModel := route != nil && route.RestoreModel && requestedModel != ""
+	if restoreModel {
+		req.Header.Del("Accept-Encoding")
+	}
+
 	// create client
 	client := &http.Client{
 		Timeout: 0, // no timeout, let ctx control it
@@ -48,7 +81,7 @@ func ProxyHandler(c *gin.Context) {
 	// do request
 	resp, err := client.Do(req)
 	if err != nil {
-		logger.Errorf("Failed to do request to target: %v", err)
+		logger.Errorf("Failed to do request to %s: %s", logURL, util.RedactURLError(err, targetURL))
 		response.RespondInternalError(c)
 		return
 	}
@@ -59,13 +92,19 @@ func ProxyHandler(c *gin.Context) {
 		c.Writer.Header()[k] = v
 	}
 
+	// report the model the client asked for
+	if restoreModel {
+		resp.Body = stream.RestoreModel(resp.Body, requestedModel)
+		c.Writer.Header().Del("Content-Length")
+	}
+
 	// set status code
 	c.Status(resp.StatusCode)
 
 	// determine if response is a stream
 	if isStreamResponse(resp) {
 		// stream copy with optional smoothing
-		if os.Getenv("STREAM_SMOOTHING_ENABLED") == "true" {
+		if watcher.GetSettings().StreamSmoothing {
 			stream.Smoothing(c, resp)
 		} else {
 			streamCopy(c, resp)
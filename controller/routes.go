@@ -10,6 +10,6 @@ import (
 func RoutesHandler(c *gin.Context) {
 	cfg := watcher.GetRoutes()
 	c.JSON(http.StatusOK, gin.H{
-		"data": cfg.Routes,
+		"data": cfg.PublicRoutes(), // never expose interpolated secrets
 	})
 }
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+)
+
+// AdminRole is the access level of an admin credential, higher roles include lower ones
+type AdminRole int
+
+const (
+	RoleViewer   AdminRole = iota + 1 // read routes, history
+	RoleOperator                      // toggle routes, manage API keys
+	RoleAdmin                         // full config changes
+)
+
+var adminRoleNames = map[AdminRole]string{
+	RoleViewer:   "viewer",
+	RoleOperator: "operator",
+	RoleAdmin:    "admin",
+}
+
+func (r AdminRole) String() string {
+	if name, ok := adminRoleNames[r]; ok {
+		return name
+	}
+	return "none"
+}
+
+func ParseAdminRole(s string) (AdminRole, error) {
+	for role, name := range adminRoleNames {
+		if strings.EqualFold(s, name) {
+			return role, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown admin role %q (viewer, operator, admin)", s)
+}
+
+type AdminUser struct {
+	Name  string
+	Role  AdminRole
+	Token string
+}
+
+// AdminConfig holds the credentials for management endpoints under /api,
+// separate from the proxy token
+type AdminConfig struct {
+	TokenHeader string
+	Users       []AdminUser
+}
+
+const defaultAdminTokenHeader = "X-Admin-Token"
+
+// LoadAdminConfigFrom parses ADMIN_TOKENS ("name:role:token,...") and ADMIN_TOKEN_HEADER
+func LoadAdminConfigFrom(getenv func(string) string) (*AdminConfig, error) {
+	cfg := &AdminConfig{
+		TokenHeader: strings.TrimSpace(getenv("ADMIN_TOKEN_HEADER")),
+	}
+	if cfg.TokenHeader == "" {
+		cfg.TokenHeader = defaultAdminTokenHeader
+	}
+
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(getenv("ADMIN_TOKENS"), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, ":", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid ADMIN_TOKENS entry, expected name:role:token")
+		}
+		name, roleStr, token := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]), strings.TrimSpace(parts[2])
+
+		role, err := ParseAdminRole(roleStr)
+		if err != nil {
+			return nil, fmt.Errorf("ADMIN_TOKENS entry %q: %w", name, err)
+		}
+		if name == "" || seen[name] {
+			return nil, fmt.Errorf("ADMIN_TOKENS: empty or duplicate name %q", name)
+		}
+		if len(token) < 16 {
+			return nil, fmt.Errorf("ADMIN_TOKENS entry %q: token is too short (<16)", name)
+		}
+		seen[name] = true
+
+		cfg.Users = append(cfg.Users, AdminUser{Name: name, Role: role, Token: token})
+	}
+
+	return cfg, nil
+}
+
+// Enabled reports whether any admin credential is configured
+func (cfg *AdminConfig) Enabled() bool {
+	return len(cfg.Users) > 0
+}
+
+// Lookup returns the admin user owning token
+func (cfg *AdminConfig) Lookup(token string) (*AdminUser, bool) {
+	token = strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if token == "" {
+		return nil, false
+	}
+	for i := range cfg.Users {
+		if subtle.ConstantTimeCompare([]byte(cfg.Users[i].Token), []byte(token)) == 1 {
+			return &cfg.Users[i], true
+		}
+	}
+	return nil, false
+}
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"net"
 	"os"
 	"strings"
+
+	"github.com/poixeai/proxify/infra/keys"
 )
 
 type AuthConfig struct {
@@ -12,13 +15,26 @@ type AuthConfig struct {
 
 	TokenHeader string
 	TokenKey    string
+
+	// API keys managed by `proxify keys`, accepted in TokenHeader as well
+	Keys *keys.Store
+}
+
+// TokenAuthEnabled reports whether requests must carry a valid token
+func (cfg *AuthConfig) TokenAuthEnabled() bool {
+	return cfg.TokenKey != "" || (cfg.Keys != nil && cfg.Keys.HasActive())
 }
 
 func LoadAuthConfig() (*AuthConfig, error) {
+	return LoadAuthConfigFrom(os.Getenv)
+}
+
+// LoadAuthConfigFrom loads the auth config using getenv to look up variables
+func LoadAuthConfigFrom(getenv func(string) string) (*AuthConfig, error) {
 	cfg := &AuthConfig{
-		IPWhitelistRaw: strings.TrimSpace(os.Getenv("AUTH_IP_WHITELIST")),
-		TokenHeader:    strings.TrimSpace(os.Getenv("AUTH_TOKEN_HEADER")),
-		TokenKey:       strings.TrimSpace(os.Getenv("AUTH_TOKEN_KEY")),
+		IPWhitelistRaw: strings.TrimSpace(getenv("AUTH_IP_WHITELIST")),
+		TokenHeader:    strings.TrimSpace(getenv("AUTH_TOKEN_HEADER")),
+		TokenKey:       strings.TrimSpace(getenv("AUTH_TOKEN_KEY")),
 	}
 
 	// parse ip whitelist
@@ -43,5 +59,25 @@ func LoadAuthConfig() (*AuthConfig, error) {
 		}
 	}
 
+	// load key store
+	store, err := keys.Open(keys.DefaultPath(getenv))
+	if err != nil {
+		return nil, err
+	}
+	cfg.Keys = store
+
 	return cfg, nil
 }
+
+// Validate checks the auth config for settings that must not be served
+func (cfg *AuthConfig) Validate() error {
+	if cfg.TokenAuthEnabled() {
+		if cfg.TokenKey != "" && len(cfg.TokenKey) < 16 {
+			return errors.New("AUTH_TOKEN_KEY is too short (<16)")
+		}
+		if cfg.TokenHeader == "" {
+			return errors.New("AUTH_TOKEN_HEADER is required when token auth enabled")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAuthConfigKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	os.WriteFile(path, []byte(`{"keys":[{"id":"key_1","name":"ci","hash":"x"}]}`), 0600)
+
+	// the key file comes from getenv, not from the process environment
+	t.Setenv("AUTH_KEYS_FILE", filepath.Join(t.TempDir(), "other.json"))
+	getenv := func(k string) string {
+		if k == "AUTH_KEYS_FILE" {
+			return path
+		}
+		return ""
+	}
+
+	cfg, err := LoadAuthConfigFrom(getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Keys.HasActive() || !cfg.TokenAuthEnabled() {
+		t.Error("keys of AUTH_KEYS_FILE from getenv not loaded")
+	}
+}
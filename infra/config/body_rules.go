@@ -0,0 +1,162 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// supported values of BodyRule.Op
+const (
+	BodyRuleSetIfAbsent = "set_if_absent" // set field when the client did not send it
+	BodyRuleOverride    = "override"      // always set field
+	BodyRuleClamp       = "clamp"         // limit a numeric field to [min, max]
+	BodyRuleDelete      = "delete"        // remove field
+)
+
+// BodyRule changes a field of JSON request bodies, e.g.
+//
+//	{"op": "clamp", "field": "max_tokens", "max": 4096}
+//	{"op": "set_if_absent", "field": "metadata.team", "value": "search"}
+//
+// Field is a dot separated path into the body, like "generationConfig.temperature".
+type BodyRule struct {
+	Op    string      `json:"op"`
+	Field string      `json:"field"`
+	Value interface{} `json:"value,omitempty"`
+	Min   *float64    `json:"min,omitempty"`
+	Max   *float64    `json:"max,omitempty"`
+}
+
+func (r *BodyRule) validate() error {
+	if strings.TrimSpace(r.Field) == "" {
+		return errors.New("field is required")
+	}
+	for _, part := range strings.Split(r.Field, ".") {
+		if part == "" {
+			return fmt.Errorf("invalid field %q", r.Field)
+		}
+	}
+
+	switch r.Op {
+	case BodyRuleSetIfAbsent, BodyRuleOverride:
+		if r.Value == nil {
+			return fmt.Errorf("%s on %q requires a value", r.Op, r.Field)
+		}
+	case BodyRuleClamp:
+		if r.Min == nil && r.Max == nil {
+			return fmt.Errorf("clamp on %q requires min or max", r.Field)
+		}
+		if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+			return fmt.Errorf("clamp on %q has min > max", r.Field)
+		}
+	case BodyRuleDelete:
+	default:
+		return fmt.Errorf("unknown op %q", r.Op)
+	}
+	return nil
+}
+
+// ApplyBodyRules applies rules in order to a decoded JSON object and reports whether it
+// changed. skipped names the fields of clamp rules that were not applied because the
+// field holds something other than a number.
+func ApplyBodyRules(body map[string]interface{}, rules []BodyRule) (changed bool, skipped []string) {
+	for i := range rules {
+		ok, skip := rules[i].apply(body)
+		if ok {
+			changed = true
+		}
+		if skip {
+			skipped = append(skipped, rules[i].Field)
+		}
+	}
+	return changed, skipped
+}
+
+// apply reports whether body changed, and whether a clamp was skipped on a non-number
+func (r *BodyRule) apply(body map[string]interface{}) (bool, bool) {
+	parts := strings.Split(r.Field, ".")
+	name := parts[len(parts)-1]
+	create := r.Op == BodyRuleSetIfAbsent || r.Op == BodyRuleOverride
+
+	// walk to the object holding the field
+	obj := body
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := obj[part].(map[string]interface{})
+		if !ok {
+			if !create || obj[part] != nil {
+				return false, false // missing, or not an object
+			}
+			next = make(map[string]interface{})
+			obj[part] = next
+		}
+		obj = next
+	}
+
+	current, exists := obj[name]
+
+	switch r.Op {
+	case BodyRuleSetIfAbsent:
+		if exists {
+			return false, false
+		}
+		obj[name] = copyValue(r.Value)
+	case BodyRuleOverride:
+		obj[name] = copyValue(r.Value)
+	case BodyRuleDelete:
+		if !exists {
+			return false, false
+		}
+		delete(obj, name)
+	case BodyRuleClamp:
+		v, ok := toFloat(current)
+		if !ok {
+			return false, exists && current != nil
+		}
+		clamped := v
+		if r.Min != nil && clamped < *r.Min {
+			clamped = *r.Min
+		}
+		if r.Max != nil && clamped > *r.Max {
+			clamped = *r.Max
+		}
+		if clamped == v {
+			return false, false
+		}
+		// 'f' keeps integral bounds integral, so max_tokens never becomes 4096.0 or 1e+06
+		obj[name] = json.Number(strconv.FormatFloat(clamped, 'f', -1, 64))
+	}
+	return true, false
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+// copyValue deep copies objects and arrays, so later rules never modify the config
+func copyValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			out[k] = copyValue(item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = copyValue(item)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,254 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestApplyBodyRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		rules   []BodyRule
+		want    string
+		changed bool
+	}{
+		// OpenAI Chat Completions
+		{
+			name:    "openai clamp max_tokens",
+			body:    `{"model":"gpt-4o","max_tokens":1000000}`,
+			rules:   []BodyRule{{Op: BodyRuleClamp, Field: "max_tokens", Max: float(4096)}},
+			want:    `{"max_tokens":4096,"model":"gpt-4o"}`,
+			changed: true,
+		},
+		{
+			name:  "openai clamp within bounds",
+			body:  `{"max_tokens":512}`,
+			rules: []BodyRule{{Op: BodyRuleClamp, Field: "max_tokens", Min: float(1), Max: float(4096)}},
+			want:  `{"max_tokens":512}`,
+		},
+		{
+			name:    "openai clamp to large integer bound",
+			body:    `{"max_tokens":5000000}`,
+			rules:   []BodyRule{{Op: BodyRuleClamp, Field: "max_tokens", Max: float(1000000)}},
+			want:    `{"max_tokens":1000000}`,
+			changed: true,
+		},
+		{
+			name:    "openai clamp integer temperature to fractional bound",
+			body:    `{"temperature":1}`,
+			rules:   []BodyRule{{Op: BodyRuleClamp, Field: "temperature", Max: float(0.7)}},
+			want:    `{"temperature":0.7}`,
+			changed: true,
+		},
+		{
+			name:    "openai clamp float temperature",
+			body:    `{"temperature":1.7}`,
+			rules:   []BodyRule{{Op: BodyRuleClamp, Field: "temperature", Max: float(1.2)}},
+			want:    `{"temperature":1.2}`,
+			changed: true,
+		},
+		{
+			name:    "openai clamp raises to min",
+			body:    `{"temperature":0}`,
+			rules:   []BodyRule{{Op: BodyRuleClamp, Field: "temperature", Min: float(0.25)}},
+			want:    `{"temperature":0.25}`,
+			changed: true,
+		},
+		{
+			name:  "clamp ignores non-numbers and missing fields",
+			body:  `{"max_tokens":"many"}`,
+			rules: []BodyRule{{Op: BodyRuleClamp, Field: "max_tokens", Max: float(10)}, {Op: BodyRuleClamp, Field: "n", Max: float(1)}},
+			want:  `{"max_tokens":"many"}`,
+		},
+		{
+			name:    "openai override temperature",
+			body:    `{"temperature":0.9}`,
+			rules:   []BodyRule{{Op: BodyRuleOverride, Field: "temperature", Value: json.Number("0")}},
+			want:    `{"temperature":0}`,
+			changed: true,
+		},
+		{
+			name:    "openai delete logit_bias",
+			body:    `{"logit_bias":{"50256":-100},"model":"gpt-4o"}`,
+			rules:   []BodyRule{{Op: BodyRuleDelete, Field: "logit_bias"}},
+			want:    `{"model":"gpt-4o"}`,
+			changed: true,
+		},
+		{
+			name:  "delete missing field",
+			body:  `{"model":"gpt-4o"}`,
+			rules: []BodyRule{{Op: BodyRuleDelete, Field: "logit_bias"}},
+			want:  `{"model":"gpt-4o"}`,
+		},
+
+		// Anthropic Messages
+		{
+			name:    "anthropic set_if_absent creates metadata",
+			body:    `{"max_tokens":1024}`,
+			rules:   []BodyRule{{Op: BodyRuleSetIfAbsent, Field: "metadata.user_id", Value: "team-search"}},
+			want:    `{"max_tokens":1024,"metadata":{"user_id":"team-search"}}`,
+			changed: true,
+		},
+		{
+			name:  "anthropic set_if_absent keeps client value",
+			body:  `{"metadata":{"user_id":"alice"}}`,
+			rules: []BodyRule{{Op: BodyRuleSetIfAbsent, Field: "metadata.user_id", Value: "team-search"}},
+			want:  `{"metadata":{"user_id":"alice"}}`,
+		},
+		{
+			name:  "set_if_absent does not replace a non-object parent",
+			body:  `{"metadata":"x"}`,
+			rules: []BodyRule{{Op: BodyRuleSetIfAbsent, Field: "metadata.user_id", Value: "team-search"}},
+			want:  `{"metadata":"x"}`,
+		},
+		{
+			name:    "anthropic clamp max_tokens and delete top_k",
+			body:    `{"max_tokens":64000,"top_k":40}`,
+			rules:   []BodyRule{{Op: BodyRuleClamp, Field: "max_tokens", Max: float(8192)}, {Op: BodyRuleDelete, Field: "top_k"}},
+			want:    `{"max_tokens":8192}`,
+			changed: true,
+		},
+
+		// Gemini generateContent
+		{
+			name:    "gemini clamp nested maxOutputTokens",
+			body:    `{"generationConfig":{"maxOutputTokens":1000000,"temperature":1}}`,
+			rules:   []BodyRule{{Op: BodyRuleClamp, Field: "generationConfig.maxOutputTokens", Min: float(1), Max: float(8192)}},
+			want:    `{"generationConfig":{"maxOutputTokens":8192,"temperature":1}}`,
+			changed: true,
+		},
+		{
+			name:    "gemini clamp raises maxOutputTokens",
+			body:    `{"generationConfig":{"maxOutputTokens":0}}`,
+			rules:   []BodyRule{{Op: BodyRuleClamp, Field: "generationConfig.maxOutputTokens", Min: float(1)}},
+			want:    `{"generationConfig":{"maxOutputTokens":1}}`,
+			changed: true,
+		},
+		{
+			name:  "gemini clamp without generationConfig",
+			body:  `{"contents":[]}`,
+			rules: []BodyRule{{Op: BodyRuleClamp, Field: "generationConfig.maxOutputTokens", Max: float(8192)}},
+			want:  `{"contents":[]}`,
+		},
+		{
+			name:    "gemini override creates generationConfig",
+			body:    `{"contents":[]}`,
+			rules:   []BodyRule{{Op: BodyRuleOverride, Field: "generationConfig.temperature", Value: json.Number("0.2")}},
+			want:    `{"contents":[],"generationConfig":{"temperature":0.2}}`,
+			changed: true,
+		},
+		{
+			name:    "gemini delete nested topK",
+			body:    `{"generationConfig":{"topK":40,"topP":0.9}}`,
+			rules:   []BodyRule{{Op: BodyRuleDelete, Field: "generationConfig.topK"}},
+			want:    `{"generationConfig":{"topP":0.9}}`,
+			changed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.rules {
+				if err := tt.rules[i].validate(); err != nil {
+					t.Fatalf("rule %d: %v", i, err)
+				}
+			}
+
+			var body map[string]interface{}
+			dec := json.NewDecoder(bytes.NewReader([]byte(tt.body)))
+			dec.UseNumber() // like the BodyRules middleware
+			if err := dec.Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			changed, _ := ApplyBodyRules(body, tt.rules)
+			got, err := json.Marshal(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("body = %s, want %s", got, tt.want)
+			}
+			if changed != tt.changed {
+				t.Errorf("changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestApplyBodyRulesDoesNotShareValues(t *testing.T) {
+	rules := []BodyRule{{Op: BodyRuleSetIfAbsent, Field: "metadata", Value: map[string]interface{}{"team": "search"}}}
+
+	first := map[string]interface{}{}
+	ApplyBodyRules(first, rules)
+	first["metadata"].(map[string]interface{})["team"] = "changed"
+
+	second := map[string]interface{}{}
+	ApplyBodyRules(second, rules)
+	if got := second["metadata"].(map[string]interface{})["team"]; got != "search" {
+		t.Errorf("rule value was modified through an earlier body: %v", got)
+	}
+}
+
+func TestBodyRuleValidate(t *testing.T) {
+	tests := []struct {
+		rule BodyRule
+		ok   bool
+	}{
+		{BodyRule{Op: BodyRuleClamp, Field: "max_tokens", Max: float(10)}, true},
+		{BodyRule{Op: BodyRuleClamp, Field: "max_tokens"}, false},
+		{BodyRule{Op: BodyRuleClamp, Field: "max_tokens", Min: float(5), Max: float(1)}, false},
+		{BodyRule{Op: BodyRuleOverride, Field: "temperature"}, false},
+		{BodyRule{Op: BodyRuleDelete, Field: "generationConfig..topK"}, false},
+		{BodyRule{Op: BodyRuleDelete, Field: ""}, false},
+		{BodyRule{Op: "rename", Field: "x"}, false},
+	}
+
+	for _, tt := range tests {
+		if err := tt.rule.validate(); (err == nil) != tt.ok {
+			t.Errorf("validate(%+v) = %v, want ok=%v", tt.rule, err, tt.ok)
+		}
+	}
+}
+
+func TestParseRoutesConfigKeepsRuleNumbers(t *testing.T) {
+	data := []byte(`{"routes":[{"path":"/openai","target":"https://api.openai.com","body_rules":[
+		{"op":"override","field":"seed","value":12345678901234567},
+		{"op":"set_if_absent","field":"generationConfig.maxOutputTokens","value":1000000}
+	]}]}`)
+
+	cfg, err := ParseRoutesConfig(data, ".json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := map[string]interface{}{}
+	ApplyBodyRules(body, cfg.Routes[0].BodyRules)
+	got, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"generationConfig":{"maxOutputTokens":1000000},"seed":12345678901234567}`; string(got) != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+func TestApplyBodyRulesReportsSkippedClamps(t *testing.T) {
+	rules := []BodyRule{
+		{Op: BodyRuleClamp, Field: "max_tokens", Max: float(4096)},
+		{Op: BodyRuleClamp, Field: "temperature", Max: float(1)},
+		{Op: BodyRuleClamp, Field: "top_p", Max: float(1)},
+		{Op: BodyRuleClamp, Field: "n", Max: float(1)},
+	}
+	body := map[string]interface{}{"max_tokens": "8192", "temperature": json.Number("2"), "top_p": nil}
+
+	changed, skipped := ApplyBodyRules(body, rules)
+	if !changed || len(skipped) != 1 || skipped[0] != "max_tokens" {
+		t.Errorf("ApplyBodyRules = %v, %v, want changed and max_tokens skipped", changed, skipped)
+	}
+}
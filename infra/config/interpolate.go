@@ -0,0 +1,194 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// interpolate expands references inside s:
+//
+//	${VAR}            value of environment variable VAR, error if unset
+//	${VAR:-default}   value of VAR, or default if unset or empty
+//	${file:/path}     content of the file, trailing newline trimmed (mounted secrets)
+//	$${...}           literal "${...}"
+//
+// changed reports whether any reference was expanded.
+func interpolate(s string, getenv func(string) (string, bool)) (out string, changed bool, err error) {
+	if !strings.Contains(s, "${") {
+		return s, false, nil
+	}
+
+	var b strings.Builder
+	rest := s
+	for {
+		i := strings.Index(rest, "${")
+		if i < 0 {
+			b.WriteString(rest)
+			break
+		}
+
+		// escaped "$${"
+		if i > 0 && rest[i-1] == '$' {
+			b.WriteString(rest[:i-1])
+			b.WriteString("${")
+			rest = rest[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(rest[i:], '}')
+		if end < 0 {
+			return "", false, fmt.Errorf("unterminated reference in %q", s)
+		}
+
+		b.WriteString(rest[:i])
+		ref := rest[i+2 : i+end]
+		val, err := resolveRef(ref, getenv)
+		if err != nil {
+			return "", false, err
+		}
+		b.WriteString(val)
+		changed = true
+		rest = rest[i+end+1:]
+	}
+
+	return b.String(), changed, nil
+}
+
+func resolveRef(ref string, getenv func(string) (string, bool)) (string, error) {
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("secret file reference: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, def, hasDefault := strings.Cut(ref, ":-")
+	if name == "" {
+		return "", fmt.Errorf("empty variable name in ${%s}", ref)
+	}
+
+	if v, ok := getenv(name); ok && (v != "" || !hasDefault) {
+		return v, nil
+	}
+	if hasDefault {
+		return def, nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set", name)
+}
+
+// keys whose values are kept as written: model_map targets use "${1}" for regex captures
+var literalKeys = map[string]bool{
+	"model_map": true,
+}
+
+// interpolateTree expands references in every string value of a decoded JSON tree,
+// except below literalKeys. The returned tree is a copy, the input is left untouched
+// so it can be shown to users.
+func interpolateTree(v interface{}, getenv func(string) (string, bool)) (interface{}, error) {
+	switch t := v.(type) {
+	case string:
+		out, _, err := interpolate(t, getenv)
+		return out, err
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			if literalKeys[k] {
+				m[k] = child
+				continue
+			}
+			out, err := interpolateTree(child, getenv)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			m[k] = out
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, child := range t {
+			out, err := interpolateTree(child, getenv)
+			if err != nil {
+				return nil, fmt.Errorf("[%d]: %w", i, err)
+			}
+			s[i] = out
+		}
+		return s, nil
+	default:
+		return v, nil
+	}
+}
+
+// hasReference reports whether s contains a ${...} reference that is not escaped as $${
+func hasReference(s string) bool {
+	for i := strings.Index(s, "${"); i >= 0; {
+		if i == 0 || s[i-1] != '$' {
+			return true
+		}
+		next := strings.Index(s[i+2:], "${")
+		if next < 0 {
+			return false
+		}
+		i += 2 + next
+	}
+	return false
+}
+
+// routeReferences returns the string values of r holding references, keyed by their
+// JSON path like "headers.Authorization". model_map is skipped, it is never interpolated.
+func routeReferences(r Route) map[string]string {
+	var tree interface{}
+	data, _ := json.Marshal(r)
+	json.Unmarshal(data, &tree)
+
+	refs := make(map[string]string)
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		switch t := v.(type) {
+		case string:
+			if hasReference(t) {
+				refs[path] = t
+			}
+		case map[string]interface{}:
+			for k, child := range t {
+				if !literalKeys[k] {
+					walk(strings.TrimPrefix(path+"."+k, "."), child)
+				}
+			}
+		case []interface{}:
+			for i, child := range t {
+				walk(fmt.Sprintf("%s[%d]", path, i), child)
+			}
+		}
+	}
+	walk("", tree)
+	return refs
+}
+
+// CheckSubmittedReferences rejects ${...} references in a route submitted through the
+// admin API, which would let API users read environment variables and files of the host.
+// References of the stored route (nil for a new route) may be kept as they are, in the
+// same field and with the same target, so file-defined routes can still be edited.
+func CheckSubmittedReferences(submitted Route, stored *Route) error {
+	var kept map[string]string
+	if stored != nil && stored.Target == submitted.Target {
+		kept = routeReferences(*stored)
+	}
+
+	var fields []string
+	for path, v := range routeReferences(submitted) {
+		if kept[path] != v {
+			fields = append(fields, path)
+		}
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+
+	sort.Strings(fields)
+	return fmt.Errorf("${...} references cannot be added through the API (%s), "+
+		"set them in the routes file or write $${ for a literal ${", strings.Join(fields, ", "))
+}
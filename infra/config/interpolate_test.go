@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "key")
+	os.WriteFile(secret, []byte("sk-from-file\r\n"), 0600)
+	multiline := filepath.Join(dir, "multi")
+	os.WriteFile(multiline, []byte("line1\nline2\n\n"), 0600)
+
+	env := map[string]string{"KEY": "sk-env", "EMPTY": "", "HOST": "api.example.com"}
+	getenv := func(k string) (string, bool) {
+		v, ok := env[k]
+		return v, ok
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		changed bool
+		err     string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "Bearer ${KEY}", want: "Bearer sk-env", changed: true},
+		{in: "https://${HOST}/v1?key=${KEY}", want: "https://api.example.com/v1?key=sk-env", changed: true},
+		{in: "${MISSING:-fallback}", want: "fallback", changed: true},
+		{in: "${EMPTY:-fallback}", want: "fallback", changed: true},
+		{in: "${EMPTY}", want: "", changed: true},
+		{in: "${KEY:-fallback}", want: "sk-env", changed: true},
+		{in: "${MISSING:-}", want: "", changed: true},
+		{in: "${file:" + secret + "}", want: "sk-from-file", changed: true},
+		{in: "${file:" + multiline + "}", want: "line1\nline2", changed: true},
+		{in: "$${KEY}", want: "${KEY}"},
+		{in: "$${KEY} ${KEY}", want: "${KEY} sk-env", changed: true},
+		{in: "${MISSING}", err: "MISSING is not set"},
+		{in: "${file:" + filepath.Join(dir, "none") + "}", err: "secret file reference"},
+		{in: "${KEY", err: "unterminated"},
+		{in: "${:-x}", err: "empty variable name"},
+	}
+
+	for _, tt := range tests {
+		got, changed, err := interpolate(tt.in, getenv)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("interpolate(%q) error = %v, want %q", tt.in, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want || changed != tt.changed {
+			t.Errorf("interpolate(%q) = %q, %v, %v, want %q, %v", tt.in, got, changed, err, tt.want, tt.changed)
+		}
+	}
+}
+
+func TestInterpolateTreeKeepsModelMap(t *testing.T) {
+	tree := map[string]interface{}{
+		"target":    "https://${HOST}",
+		"model_map": map[string]interface{}{"/^gpt-(.*)$/": "azure-${1}"},
+	}
+	out, err := interpolateTree(tree, func(k string) (string, bool) { return "h", k == "HOST" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := out.(map[string]interface{})
+	if m["target"] != "https://h" || m["model_map"].(map[string]interface{})["/^gpt-(.*)$/"] != "azure-${1}" {
+		t.Errorf("tree = %v", m)
+	}
+	if tree["target"] != "https://${HOST}" {
+		t.Error("input tree was modified")
+	}
+}
+
+func TestCheckSubmittedReferences(t *testing.T) {
+	stored := Route{
+		Path:    "/openai",
+		Target:  "https://api.openai.com",
+		Headers: map[string]string{"Authorization": "Bearer ${OPENAI_KEY}"},
+	}
+
+	tests := []struct {
+		name      string
+		submitted Route
+		stored    *Route
+		ok        bool
+	}{
+		{
+			name:      "new route without references",
+			submitted: Route{Path: "/a", Target: "https://a.example.com", Headers: map[string]string{"x": "literal $${x}"}},
+			ok:        true,
+		},
+		{
+			name:      "new route reading an env var",
+			submitted: Route{Path: "/a", Target: "https://attacker.example", Headers: map[string]string{"x": "${AUTH_TOKEN_KEY}"}},
+		},
+		{
+			name:      "new route reading a file",
+			submitted: Route{Path: "/a", Target: "https://attacker.example/${file:/etc/passwd}"},
+		},
+		{
+			name:      "model_map captures are allowed",
+			submitted: Route{Path: "/a", Target: "https://a.example.com", ModelMap: map[string]string{"/^x-(.*)$/": "${1}"}},
+			ok:        true,
+		},
+		{
+			name: "update keeping the stored reference",
+			submitted: Route{Path: "/openai", Target: "https://api.openai.com", Description: "edited",
+				Headers: map[string]string{"Authorization": "Bearer ${OPENAI_KEY}"}},
+			stored: &stored,
+			ok:     true,
+		},
+		{
+			name: "update moving the reference to another target",
+			submitted: Route{Path: "/openai", Target: "https://attacker.example",
+				Headers: map[string]string{"Authorization": "Bearer ${OPENAI_KEY}"}},
+			stored: &stored,
+		},
+		{
+			name: "update moving the reference to another field",
+			submitted: Route{Path: "/openai", Target: "https://api.openai.com",
+				Headers: map[string]string{"X-Echo": "Bearer ${OPENAI_KEY}"}},
+			stored: &stored,
+		},
+		{
+			name: "update adding a reference",
+			submitted: Route{Path: "/openai", Target: "https://api.openai.com",
+				Headers: map[string]string{"Authorization": "Bearer ${OPENAI_KEY}", "X-Org": "${ORG}"}},
+			stored: &stored,
+		},
+	}
+
+	for _, tt := range tests {
+		err := CheckSubmittedReferences(tt.submitted, tt.stored)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// model_map keys are matched in this order, first match wins:
+//  1. exact names
+//  2. glob patterns like "gpt-4o-*"
+//  3. regular expressions, keys starting with "^", e.g. "^claude-(.*)$" -> "anthropic/claude-$1"
+//
+// Within globs and regexes, longer keys are tried first, then in alphabetical order.
+
+type modelRule struct {
+	key    string
+	target string
+	re     *regexp.Regexp // nil for globs
+}
+
+// modelMapper is the compiled form of a route's model_map
+type modelMapper struct {
+	exact map[string]string
+	globs []modelRule
+	regex []modelRule
+}
+
+func isModelRegex(key string) bool {
+	return strings.HasPrefix(key, "^")
+}
+
+// isModelMapPattern reports whether a model_map key is a glob or regex rather than a model name
+func isModelMapPattern(key string) bool {
+	return isModelRegex(key) || isModelPattern(key)
+}
+
+func compileModelMap(m map[string]string) (*modelMapper, error) {
+	mm := &modelMapper{exact: make(map[string]string)}
+
+	for key, target := range m {
+		switch {
+		case isModelRegex(key):
+			re, err := regexp.Compile(key)
+			if err != nil {
+				return nil, fmt.Errorf("bad model_map regex %q: %v", key, err)
+			}
+			mm.regex = append(mm.regex, modelRule{key: key, target: target, re: re})
+		case isModelPattern(key):
+			if _, err := path.Match(key, ""); err != nil {
+				return nil, fmt.Errorf("bad model_map pattern %q", key)
+			}
+			mm.globs = append(mm.globs, modelRule{key: key, target: target})
+		default:
+			mm.exact[key] = target
+		}
+	}
+
+	sortModelRules(mm.globs)
+	sortModelRules(mm.regex)
+	return mm, nil
+}
+
+func sortModelRules(rules []modelRule) {
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].key) != len(rules[j].key) {
+			return len(rules[i].key) > len(rules[j].key)
+		}
+		return rules[i].key < rules[j].key
+	})
+}
+
+func (mm *modelMapper) mapModel(model string) (string, bool) {
+	if target, ok := mm.exact[model]; ok {
+		return target, true
+	}
+	for _, r := range mm.globs {
+		if ok, _ := path.Match(r.key, model); ok {
+			return r.target, true
+		}
+	}
+	for _, r := range mm.regex {
+		if m := r.re.FindStringSubmatchIndex(model); m != nil {
+			return string(r.re.ExpandString(nil, r.target, model, m)), true
+		}
+	}
+	return "", false
+}
+
+// MapModel returns the upstream name of model according to the route's model_map
+func (r *Route) MapModel(model string) (string, bool) {
+	if len(r.ModelMap) == 0 {
+		return "", false
+	}
+
+	mm := r.modelMapper
+	if mm == nil {
+		// routes that did not go through ValidateRoutes
+		var err error
+		if mm, err = compileModelMap(r.ModelMap); err != nil {
+			return "", false
+		}
+	}
+	return mm.mapModel(model)
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"path"
+	"strings"
+
+	"github.com/poixeai/proxify/util"
+)
+
+// MatchModel finds the route serving model on the unified /v1 endpoint and
+// returns it with the model name to send upstream.
+//
+// Lookup order, first match wins and routes are tried in config order:
+//  1. exact names in `models` or `model_map`
+//  2. "provider/model" where provider is a route path, e.g. "groq/llama3"
+//  3. glob patterns in `models`, e.g. "claude-*", or pattern keys of `model_map`
+//
+// Disabled routes are skipped.
+func (cfg *RoutesConfig) MatchModel(model string) (*Route, string, bool) {
+	if model == "" {
+		return nil, "", false
+	}
+
+	// 1. exact
+	for i := range cfg.Routes {
+		r := &cfg.Routes[i]
+		if r.Disabled {
+			continue
+		}
+		if _, ok := r.ModelMap[model]; ok && !isModelMapPattern(model) {
+			return r, model, true
+		}
+		for _, m := range r.Models {
+			if !isModelPattern(m) && m == model {
+				return r, model, true
+			}
+		}
+	}
+
+	// 2. provider prefix
+	if provider, rest, ok := strings.Cut(model, "/"); ok && rest != "" {
+		for i := range cfg.Routes {
+			r := &cfg.Routes[i]
+			if !r.Disabled && strings.TrimPrefix(r.Path, "/") == provider {
+				return r, rest, true
+			}
+		}
+	}
+
+	// 3. glob
+	for i := range cfg.Routes {
+		r := &cfg.Routes[i]
+		if r.Disabled {
+			continue
+		}
+		for _, m := range r.Models {
+			if !isModelPattern(m) {
+				continue
+			}
+			if ok, _ := path.Match(m, model); ok {
+				return r, model, true
+			}
+		}
+		if _, ok := r.MapModel(model); ok {
+			return r, model, true
+		}
+	}
+
+	return nil, "", false
+}
+
+// IsModelPattern reports whether a models or model_map entry is a pattern rather than a model name
+func IsModelPattern(s string) bool {
+	return isModelMapPattern(s)
+}
+
+func isModelPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+// ModelAllowed reports whether a model, given by one or more names, passes the
+// route's models_allow / models_deny filters
+func (r *Route) ModelAllowed(names ...string) bool {
+	return util.ModelAllowed(r.ModelsAllow, r.ModelsDeny, names...)
+}
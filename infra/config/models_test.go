@@ -0,0 +1,46 @@
+package config
+
+import "testing"
+
+func TestMatchModel(t *testing.T) {
+	cfg := &RoutesConfig{Routes: []Route{
+		{Path: "/off", Target: "https://off.example.com", Models: []string{"gpt-4o"}, Disabled: true},
+		{Path: "/openai", Target: "https://api.openai.com", Models: []string{"gpt-4o", "gpt-4*"}},
+		{Path: "/azure", Target: "https://azure.example.com", Models: []string{"gpt-4.1"},
+			ModelMap: map[string]string{"fast": "gpt-4o-mini", "o*": "o3"}},
+		{Path: "/claude", Target: "https://api.anthropic.com", Models: []string{"claude-*"}},
+	}}
+	if err := ValidateRoutes(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		model, route, upstream string
+	}{
+		{"gpt-4o", "/openai", "gpt-4o"},      // disabled routes are skipped
+		{"gpt-4.1", "/azure", "gpt-4.1"},     // exact names win over earlier globs
+		{"fast", "/azure", "fast"},           // model_map keys, mapped later by ModelRewrite
+		{"azure/gpt-4o", "/azure", "gpt-4o"}, // provider prefix
+		{"openai/o1/x", "/openai", "o1/x"},   // only the first segment is the provider
+		{"gpt-4-turbo", "/openai", "gpt-4-turbo"},
+		{"o1-mini", "/azure", "o1-mini"}, // model_map patterns
+		{"claude-sonnet-4", "/claude", "claude-sonnet-4"},
+		{"off/gpt-4o", "", ""},
+		{"unknown/gpt-4o", "", ""},
+		{"azure/", "", ""},
+		{"llama", "", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		route, upstream, ok := cfg.MatchModel(tt.model)
+		if tt.route == "" {
+			if ok {
+				t.Errorf("%q: matched %s, want no match", tt.model, route.Path)
+			}
+			continue
+		}
+		if !ok || route.Path != tt.route || upstream != tt.upstream {
+			t.Errorf("%q: got %v %q %v, want %s %q", tt.model, route, upstream, ok, tt.route, tt.upstream)
+		}
+	}
+}
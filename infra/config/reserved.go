@@ -1,6 +1,23 @@
 package config
 
-// defines the system-level routes that should not be proxied
+// system-level top routes
+const (
+	APITopRoute     = "api" // management API and frontend data
+	UnifiedTopRoute = "v1"  // unified OpenAI compatible endpoint, routed by model
+)
+
+// defines the system-level routes that should not be proxied.
+// /v1 is not reserved: a route with that path keeps working and turns the unified endpoint off.
 var ReservedTopRoutes = map[string]bool{
-	"api": true,
+	APITopRoute: true,
+}
+
+// UnifiedEndpoint reports whether /v1 is the unified endpoint, i.e. no route uses /v1 itself
+func (cfg *RoutesConfig) UnifiedEndpoint() bool {
+	for i := range cfg.Routes {
+		if cfg.Routes[i].Path == "/"+UnifiedTopRoute {
+			return false
+		}
+	}
+	return true
 }
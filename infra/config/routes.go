@@ -1,8 +1,16 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
 )
 
 type Route struct {
@@ -14,25 +22,298 @@ type Route struct {
 	// model mapping (optional)
 	ModelMap map[string]string `json:"model_map,omitempty"`
 
+	// report the model the client asked for in responses, instead of the rewritten one (optional)
+	RestoreModel bool `json:"restore_model,omitempty"`
+
+	// models served through the unified /v1 endpoint (optional),
+	// exact names or glob patterns like "gpt-4*"
+	Models []string `json:"models,omitempty"`
+
+	// glob filters for the models this route exposes (optional), deny wins over allow
+	ModelsAllow []string `json:"models_allow,omitempty"`
+	ModelsDeny  []string `json:"models_deny,omitempty"`
+
 	// API format transform (optional)
 	// "responses_to_chat" - converts OpenAI Responses API to Chat Completions API
+	// "anthropic_to_chat" - converts Anthropic Messages API to Chat Completions API
+	// "chat_to_anthropic" - converts Chat Completions API to Anthropic Messages API
+	// "chat_to_gemini"    - converts Chat Completions API to Gemini generateContent
 	Transform string `json:"transform,omitempty"`
+
+	// upstream only accepts string message content (optional, responses_to_chat):
+	// text parts are joined, images and files are replaced by placeholders
+	TextOnly bool `json:"text_only,omitempty"`
+
+	// report upstream reasoning_content as reasoning summary items (optional, responses_to_chat)
+	ReasoningSummary bool `json:"reasoning_summary,omitempty"`
+
+	// what happens to request parameters without a Chat Completions equivalent (optional, responses_to_chat):
+	// "drop" (default) or "reject"
+	UnsupportedParams string `json:"unsupported_params,omitempty"`
+
+	// changes applied to JSON request bodies after model rewriting (optional)
+	BodyRules []BodyRule `json:"body_rules,omitempty"`
+
+	// extra headers set on upstream requests (optional), e.g. upstream API keys
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// disabled routes are kept in the config but answer 503 (optional)
+	Disabled bool `json:"disabled,omitempty"`
+
+	// file the route was loaded from, not part of the config format
+	Source string `json:"-"`
+
+	// route as written in the config file, before ${...} interpolation
+	raw *Route
+
+	// compiled model_map, set by ValidateRoutes
+	modelMapper *modelMapper
+}
+
+// Public returns the route as written in the config, with ${...} references
+// instead of their values, so secrets are never exposed by APIs or logs
+func (r Route) Public() Route {
+	if r.raw == nil {
+		return r
+	}
+	pub := *r.raw
+	pub.Source = r.Source
+	return pub
+}
+
+// PublicRoutes returns Public() of every route
+func (cfg *RoutesConfig) PublicRoutes() []Route {
+	out := make([]Route, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		out = append(out, r.Public())
+	}
+	return out
 }
 
 type RoutesConfig struct {
 	Routes []Route `json:"routes"`
 }
 
+// DefaultRoutesConfig is used when no routes config exists
+func DefaultRoutesConfig() *RoutesConfig {
+	return &RoutesConfig{
+		Routes: []Route{
+			// default routes, add more
+			{Path: "/openai", Target: "https://api.openai.com"},
+		},
+	}
+}
+
+// routes file names tried in order when no file is given explicitly
+var defaultRoutesFiles = []string{"routes.json", "routes.yaml", "routes.yml"}
+
+// DefaultRoutesFile returns the first existing default routes file, or routes.json
+func DefaultRoutesFile() string {
+	for _, f := range defaultRoutesFiles {
+		if _, err := os.Stat(f); err == nil {
+			return f
+		}
+	}
+	return defaultRoutesFiles[0]
+}
+
+// LoadRoutesConfig loads a JSON or YAML routes file and expands ${...} references
+// in string values using the process environment
 func LoadRoutesConfig(path string) (*RoutesConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var cfg RoutesConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	cfg, err := ParseRoutesConfig(data, filepath.Ext(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for i := range cfg.Routes {
+		cfg.Routes[i].Source = path
+	}
+
+	return cfg, nil
+}
+
+// ParseRoutesConfig parses a routes config in the format given by ext (".json", ".yaml", ".yml")
+func ParseRoutesConfig(data []byte, ext string) (*RoutesConfig, error) {
+	if isYAMLExt(ext) {
+		converted, err := yaml.YAMLToJSON(data)
+		if err != nil {
+			return nil, err
+		}
+		data = converted
+	}
+
+	// decode into a generic tree first so interpolation sees every string value
+	var tree interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&tree); err != nil {
 		return nil, err
 	}
 
+	resolved, err := interpolateTree(tree, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw, cfg RoutesConfig
+	if err := decodeTree(tree, &raw); err != nil {
+		return nil, err
+	}
+	if err := decodeTree(resolved, &cfg); err != nil {
+		return nil, err
+	}
+
+	for i := range cfg.Routes {
+		cfg.Routes[i].raw = &raw.Routes[i]
+	}
+
 	return &cfg, nil
 }
+
+// EncodeRoutesConfig serializes cfg in the format given by ext, using the routes
+// as written (Public), so ${...} references are preserved
+func EncodeRoutesConfig(cfg *RoutesConfig, ext string) ([]byte, error) {
+	data, err := json.MarshalIndent(RoutesConfig{Routes: cfg.PublicRoutes()}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	if isYAMLExt(ext) {
+		return yaml.JSONToYAML(data)
+	}
+	return append(data, '\n'), nil
+}
+
+func decodeTree(tree interface{}, v interface{}) error {
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	// keep body rule values as written, 4096 must not turn into a float
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func isYAMLExt(ext string) bool {
+	return strings.EqualFold(ext, ".yaml") || strings.EqualFold(ext, ".yml")
+}
+
+// RoutesDirFor returns the default routes.d directory next to the routes file
+func RoutesDirFor(file string) string {
+	return filepath.Join(filepath.Dir(file), "routes.d")
+}
+
+// LoadRoutes loads the routes file and merges every JSON/YAML file in dir (sorted by name).
+// Either source may be missing; if both are, an error satisfying os.IsNotExist is returned.
+// Paths defined in more than one file are reported as errors.
+func LoadRoutes(file, dir string) (*RoutesConfig, error) {
+	cfg, err := LoadRoutesConfig(file)
+	switch {
+	case err == nil:
+	case os.IsNotExist(err):
+		cfg = nil
+	default:
+		return nil, err
+	}
+
+	merged, err := MergeRoutesDir(cfg, dir)
+	if err != nil {
+		return nil, err
+	}
+	if merged == nil {
+		return nil, &os.PathError{Op: "open", Path: file, Err: os.ErrNotExist}
+	}
+	return merged, nil
+}
+
+// MergeRoutesDir merges every JSON/YAML file in dir (sorted by name) into main.
+// main may be nil; if it is and dir has no files, nil is returned.
+func MergeRoutesDir(main *RoutesConfig, dir string) (*RoutesConfig, error) {
+	merged := &RoutesConfig{}
+	found := false
+	var errs []error
+
+	add := func(cfg *RoutesConfig) {
+		for _, r := range cfg.Routes {
+			for _, existing := range merged.Routes {
+				if existing.Path == r.Path && existing.Source != r.Source {
+					errs = append(errs, fmt.Errorf("invalid route: duplicate path '%s' in %s (already defined in %s)",
+						r.Path, r.Source, existing.Source))
+				}
+			}
+			merged.Routes = append(merged.Routes, r)
+		}
+	}
+
+	if main != nil {
+		found = true
+		add(main)
+	}
+
+	files, err := routesDirFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		cfg, err := LoadRoutesConfig(f)
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		add(cfg)
+	}
+
+	if !found {
+		return nil, nil
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
+
+// routesDirFiles lists config files in dir, skipping hidden files such as
+// Kubernetes ConfigMap internals (..data) and editor swap files
+func routesDirFiles(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || !isRoutesFile(name) {
+			continue
+		}
+
+		// follow symlinks, ConfigMap files are links into ..data
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || info.IsDir() {
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func isRoutesFile(name string) bool {
+	ext := filepath.Ext(name)
+	return strings.EqualFold(ext, ".json") || isYAMLExt(ext)
+}
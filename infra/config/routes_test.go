@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadRoutesMergesDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "routes.json")
+	routesDir := filepath.Join(dir, "routes.d")
+	writeFile(t, file, `{"routes":[{"path":"/openai","target":"https://api.openai.com"}]}`)
+	writeFile(t, filepath.Join(routesDir, "20-groq.yaml"), "routes:\n  - path: /groq\n    target: https://api.groq.com/openai\n")
+	writeFile(t, filepath.Join(routesDir, "10-claude.json"), `{"routes":[{"path":"/claude","target":"https://api.anthropic.com"}]}`)
+	writeFile(t, filepath.Join(routesDir, ".10-claude.json.swp"), `not a config`)
+	writeFile(t, filepath.Join(routesDir, "README.md"), `not a config`)
+
+	cfg, err := LoadRoutes(file, routesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range cfg.Routes {
+		got = append(got, r.Path+"@"+filepath.Base(r.Source))
+	}
+	if want := "/openai@routes.json /claude@10-claude.json /groq@20-groq.yaml"; strings.Join(got, " ") != want {
+		t.Errorf("routes = %v, want %s", got, want)
+	}
+
+	// routes.d alone is enough
+	cfg, err = LoadRoutes(filepath.Join(dir, "missing.json"), routesDir)
+	if err != nil || len(cfg.Routes) != 2 {
+		t.Errorf("without routes file: %v, %v", cfg, err)
+	}
+	if _, err := LoadRoutes(filepath.Join(dir, "missing.json"), filepath.Join(dir, "missing.d")); !os.IsNotExist(err) {
+		t.Errorf("without any config: err = %v, want not exist", err)
+	}
+}
+
+func TestLoadRoutesRejectsDuplicatesAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "routes.json")
+	routesDir := filepath.Join(dir, "routes.d")
+	writeFile(t, file, `{"routes":[{"path":"/openai","target":"https://api.openai.com"}]}`)
+	writeFile(t, filepath.Join(routesDir, "openai.json"), `{"routes":[{"path":"/openai","target":"https://proxy.example.com"}]}`)
+
+	_, err := LoadRoutes(file, routesDir)
+	if err == nil || !strings.Contains(err.Error(), "duplicate path '/openai'") || !strings.Contains(err.Error(), "openai.json") {
+		t.Errorf("err = %v, want a duplicate naming both files", err)
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Settings holds the server settings that can be changed without a restart
+type Settings struct {
+	Auth  *AuthConfig
+	Admin *AdminConfig
+
+	// CORS allowed origins, empty means reflect any origin
+	CORSAllowOrigins []string
+
+	StreamSmoothing bool
+	StreamHeartbeat bool
+
+	LogLevel string
+
+	// how long upstream model lists are cached for /v1/models, 0 disables caching
+	ModelsCacheTTL time.Duration
+}
+
+func LoadSettings() (*Settings, error) {
+	return LoadSettingsFrom(os.Getenv)
+}
+
+// LoadSettingsFrom loads and validates settings using getenv to look up variables
+func LoadSettingsFrom(getenv func(string) string) (*Settings, error) {
+	auth, err := LoadAuthConfigFrom(getenv)
+	if err != nil {
+		return nil, fmt.Errorf("auth config: %w", err)
+	}
+	if err := auth.Validate(); err != nil {
+		return nil, err
+	}
+
+	admin, err := LoadAdminConfigFrom(getenv)
+	if err != nil {
+		return nil, fmt.Errorf("admin config: %w", err)
+	}
+
+	s := &Settings{
+		Auth:            auth,
+		Admin:           admin,
+		StreamSmoothing: getenv("STREAM_SMOOTHING_ENABLED") == "true",
+		StreamHeartbeat: getenv("STREAM_HEARTBEAT_ENABLED") == "true",
+		LogLevel:        strings.ToLower(strings.TrimSpace(getenv("LOG_LEVEL"))),
+	}
+
+	for _, origin := range strings.Split(getenv("CORS_ALLOW_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			s.CORSAllowOrigins = append(s.CORSAllowOrigins, origin)
+		}
+	}
+
+	s.ModelsCacheTTL = 5 * time.Minute
+	if v := strings.TrimSpace(getenv("MODELS_CACHE_TTL")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid MODELS_CACHE_TTL %q", v)
+		}
+		s.ModelsCacheTTL = d
+	}
+
+	// default log level follows MODE like before
+	if s.LogLevel == "" {
+		s.LogLevel = "info"
+		if getenv("MODE") == "debug" {
+			s.LogLevel = "debug"
+		}
+	}
+	if _, err := zapcore.ParseLevel(s.LogLevel); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL %q", s.LogLevel)
+	}
+
+	return s, nil
+}
+
+// CORSOriginAllowed reports whether origin may access the server
+func (s *Settings) CORSOriginAllowed(origin string) bool {
+	if len(s.CORSAllowOrigins) == 0 {
+		return true
+	}
+	for _, o := range s.CORSAllowOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	pathpkg "path"
+	"strings"
+)
+
+// supported values of Route.Transform
+const (
+	TransformResponsesToChat = "responses_to_chat"
+	TransformAnthropicToChat = "anthropic_to_chat"
+	TransformChatToAnthropic = "chat_to_anthropic"
+	TransformChatToGemini    = "chat_to_gemini"
+)
+
+// supported values of Route.UnsupportedParams
+const (
+	UnsupportedParamsDrop   = "drop"
+	UnsupportedParamsReject = "reject"
+)
+
+var supportedTransforms = map[string]bool{
+	TransformResponsesToChat: true,
+	TransformAnthropicToChat: true,
+	TransformChatToAnthropic: true,
+	TransformChatToGemini:    true,
+}
+
+// ValidateRoutes checks the whole routes config and returns all problems found.
+// It also compiles the model_map patterns, so they are not compiled per request.
+func ValidateRoutes(cfg *RoutesConfig) error {
+	var errs []error
+	seen := make(map[string]bool)
+
+	for i, r := range cfg.Routes {
+		path := r.Path
+
+		// 1. check empty
+		if path == "" {
+			errs = append(errs, fmt.Errorf("invalid route #%d: empty path is not allowed", i))
+			continue
+		}
+
+		// 2. check format, only single-level prefixes like /openai are matched
+		top := strings.TrimPrefix(path, "/")
+		if !strings.HasPrefix(path, "/") || top == "" || strings.Contains(top, "/") {
+			errs = append(errs, fmt.Errorf("invalid route: path '%s' must be a single segment like '/openai'", path))
+		}
+
+		// 3. check reserved
+		if ReservedTopRoutes[top] {
+			errs = append(errs, fmt.Errorf("invalid route: path '%s' is reserved by system", path))
+		}
+
+		// 4. check duplicate
+		if seen[path] {
+			errs = append(errs, fmt.Errorf("invalid route: duplicate path '%s'", path))
+		}
+		seen[path] = true
+
+		// 5. check target
+		if err := validateTarget(r.Target); err != nil {
+			errs = append(errs, fmt.Errorf("invalid route '%s': %w", path, err))
+		}
+
+		// 6. check model map
+		for from, to := range r.ModelMap {
+			if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" {
+				errs = append(errs, fmt.Errorf("invalid route '%s': model_map entries must not be empty (%q -> %q)", path, from, to))
+			}
+		}
+		if mm, err := compileModelMap(r.ModelMap); err != nil {
+			errs = append(errs, fmt.Errorf("invalid route '%s': %w", path, err))
+		} else {
+			cfg.Routes[i].modelMapper = mm
+		}
+
+		// 7. check models and filters
+		for _, f := range []struct {
+			field    string
+			patterns []string
+		}{
+			{"models", r.Models},
+			{"models_allow", r.ModelsAllow},
+			{"models_deny", r.ModelsDeny},
+		} {
+			field := f.field
+			for _, m := range f.patterns {
+				if strings.TrimSpace(m) == "" {
+					errs = append(errs, fmt.Errorf("invalid route '%s': %s entries must not be empty", path, field))
+				} else if _, err := pathpkg.Match(m, ""); err != nil {
+					errs = append(errs, fmt.Errorf("invalid route '%s': bad pattern %q in %s", path, m, field))
+				}
+			}
+		}
+
+		// 8. check transform
+		if r.Transform != "" && !supportedTransforms[r.Transform] {
+			errs = append(errs, fmt.Errorf("invalid route '%s': unknown transform '%s'", path, r.Transform))
+		}
+		if r.TextOnly && r.Transform != TransformResponsesToChat {
+			errs = append(errs, fmt.Errorf("invalid route '%s': text_only requires transform '%s'", path, TransformResponsesToChat))
+		}
+		switch r.UnsupportedParams {
+		case "", UnsupportedParamsDrop, UnsupportedParamsReject:
+		default:
+			errs = append(errs, fmt.Errorf("invalid route '%s': unsupported_params must be '%s' or '%s'",
+				path, UnsupportedParamsDrop, UnsupportedParamsReject))
+		}
+		if r.UnsupportedParams != "" && r.Transform != TransformResponsesToChat {
+			errs = append(errs, fmt.Errorf("invalid route '%s': unsupported_params requires transform '%s'", path, TransformResponsesToChat))
+		}
+		if r.ReasoningSummary && r.Transform != TransformResponsesToChat {
+			errs = append(errs, fmt.Errorf("invalid route '%s': reasoning_summary requires transform '%s'", path, TransformResponsesToChat))
+		}
+
+		// 9. check body rules
+		for j := range r.BodyRules {
+			if err := r.BodyRules[j].validate(); err != nil {
+				errs = append(errs, fmt.Errorf("invalid route '%s': body_rules #%d: %w", path, j, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validateTarget(target string) error {
+	if target == "" {
+		return errors.New("target is required")
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid target '%s': %v", target, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid target '%s': scheme must be http or https", target)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid target '%s': missing host", target)
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package conversation
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/poixeai/proxify/infra/logger"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	recordsBucket = []byte("records") // id -> Record JSON
+	orderBucket   = []byte("order")   // created_at (big endian unix nanos) + id -> id, oldest first
+	metaBucket    = []byte("meta")
+	countKey      = []byte("count") // records in recordsBucket, kept in the transaction that changes them
+)
+
+// lockTimeout bounds the wait for the database file lock held by another process
+const lockTimeout = 5 * time.Second
+
+// boltStore keeps records in a bbolt database file, so records survive restarts.
+//
+// The database is opened for each operation rather than for the life of the process:
+// bbolt locks the file while it is open, and during a graceful restart the old and the
+// new process both serve requests. Writes are transactions, a crash never leaves a
+// partial record.
+type boltStore struct {
+	path       string
+	ttl        time.Duration
+	maxEntries int
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewFileStore returns a store keeping records in the database file at path, ttl 0 keeps
+// records forever and maxEntries 0 keeps any number of records
+func NewFileStore(path string, ttl time.Duration, maxEntries int) Store {
+	return &boltStore{path: path, ttl: ttl, maxEntries: maxEntries}
+}
+
+// open opens the database, creating it and its buckets if needed
+func (s *boltStore) open() (*bolt.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(s.path, 0600, &bolt.Options{Timeout: lockTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	// set up once, a write transaction on every open would sync the file for nothing
+	ready := false
+	db.View(func(tx *bolt.Tx) error {
+		ready = tx.Bucket(metaBucket) != nil && tx.Bucket(metaBucket).Get(countKey) != nil
+		return nil
+	})
+	if ready {
+		return db, nil
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{recordsBucket, orderBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if tx.Bucket(metaBucket).Get(countKey) == nil {
+			return setRecordCount(tx, tx.Bucket(recordsBucket).Stats().KeyN)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// update runs fn in a write transaction
+func (s *boltStore) update(fn func(tx *bolt.Tx) error) error {
+	db, err := s.open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Update(fn)
+}
+
+func (s *boltStore) Get(id string) (*Record, error) {
+	if !ValidID(id) {
+		return nil, ErrNotFound
+	}
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	db, err := s.open()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var rec Record
+	err = db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(recordsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, &rec)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if expired(&rec, s.ttl) {
+		err := db.Update(func(tx *bolt.Tx) error {
+			return s.remove(tx, id)
+		})
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			logger.Warnf("[conversation] failed to remove expired record %s: %v", id, err)
+		}
+		return nil, ErrNotFound
+	}
+	return &rec, nil
+}
+
+func (s *boltStore) Put(rec *Record) error {
+	if !ValidID(rec.ID) {
+		return errors.New("invalid response id")
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	sweep := s.ttl > 0 && time.Since(s.lastSweep) >= sweepInterval
+	if sweep {
+		s.lastSweep = time.Now()
+	}
+	s.mu.Unlock()
+
+	return s.update(func(tx *bolt.Tx) error {
+		if err := s.remove(tx, rec.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err := tx.Bucket(recordsBucket).Put([]byte(rec.ID), data); err != nil {
+			return err
+		}
+		if err := tx.Bucket(orderBucket).Put(orderKey(rec), []byte(rec.ID)); err != nil {
+			return err
+		}
+		if err := setRecordCount(tx, recordCount(tx)+1); err != nil {
+			return err
+		}
+
+		if sweep {
+			if err := s.removeExpired(tx); err != nil {
+				return err
+			}
+		}
+		return s.evict(tx)
+	})
+}
+
+func (s *boltStore) Delete(id string) error {
+	if !ValidID(id) {
+		return ErrNotFound
+	}
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return s.update(func(tx *bolt.Tx) error {
+		return s.remove(tx, id)
+	})
+}
+
+// remove deletes a record and its order entry
+func (s *boltStore) remove(tx *bolt.Tx, id string) error {
+	records := tx.Bucket(recordsBucket)
+	data := records.Get([]byte(id))
+	if data == nil {
+		return ErrNotFound
+	}
+
+	var rec Record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		logger.Warnf("[conversation] removing unreadable record %s: %v", id, err)
+	} else if err := tx.Bucket(orderBucket).Delete(orderKey(&rec)); err != nil {
+		return err
+	}
+	if err := records.Delete([]byte(id)); err != nil {
+		return err
+	}
+	return setRecordCount(tx, max(recordCount(tx)-1, 0))
+}
+
+// removeExpired deletes records older than ttl, oldest first
+func (s *boltStore) removeExpired(tx *bolt.Tx) error {
+	cutoff := time.Now().Add(-s.ttl).UnixNano()
+	var expiredIDs []string
+
+	c := tx.Bucket(orderBucket).Cursor()
+	for k, v := c.First(); k != nil && int64(binary.BigEndian.Uint64(k)) < cutoff; k, v = c.Next() {
+		expiredIDs = append(expiredIDs, string(v))
+	}
+	return s.removeAll(tx, expiredIDs)
+}
+
+// evict deletes the oldest records of a full store
+func (s *boltStore) evict(tx *bolt.Tx) error {
+	extra := recordCount(tx) - s.maxEntries
+	if s.maxEntries <= 0 || extra <= 0 {
+		return nil
+	}
+
+	oldest := make([]string, 0, extra)
+	c := tx.Bucket(orderBucket).Cursor()
+	for k, v := c.First(); k != nil && len(oldest) < extra; k, v = c.Next() {
+		oldest = append(oldest, string(v))
+	}
+	return s.removeAll(tx, oldest)
+}
+
+// removeAll deletes records collected while iterating, a cursor must not see its bucket change
+func (s *boltStore) removeAll(tx *bolt.Tx, ids []string) error {
+	for _, id := range ids {
+		if err := s.remove(tx, id); err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// orderKey sorts records by creation time, the id keeps keys of the same instant apart
+func orderKey(rec *Record) []byte {
+	key := make([]byte, 8, 8+len(rec.ID))
+	binary.BigEndian.PutUint64(key, uint64(rec.CreatedAt.UnixNano()))
+	return append(key, rec.ID...)
+}
+
+// recordCount returns the number of records, counted once when open creates the key
+func recordCount(tx *bolt.Tx) int {
+	v := tx.Bucket(metaBucket).Get(countKey)
+	if len(v) != 8 {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(v))
+}
+
+func setRecordCount(tx *bolt.Tx, n int) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(n))
+	return tx.Bucket(metaBucket).Put(countKey, v)
+}
@@ -0,0 +1,193 @@
+// Package conversation stores the history of transformed Responses API calls,
+// so stateless Chat Completions upstreams can serve previous_response_id
+package conversation
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/poixeai/proxify/infra/logger"
+)
+
+const (
+	BackendMemory = "memory"
+	BackendFile   = "file"
+
+	defaultPath       = ".proxify/responses.db"
+	defaultTTL        = 24 * time.Hour
+	defaultMaxEntries = 10000
+
+	// how often expired records are removed
+	sweepInterval = time.Minute
+)
+
+var ErrNotFound = errors.New("response not found")
+
+// response ids are stored as keys, and appear in URLs
+var idRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,128}$`)
+
+// Record is a stored response with the conversation that led to it
+type Record struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Route     string    `json:"route"`            // route path, records are only served on their route
+	KeyID     string    `json:"key_id,omitempty"` // API key that created the record, if any
+
+	// input and output messages in Chat Completions format, without instructions
+	Messages json.RawMessage `json:"messages"`
+	// Responses API response object returned to the client
+	Response json.RawMessage `json:"response"`
+}
+
+// Store keeps records until they expire, or until they are the oldest of a full store
+type Store interface {
+	Get(id string) (*Record, error)
+	Put(rec *Record) error
+	Delete(id string) error
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultStore Store
+)
+
+// Default returns the store configured by RESPONSES_STORE ("memory" or "file"),
+// RESPONSES_STORE_PATH, RESPONSES_STORE_TTL and RESPONSES_STORE_MAX_ENTRIES, created on first use
+func Default() Store {
+	defaultOnce.Do(func() {
+		ttl := defaultTTL
+		if v := os.Getenv("RESPONSES_STORE_TTL"); v != "" {
+			if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+				ttl = d
+			} else {
+				logger.Warnf("[conversation] invalid RESPONSES_STORE_TTL %q, using %s", v, defaultTTL)
+			}
+		}
+
+		maxEntries := defaultMaxEntries
+		if v := os.Getenv("RESPONSES_STORE_MAX_ENTRIES"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				maxEntries = n
+			} else {
+				logger.Warnf("[conversation] invalid RESPONSES_STORE_MAX_ENTRIES %q, using %d", v, defaultMaxEntries)
+			}
+		}
+
+		switch backend := strings.ToLower(os.Getenv("RESPONSES_STORE")); backend {
+		case BackendFile:
+			path := os.Getenv("RESPONSES_STORE_PATH")
+			if path == "" {
+				path = defaultPath
+			}
+			defaultStore = NewFileStore(path, ttl, maxEntries)
+		case "", BackendMemory:
+			defaultStore = NewMemoryStore(ttl, maxEntries)
+		default:
+			logger.Warnf("[conversation] unknown RESPONSES_STORE %q, using memory", backend)
+			defaultStore = NewMemoryStore(ttl, maxEntries)
+		}
+	})
+	return defaultStore
+}
+
+// ValidID reports whether id can be stored
+func ValidID(id string) bool {
+	return idRe.MatchString(id)
+}
+
+// expired reports whether rec is older than ttl, 0 never expires
+func expired(rec *Record, ttl time.Duration) bool {
+	return ttl > 0 && time.Since(rec.CreatedAt) > ttl
+}
+
+// memoryStore keeps records in memory, they are lost on restart
+type memoryStore struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu        sync.Mutex
+	records   map[string]*list.Element // values are *Record
+	order     *list.List               // oldest first, for eviction
+	lastSweep time.Time
+}
+
+// NewMemoryStore returns an in-memory store, ttl 0 keeps records forever and
+// maxEntries 0 keeps any number of records
+func NewMemoryStore(ttl time.Duration, maxEntries int) Store {
+	return &memoryStore{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		records:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (s *memoryStore) Get(id string) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	rec := e.Value.(*Record)
+	if expired(rec, s.ttl) {
+		s.remove(e)
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+func (s *memoryStore) Put(rec *Record) error {
+	if !ValidID(rec.ID) {
+		return errors.New("invalid response id")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.records[rec.ID]; ok {
+		s.remove(e)
+	}
+	s.records[rec.ID] = s.order.PushBack(rec)
+
+	// drop the oldest records of a full store
+	for s.maxEntries > 0 && s.order.Len() > s.maxEntries {
+		s.remove(s.order.Front())
+	}
+
+	if time.Since(s.lastSweep) > sweepInterval {
+		s.lastSweep = time.Now()
+		for _, e := range s.records {
+			if expired(e.Value.(*Record), s.ttl) {
+				s.remove(e)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.records[id]
+	if !ok {
+		return ErrNotFound
+	}
+	s.remove(e)
+	return nil
+}
+
+// remove drops a record, s.mu must be held
+func (s *memoryStore) remove(e *list.Element) {
+	delete(s.records, e.Value.(*Record).ID)
+	s.order.Remove(e)
+}
@@ -0,0 +1,160 @@
+package conversation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/poixeai/proxify/infra/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.ZapLog = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
+
+func record(i int) *Record {
+	return &Record{
+		ID:        fmt.Sprintf("resp_%d", i),
+		CreatedAt: time.Now(),
+		Route:     "/openai",
+		Messages:  []byte(`[]`),
+		Response:  []byte(`{}`),
+	}
+}
+
+func TestMemoryStoreEvictsOldest(t *testing.T) {
+	s := NewMemoryStore(time.Hour, 3)
+	for i := 1; i <= 5; i++ {
+		if err := s.Put(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= 5; i++ {
+		_, err := s.Get(record(i).ID)
+		if kept := i > 2; kept != (err == nil) {
+			t.Errorf("resp_%d: kept = %v, err = %v", i, kept, err)
+		}
+	}
+
+	// deleting frees a slot, the remaining records stay
+	if err := s.Delete("resp_3"); err != nil {
+		t.Fatal(err)
+	}
+	s.Put(record(6))
+	for _, id := range []string{"resp_4", "resp_5", "resp_6"} {
+		if _, err := s.Get(id); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+}
+
+func TestMemoryStoreExpires(t *testing.T) {
+	s := NewMemoryStore(time.Minute, 0)
+	old := record(1)
+	old.CreatedAt = time.Now().Add(-2 * time.Minute)
+	s.Put(old)
+
+	if _, err := s.Get(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired record: err = %v", err)
+	}
+}
+
+func TestFileStoreEvictsOldest(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "responses.db"), 0, 3)
+
+	base := time.Now().Add(-time.Hour)
+	for i := 1; i <= 5; i++ {
+		rec := record(i)
+		rec.CreatedAt = base.Add(time.Duration(i) * time.Second)
+		if err := s.Put(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 1; i <= 5; i++ {
+		_, err := s.Get(record(i).ID)
+		if kept := i > 2; kept != (err == nil) {
+			t.Errorf("resp_%d: kept = %v, err = %v", i, kept, err)
+		}
+	}
+
+	// deleting frees a slot, replacing a record takes none
+	if err := s.Delete("resp_3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("resp_3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second delete: err = %v", err)
+	}
+	s.Put(record(5))
+	s.Put(record(6))
+	for _, id := range []string{"resp_4", "resp_5", "resp_6"} {
+		if _, err := s.Get(id); err != nil {
+			t.Errorf("%s: %v", id, err)
+		}
+	}
+}
+
+func TestFileStoreKeepsRecordsAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "responses.db")
+	for i := 1; i <= 5; i++ {
+		NewFileStore(path, 0, 0).Put(record(i))
+	}
+
+	// a restarted store with a smaller cap trims the records of the earlier run
+	s := NewFileStore(path, 0, 3)
+	if err := s.Put(record(6)); err != nil {
+		t.Fatal(err)
+	}
+	kept := 0
+	for i := 1; i <= 6; i++ {
+		if _, err := s.Get(record(i).ID); err == nil {
+			kept++
+		}
+	}
+	if kept != 3 {
+		t.Errorf("%d records kept, want 3", kept)
+	}
+	if rec, err := s.Get("resp_6"); err != nil || rec.Route != "/openai" {
+		t.Errorf("newest record: %v, %v", rec, err)
+	}
+}
+
+func TestFileStoreExpires(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "responses.db"), time.Minute, 0)
+	old := record(1)
+	old.CreatedAt = time.Now().Add(-2 * time.Minute)
+	s.Put(old)
+	s.Put(record(2))
+
+	if _, err := s.Get(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expired record: err = %v", err)
+	}
+	if _, err := s.Get("resp_2"); err != nil {
+		t.Errorf("current record: %v", err)
+	}
+	if _, err := s.Get("resp_3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing record: err = %v", err)
+	}
+}
+
+func TestFileStoreMissingDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "responses.db")
+	s := NewFileStore(path, 0, 0)
+
+	// reads do not create the database
+	if _, err := s.Get("resp_1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get: err = %v", err)
+	}
+	if err := s.Delete("resp_1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete: err = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("database was created: %v", err)
+	}
+}
@@ -10,4 +10,8 @@ const (
 	TargetURL        = "target_url"          // like https://api.openai.com/v1/chat/completions
 	Proxified        = "proxified"           // bool, whether the request has been proxified
 	RouteConfig      = "route_config"
+	RequestedModel   = "requested_model"   // model asked for by the client, set when it was rewritten
+	APIKey           = "api_key"           // *keys.Key, set when the request used a stored API key
+	AdminUser        = "admin_user"        // *config.AdminUser, set on authenticated admin requests
+	ResponsesHistory = "responses_history" // []ChatMessage of a stored Responses request, without instructions
 )
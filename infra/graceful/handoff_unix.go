@@ -0,0 +1,67 @@
+//go:build !windows
+
+package graceful
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var handoffSignals = []os.Signal{syscall.SIGUSR2}
+
+// handoff starts a copy of the current binary that inherits the listening socket.
+// It returns once the child reports that it is serving.
+func handoff(ln net.Listener) error {
+	fl, ok := ln.(interface{ File() (*os.File, error) })
+	if !ok {
+		return errors.New("listener does not support fd export")
+	}
+
+	lnFile, err := fl.File()
+	if err != nil {
+		return fmt.Errorf("export listener fd: %w", err)
+	}
+	defer lnFile.Close()
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("create ready pipe: %w", err)
+	}
+	defer readyR.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		readyW.Close()
+		return fmt.Errorf("resolve executable: %w", err)
+	}
+
+	// ExtraFiles start at fd 3
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{lnFile, readyW}
+	cmd.Env = append(os.Environ(), envListenerFD+"=3", envReadyFD+"=4")
+
+	err = cmd.Start()
+	readyW.Close() // only the child holds the write end now
+	if err != nil {
+		return fmt.Errorf("start child: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	if err := waitReady(readyR, exited); err != nil {
+		cmd.Process.Kill()
+		return err
+	}
+
+	return nil
+}
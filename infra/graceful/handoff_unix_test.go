@@ -0,0 +1,99 @@
+//go:build !windows
+
+package graceful
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/poixeai/proxify/infra/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.ZapLog = zap.NewNop().Sugar()
+
+	// started by TestHandoff: serve on the inherited listener
+	if os.Getenv(envListenerFD) != "" {
+		os.Exit(runChild())
+	}
+	os.Exit(m.Run())
+}
+
+// runChild answers one request with "child" and exits
+func runChild() int {
+	ln, err := Listen("")
+	if err != nil {
+		return 1
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+		io.WriteString(w, "child")
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			os.Exit(0)
+		}()
+	})}
+	Serve(srv, ln)
+	return 0
+}
+
+func get(t *testing.T, addr string) string {
+	t.Helper()
+	client := &http.Client{Timeout: 5 * time.Second, Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get("http://" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestHandoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "parent")
+	})}
+	go srv.Serve(ln)
+	if got := get(t, addr); got != "parent" {
+		t.Fatalf("before handoff: %q", got)
+	}
+
+	if err := handoff(ln); err != nil {
+		t.Fatal(err)
+	}
+
+	// the parent stops accepting, the socket stays open in the child
+	srv.Close()
+	if got := get(t, addr); got != "child" {
+		t.Errorf("after handoff: %q", got)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	r, w, _ := os.Pipe()
+	w.Write([]byte{1})
+	if err := waitReady(r, make(chan error)); err != nil {
+		t.Errorf("ready child: %v", err)
+	}
+	r.Close()
+	w.Close()
+
+	// a child that dies closes the pipe without writing
+	r, w, _ = os.Pipe()
+	w.Close()
+	if err := waitReady(r, make(chan error)); err == nil {
+		t.Error("closed pipe reported as ready")
+	}
+	r.Close()
+}
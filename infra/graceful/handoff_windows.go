@@ -0,0 +1,16 @@
+//go:build windows
+
+package graceful
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// socket handoff is not supported on windows, only graceful shutdown
+var handoffSignals = []os.Signal{}
+
+func handoff(ln net.Listener) error {
+	return errors.New("socket handoff is not supported on windows")
+}
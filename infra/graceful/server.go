@@ -0,0 +1,170 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/poixeai/proxify/infra/logger"
+)
+
+const (
+	// envListenerFD tells a child process which fd holds the inherited listener
+	envListenerFD = "PROXIFY_LISTENER_FD"
+	// envReadyFD tells a child process which fd to close once it is serving
+	envReadyFD = "PROXIFY_READY_FD"
+
+	defaultShutdownTimeout = 5 * time.Minute
+	handoffReadyTimeout    = 30 * time.Second
+)
+
+// Listen returns the listener inherited from a parent process during a handoff,
+// or opens a new one on addr.
+func Listen(addr string) (net.Listener, error) {
+	fdStr := os.Getenv(envListenerFD)
+	if fdStr == "" {
+		return net.Listen("tcp", addr)
+	}
+
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return nil, err
+	}
+
+	f := os.NewFile(uintptr(fd), "proxify-listener")
+	defer f.Close() // net.FileListener dups the fd
+
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Infof("[graceful] inherited listener on %s from parent process", ln.Addr())
+	return ln, nil
+}
+
+// Serve serves srv on ln until a shutdown signal is received.
+// SIGINT/SIGTERM shut down gracefully, SIGUSR2 hands the listener to a new process first.
+// In both cases in-flight requests (including open streams) are allowed to finish.
+func Serve(srv *http.Server, ln net.Listener) error {
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.Serve(ln)
+	}()
+
+	// tell the parent (if any) that we are serving
+	notifyReady()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, shutdownSignals...)
+	signal.Notify(sigCh, handoffSignals...)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case err := <-errCh:
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+			return err
+
+		case sig := <-sigCh:
+			if isHandoffSignal(sig) {
+				logger.Infof("[graceful] received %v, starting new process", sig)
+				if err := handoff(ln); err != nil {
+					logger.Errorf("[graceful] handoff failed, keep serving: %v", err)
+					continue
+				}
+				logger.Infof("[graceful] new process is ready, draining connections")
+			} else {
+				logger.Infof("[graceful] received %v, shutting down", sig)
+			}
+			return shutdown(srv)
+		}
+	}
+}
+
+// shutdown stops accepting new connections and waits for active ones
+func shutdown(srv *http.Server) error {
+	timeout := defaultShutdownTimeout
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			timeout = d
+		} else {
+			logger.Warnf("[graceful] invalid SHUTDOWN_TIMEOUT %q, using %v", v, timeout)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Warnf("[graceful] shutdown timed out after %v: %v", timeout, err)
+		return err
+	}
+
+	logger.Infof("[graceful] all connections drained, bye")
+	return nil
+}
+
+// notifyReady closes the ready pipe passed by the parent process
+func notifyReady() {
+	fdStr := os.Getenv(envReadyFD)
+	if fdStr == "" {
+		return
+	}
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "proxify-ready")
+	f.Write([]byte{1})
+	f.Close()
+
+	// children of this process must not see our parent's descriptors
+	os.Unsetenv(envListenerFD)
+	os.Unsetenv(envReadyFD)
+}
+
+// waitReady waits until the child writes to the ready pipe or exits
+func waitReady(ready *os.File, exited <-chan error) error {
+	done := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		// a crashed child closes the pipe without writing, which reads as io.EOF
+		_, err := ready.Read(buf)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return errors.New("child exited before becoming ready")
+		}
+		return nil
+	case err := <-exited:
+		if err == nil {
+			err = errors.New("child exited before becoming ready")
+		}
+		return err
+	case <-time.After(handoffReadyTimeout):
+		return errors.New("timed out waiting for child to become ready")
+	}
+}
+
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+func isHandoffSignal(sig os.Signal) bool {
+	for _, s := range handoffSignals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const diffContext = 3
+
+// Diff returns a unified diff of the routes of two revisions
+func Diff(from, to *Revision) string {
+	a := revisionLines(from)
+	b := revisionLines(to)
+
+	header := fmt.Sprintf("--- revision %d (%s, %s)\n+++ revision %d (%s, %s)\n",
+		from.ID, from.Source, from.CreatedAt.Format("2006-01-02 15:04:05"),
+		to.ID, to.Source, to.CreatedAt.Format("2006-01-02 15:04:05"))
+
+	return header + unifiedDiff(a, b)
+}
+
+func revisionLines(rev *Revision) []string {
+	data, _ := json.MarshalIndent(rev.Routes, "", "  ")
+	return strings.Split(string(data), "\n")
+}
+
+type diffOp struct {
+	kind byte // ' ', '-', '+'
+	text string
+}
+
+// lineDiff computes a line diff of a and b using the longest common subsequence
+func lineDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// unifiedDiff renders changed lines with diffContext lines of context around them
+func unifiedDiff(a, b []string) string {
+	ops := lineDiff(a, b)
+
+	// mark lines to print
+	show := make([]bool, len(ops))
+	changed := false
+	for i, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		changed = true
+		for k := max(0, i-diffContext); k <= min(len(ops)-1, i+diffContext); k++ {
+			show[k] = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb strings.Builder
+	aLine, bLine := 1, 1
+	for i := 0; i < len(ops); {
+		if !show[i] {
+			if ops[i].kind != '+' {
+				aLine++
+			}
+			if ops[i].kind != '-' {
+				bLine++
+			}
+			i++
+			continue
+		}
+
+		// one hunk
+		end := i
+		for end < len(ops) && show[end] {
+			end++
+		}
+		aCount, bCount := 0, 0
+		for _, op := range ops[i:end] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+		for _, op := range ops[i:end] {
+			sb.WriteByte(op.kind)
+			sb.WriteString(op.text)
+			sb.WriteByte('\n')
+		}
+		aLine += aCount
+		bLine += bCount
+		i = end
+	}
+	return sb.String()
+}
@@ -0,0 +1,293 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/poixeai/proxify/infra/config"
+)
+
+// revision sources
+const (
+	SourceStartup  = "startup"
+	SourceFile     = "file"
+	SourceAdmin    = "admin"
+	SourceRollback = "rollback"
+)
+
+const (
+	defaultDir   = ".proxify/history"
+	defaultLimit = 50
+	fileExt      = ".json"
+)
+
+var ErrNotFound = errors.New("revision not found")
+
+// ErrNoMainRoutes is returned when a rollback would write an empty routes file
+var ErrNoMainRoutes = errors.New("no routes to roll back")
+
+// Route is a route as stored in history: as written in the config, plus its file
+type Route struct {
+	config.Route
+	Source string `json:"source,omitempty"`
+}
+
+// Revision is one applied routes config
+type Revision struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Source    string    `json:"source"`
+	Routes    []Route   `json:"routes"`
+}
+
+// Summary is a revision without its routes, for listings
+type Summary struct {
+	ID         int       `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Source     string    `json:"source"`
+	RouteCount int       `json:"route_count"`
+}
+
+// Store keeps the last Limit revisions as numbered files in Dir
+type Store struct {
+	Dir   string
+	Limit int
+
+	mu sync.Mutex
+}
+
+// Default returns the store configured by ROUTES_HISTORY_DIR and ROUTES_HISTORY_LIMIT
+func Default() *Store {
+	s := &Store{Dir: defaultDir, Limit: defaultLimit}
+	if dir := os.Getenv("ROUTES_HISTORY_DIR"); dir != "" {
+		s.Dir = dir
+	}
+	if v := os.Getenv("ROUTES_HISTORY_LIMIT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			s.Limit = n
+		}
+	}
+	return s
+}
+
+// Enabled reports whether history is kept, ROUTES_HISTORY_LIMIT=0 disables it
+func (s *Store) Enabled() bool {
+	return s.Limit > 0
+}
+
+// Record stores cfg as a new revision, unless it equals the latest one.
+// It returns the stored revision, or the latest one when nothing changed.
+func (s *Store) Record(cfg *config.RoutesConfig, source string) (*Revision, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	routes := make([]Route, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes = append(routes, Route{Route: r.Public(), Source: r.Source})
+	}
+
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) > 0 {
+		latest, err := s.read(ids[len(ids)-1])
+		if err == nil && sameRoutes(latest.Routes, routes) {
+			return latest, nil
+		}
+	}
+
+	rev := &Revision{
+		CreatedAt: time.Now().UTC(),
+		Source:    source,
+		Routes:    routes,
+	}
+	if err := s.write(rev, ids); err != nil {
+		return nil, err
+	}
+
+	s.prune()
+	return rev, nil
+}
+
+// List returns summaries of all revisions, newest first
+func (s *Store) List() ([]Summary, error) {
+	ids, err := s.ids()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Summary, 0, len(ids))
+	for i := len(ids) - 1; i >= 0; i-- {
+		rev, err := s.read(ids[i])
+		if err != nil {
+			continue
+		}
+		out = append(out, Summary{
+			ID:         rev.ID,
+			CreatedAt:  rev.CreatedAt,
+			Source:     rev.Source,
+			RouteCount: len(rev.Routes),
+		})
+	}
+	return out, nil
+}
+
+// Get returns the revision with the given id
+func (s *Store) Get(id int) (*Revision, error) {
+	rev, err := s.read(id)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return rev, err
+}
+
+// MainRoutes returns the routes of rev that belong to file (or have no file),
+// i.e. the content to write back to the main routes file on rollback.
+// A revision without such routes is an error, e.g. when it was recorded for a
+// routes file at another path, so a rollback never wipes the routes file.
+func (rev *Revision) MainRoutes(file string) ([]config.Route, error) {
+	var out []config.Route
+	others := make(map[string]bool)
+	for _, r := range rev.Routes {
+		if r.Source == "" || samePath(r.Source, file) {
+			out = append(out, r.Route)
+		} else {
+			others[r.Source] = true
+		}
+	}
+
+	if len(out) > 0 {
+		return out, nil
+	}
+	if len(others) == 0 {
+		return nil, fmt.Errorf("%w: revision %d is empty", ErrNoMainRoutes, rev.ID)
+	}
+
+	sources := make([]string, 0, len(others))
+	for src := range others {
+		sources = append(sources, src)
+	}
+	sort.Strings(sources)
+	return nil, fmt.Errorf("%w: revision %d has no routes from %s, only from %s",
+		ErrNoMainRoutes, rev.ID, file, strings.Join(sources, ", "))
+}
+
+// samePath compares paths after making them absolute, "./routes.json" == "routes.json"
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return absA == absB
+}
+
+// ids returns the existing revision ids in ascending order
+func (s *Store) ids() ([]int, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ids []int
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimSuffix(name, fileExt)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (s *Store) path(id int) string {
+	return filepath.Join(s.Dir, fmt.Sprintf("%06d%s", id, fileExt))
+}
+
+func (s *Store) read(id int) (*Revision, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var rev Revision
+	if err := json.Unmarshal(data, &rev); err != nil {
+		return nil, fmt.Errorf("revision %d: %w", id, err)
+	}
+	rev.ID = id
+	return &rev, nil
+}
+
+// write stores rev under the next free id. O_EXCL keeps ids unique when the
+// server and the CLI record at the same time.
+func (s *Store) write(rev *Revision, ids []int) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return err
+	}
+
+	next := 1
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		rev.ID = next
+		data, err := json.MarshalIndent(rev, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(s.path(next), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			next++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	return errors.New("could not allocate a revision id")
+}
+
+// prune removes the oldest revisions beyond Limit
+func (s *Store) prune() {
+	ids, err := s.ids()
+	if err != nil || len(ids) <= s.Limit {
+		return
+	}
+	for _, id := range ids[:len(ids)-s.Limit] {
+		os.Remove(s.path(id))
+	}
+}
+
+func sameRoutes(a, b []Route) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ja, jb)
+}
@@ -0,0 +1,147 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/poixeai/proxify/infra/config"
+)
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want string
+	}{
+		{name: "equal", a: []string{"a", "b"}, b: []string{"a", "b"}, want: " a, b"},
+		{name: "insert", a: []string{"a", "c"}, b: []string{"a", "b", "c"}, want: " a,+b, c"},
+		{name: "delete", a: []string{"a", "b", "c"}, b: []string{"a", "c"}, want: " a,-b, c"},
+		{name: "replace", a: []string{"a", "b", "c"}, b: []string{"a", "x", "c"}, want: " a,-b,+x, c"},
+		{name: "from empty", a: nil, b: []string{"a"}, want: "+a"},
+		{name: "to empty", a: []string{"a"}, b: nil, want: "-a"},
+		{name: "keeps the longest common part", a: []string{"x", "a", "b", "c"}, b: []string{"a", "b", "c", "y"}, want: "-x, a, b, c,+y"},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, op := range lineDiff(tt.a, tt.b) {
+			got = append(got, string(op.kind)+op.text)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s: lineDiff = %q, want %q", tt.name, strings.Join(got, ","), tt.want)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	if got := unifiedDiff([]string{"a"}, []string{"a"}); got != "" {
+		t.Errorf("diff of equal input = %q", got)
+	}
+
+	// the changes at lines 2 and 10 are too far apart to share a hunk
+	var a []string
+	for i := 1; i <= 12; i++ {
+		a = append(a, string(rune('a'+i-1)))
+	}
+	b := append([]string(nil), a...)
+	b[1] = "B"
+	b[9] = "J"
+
+	want := "@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -7,6 +7,6 @@\n g\n h\n i\n-j\n+J\n k\n l\n"
+	if got := unifiedDiff(a, b); got != want {
+		t.Errorf("unifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func testConfig(targets ...string) *config.RoutesConfig {
+	cfg := &config.RoutesConfig{}
+	for i, target := range targets {
+		cfg.Routes = append(cfg.Routes, config.Route{Path: "/r" + string(rune('a'+i)), Target: target})
+	}
+	return cfg
+}
+
+func TestStoreRecord(t *testing.T) {
+	s := &Store{Dir: t.TempDir(), Limit: 2}
+
+	first, err := s.Record(testConfig("https://a.example.com"), SourceStartup)
+	if err != nil || first.ID != 1 {
+		t.Fatalf("Record = %+v, %v", first, err)
+	}
+
+	// an unchanged config is not recorded again
+	same, err := s.Record(testConfig("https://a.example.com"), SourceFile)
+	if err != nil || same.ID != 1 {
+		t.Fatalf("Record of unchanged config = %+v, %v", same, err)
+	}
+
+	s.Record(testConfig("https://b.example.com"), SourceAdmin)
+	s.Record(testConfig("https://c.example.com"), SourceFile)
+
+	list, err := s.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 2 || list[0].Source != SourceFile {
+		t.Errorf("List = %+v, want revisions 3 and 2, newest first", list)
+	}
+	if _, err := s.Get(1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(1) after pruning: err = %v", err)
+	}
+
+	rev, err := s.Get(2)
+	if err != nil || rev.Routes[0].Target != "https://b.example.com" {
+		t.Errorf("Get(2) = %+v, %v", rev, err)
+	}
+}
+
+func TestStoreDisabled(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "history")
+	s := &Store{Dir: dir, Limit: 0}
+	if rev, err := s.Record(testConfig("https://a.example.com"), SourceStartup); rev != nil || err != nil {
+		t.Errorf("Record = %+v, %v, want nothing recorded", rev, err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("history dir created while disabled: %v", err)
+	}
+}
+
+func TestMainRoutes(t *testing.T) {
+	rev := &Revision{ID: 4, Routes: []Route{
+		{Route: config.Route{Path: "/a"}, Source: "routes.json"},
+		{Route: config.Route{Path: "/b"}},
+		{Route: config.Route{Path: "/c"}, Source: "routes.d/c.yaml"},
+	}}
+
+	routes, err := rev.MainRoutes("./routes.json")
+	if err != nil || len(routes) != 2 || routes[0].Path != "/a" || routes[1].Path != "/b" {
+		t.Errorf("MainRoutes = %+v, %v", routes, err)
+	}
+
+	other := &Revision{ID: 5, Routes: []Route{{Route: config.Route{Path: "/a"}, Source: "old.json"}}}
+	if _, err := other.MainRoutes("routes.json"); !errors.Is(err, ErrNoMainRoutes) {
+		t.Errorf("MainRoutes of another file: err = %v", err)
+	}
+	if _, err := (&Revision{ID: 6}).MainRoutes("routes.json"); !errors.Is(err, ErrNoMainRoutes) {
+		t.Errorf("MainRoutes of an empty revision: err = %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	s := &Store{Dir: t.TempDir(), Limit: 10}
+	from, _ := s.Record(testConfig("https://a.example.com"), SourceStartup)
+	to, _ := s.Record(testConfig("https://b.example.com"), SourceAdmin)
+
+	out := Diff(from, to)
+	if !strings.Contains(out, "--- revision 1 (startup") || !strings.Contains(out, "+++ revision 2 (admin") {
+		t.Errorf("Diff header:\n%s", out)
+	}
+	if !strings.Contains(out, `-    "target": "https://a.example.com"`) ||
+		!strings.Contains(out, `+    "target": "https://b.example.com"`) {
+		t.Errorf("Diff body:\n%s", out)
+	}
+}
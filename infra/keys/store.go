@@ -0,0 +1,346 @@
+package keys
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/poixeai/proxify/util"
+)
+
+const (
+	keyPrefix = "pk-"
+	keyLength = 40
+
+	// how often Lookup checks the file for changes made by the CLI
+	reloadInterval = time.Second
+)
+
+var ErrNotFound = errors.New("key not found")
+
+// Key is a stored API key. Only the sha256 hash of the secret is persisted.
+type Key struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Hash      string     `json:"hash"`
+	Hint      string     `json:"hint"` // first characters of the secret, for humans
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+
+	ModelPolicy
+}
+
+// ModelPolicy restricts the models a key may use, with glob patterns like "gpt-4o*"
+type ModelPolicy struct {
+	ModelsAllow []string `json:"models_allow,omitempty"`
+	ModelsDeny  []string `json:"models_deny,omitempty"`
+}
+
+// Validate checks that all entries are valid glob patterns
+func (p ModelPolicy) Validate() error {
+	for _, patterns := range [][]string{p.ModelsAllow, p.ModelsDeny} {
+		for _, m := range patterns {
+			if _, err := path.Match(m, ""); err != nil || strings.TrimSpace(m) == "" {
+				return fmt.Errorf("invalid model pattern %q", m)
+			}
+		}
+	}
+	return nil
+}
+
+// ModelAllowed reports whether the policy permits a model, given by one or more names,
+// deny wins over allow
+func (p ModelPolicy) ModelAllowed(names ...string) bool {
+	return util.ModelAllowed(p.ModelsAllow, p.ModelsDeny, names...)
+}
+
+func (k *Key) Active() bool {
+	return k.RevokedAt == nil
+}
+
+type fileData struct {
+	Keys []*Key `json:"keys"`
+}
+
+// Store is a file-backed key store, safe for concurrent use.
+// Changes written by other processes (e.g. `proxify keys create`) are picked up automatically.
+type Store struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    []*Key
+	modTime time.Time
+
+	lastCheck atomic.Int64 // unix nanoseconds of the last file check
+}
+
+// Open loads the store at path. A missing file is treated as an empty store.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DefaultPath returns the key file path from AUTH_KEYS_FILE, or "keys.json"
+func DefaultPath(getenv func(string) string) string {
+	if p := getenv("AUTH_KEYS_FILE"); p != "" {
+		return p
+	}
+	return "keys.json"
+}
+
+func (s *Store) load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			s.keys = nil
+			s.modTime = time.Time{}
+			return nil
+		}
+		return err
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	var fd fileData
+	if err := json.Unmarshal(data, &fd); err != nil {
+		return fmt.Errorf("parse %s: %w", s.path, err)
+	}
+
+	s.keys = fd.Keys
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// refresh reloads the file if it changed on disk, at most once per reloadInterval.
+// Between checks it takes no lock, and only one caller performs each check.
+func (s *Store) refresh() {
+	now := time.Now().UnixNano()
+	last := s.lastCheck.Load()
+	if now-last < int64(reloadInterval) || !s.lastCheck.CompareAndSwap(last, now) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	switch {
+	case err != nil && os.IsNotExist(err):
+		if !s.modTime.IsZero() {
+			s.keys = nil
+			s.modTime = time.Time{}
+		}
+	case err != nil:
+		return
+	case !info.ModTime().Equal(s.modTime):
+		if err := s.load(); err != nil {
+			// keep serving the previous keys
+			return
+		}
+	}
+}
+
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(fileData{Keys: s.keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := util.WriteFileAtomic(s.path, data, 0600); err != nil {
+		return err
+	}
+
+	if info, err := os.Stat(s.path); err == nil {
+		s.modTime = info.ModTime()
+	}
+	return nil
+}
+
+// HasActive reports whether at least one non-revoked key exists
+func (s *Store) HasActive() bool {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.Active() {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the active key matching secret
+func (s *Store) Lookup(secret string) (*Key, bool) {
+	if secret == "" {
+		return nil, false
+	}
+	s.refresh()
+
+	hash := hashSecret(secret)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.Active() && subtle.ConstantTimeCompare([]byte(k.Hash), []byte(hash)) == 1 {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+// List returns a copy of all keys, including revoked ones
+func (s *Store) List() []Key {
+	s.refresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Key, 0, len(s.keys))
+	for _, k := range s.keys {
+		out = append(out, *k)
+	}
+	return out
+}
+
+// Create generates a new key and returns it with its plaintext secret.
+// The secret is not stored and cannot be recovered later.
+func (s *Store) Create(name string, policy ModelPolicy) (*Key, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, "", err
+	}
+
+	k, secret, err := s.add(name, policy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := s.save(); err != nil {
+		return nil, "", err
+	}
+
+	copied := *k
+	return &copied, secret, nil
+}
+
+// Rotate revokes the key with the given id and creates a replacement with the same name and policy
+func (s *Store) Rotate(id string) (*Key, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, "", err
+	}
+
+	var old *Key
+	for _, k := range s.keys {
+		if k.ID == id && k.Active() {
+			old = k
+			break
+		}
+	}
+	if old == nil {
+		return nil, "", ErrNotFound
+	}
+
+	k, secret, err := s.add(old.Name, old.ModelPolicy)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now().UTC()
+	old.RevokedAt = &now
+
+	if err := s.save(); err != nil {
+		return nil, "", err
+	}
+
+	copied := *k
+	return &copied, secret, nil
+}
+
+// add appends a freshly generated key, the caller holds the lock and saves
+func (s *Store) add(name string, policy ModelPolicy) (*Key, string, error) {
+	secret, err := randomString(keyLength)
+	if err != nil {
+		return nil, "", err
+	}
+	secret = keyPrefix + secret
+
+	id, err := randomString(8)
+	if err != nil {
+		return nil, "", err
+	}
+
+	k := &Key{
+		ID:        "key_" + id,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Hint:      secret[:len(keyPrefix)+4] + "...",
+		CreatedAt: time.Now().UTC(),
+
+		ModelPolicy: policy,
+	}
+	s.keys = append(s.keys, k)
+	return k, secret, nil
+}
+
+// Revoke marks the key with the given id as revoked
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+
+	for _, k := range s.keys {
+		if k.ID != id {
+			continue
+		}
+		if !k.Active() {
+			return nil
+		}
+		now := time.Now().UTC()
+		k.RevokedAt = &now
+		return s.save()
+	}
+	return ErrNotFound
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomString returns a cryptographically random alphanumeric string
+func randomString(n int) (string, error) {
+	b := make([]rune, n)
+	max := big.NewInt(int64(len(util.RandAlphanumeric)))
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = util.RandAlphanumeric[idx.Int64()]
+	}
+	return string(b), nil
+}
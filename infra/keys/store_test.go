@@ -0,0 +1,125 @@
+package keys
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateLookupRevoke(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.HasActive() {
+		t.Fatal("empty store has active keys")
+	}
+
+	key, secret, err := s.Create("ci", ModelPolicy{ModelsAllow: []string{"gpt-4o*"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, keyPrefix) || len(secret) != len(keyPrefix)+keyLength {
+		t.Errorf("secret = %q", secret)
+	}
+	if key.Hint != secret[:len(keyPrefix)+4]+"..." {
+		t.Errorf("hint = %q", key.Hint)
+	}
+
+	// only the hash is persisted
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), secret) || !strings.Contains(string(data), hashSecret(secret)) {
+		t.Errorf("key file content:\n%s", data)
+	}
+
+	found, ok := s.Lookup(secret)
+	if !ok || found.ID != key.ID || !found.ModelAllowed("gpt-4o-mini") || found.ModelAllowed("o3") {
+		t.Errorf("Lookup = %+v, %v", found, ok)
+	}
+	for _, wrong := range []string{"", secret + "x", secret[:len(secret)-1], hashSecret(secret)} {
+		if _, ok := s.Lookup(wrong); ok {
+			t.Errorf("Lookup(%q) matched", wrong)
+		}
+	}
+
+	if err := s.Revoke(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Lookup(secret); ok {
+		t.Error("revoked key still matches")
+	}
+	if s.HasActive() {
+		t.Error("HasActive after revoking the only key")
+	}
+	if err := s.Revoke(key.ID); err != nil {
+		t.Errorf("revoking twice: %v", err)
+	}
+	if err := s.Revoke("key_missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Revoke of unknown key: err = %v", err)
+	}
+
+	list := s.List()
+	if len(list) != 1 || list[0].Active() {
+		t.Errorf("List = %+v", list)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	s, _ := Open(filepath.Join(t.TempDir(), "keys.json"))
+	old, oldSecret, _ := s.Create("ci", ModelPolicy{ModelsDeny: []string{"o1*"}})
+
+	key, secret, err := s.Rotate(old.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.ID == old.ID || key.Name != "ci" || len(key.ModelsDeny) != 1 {
+		t.Errorf("rotated key = %+v", key)
+	}
+	if _, ok := s.Lookup(oldSecret); ok {
+		t.Error("old secret still matches")
+	}
+	if found, ok := s.Lookup(secret); !ok || found.ID != key.ID {
+		t.Errorf("Lookup of new secret = %+v, %v", found, ok)
+	}
+	if _, _, err := s.Rotate(old.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("rotating a revoked key: err = %v", err)
+	}
+}
+
+func TestReloadsChangesOfOtherProcesses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	server, _ := Open(path)
+	cli, _ := Open(path)
+
+	_, secret, err := cli.Create("ci", ModelPolicy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the file is checked at most once per reloadInterval
+	server.lastCheck.Store(0)
+	if _, ok := server.Lookup(secret); !ok {
+		t.Fatal("key created by another store not found")
+	}
+
+	os.Remove(path)
+	server.lastCheck.Store(0)
+	if server.HasActive() {
+		t.Error("keys kept after the file was removed")
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	env := map[string]string{}
+	getenv := func(k string) string { return env[k] }
+	if got := DefaultPath(getenv); got != "keys.json" {
+		t.Errorf("DefaultPath = %q", got)
+	}
+	env["AUTH_KEYS_FILE"] = "/etc/proxify/keys.json"
+	if got := DefaultPath(getenv); got != "/etc/proxify/keys.json" {
+		t.Errorf("DefaultPath = %q", got)
+	}
+}
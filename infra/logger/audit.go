@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"path/filepath"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// audit logger, JSON lines in <LogDir>/audit.log, independent of the log level
+var auditLog *zap.SugaredLogger
+
+func initAudit(cfg *LoggerConfig) {
+	core := zapcore.NewCore(
+		getFileEncoder(cfg.TimeZone),
+		getLumberjackWriter(filepath.Join(cfg.LogDir, "audit.log"), cfg),
+		zapcore.InfoLevel,
+	)
+	auditLog = zap.New(core).Sugar()
+}
+
+// Audit records a management action, kv are key/value pairs
+func Audit(msg string, kv ...interface{}) {
+	if auditLog == nil {
+		return
+	}
+	auditLog.Infow(msg, kv...)
+}
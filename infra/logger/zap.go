@@ -27,6 +27,9 @@ type LoggerConfig struct {
 // global logger
 var ZapLog *zap.SugaredLogger
 
+// global level, shared by all cores so it can be changed at runtime
+var atomicLevel = zap.NewAtomicLevel()
+
 // Init initializes the global logger
 func Init(config *LoggerConfig) {
 	if config == nil {
@@ -34,6 +37,7 @@ func Init(config *LoggerConfig) {
 	}
 	logger := newLogger(config)
 	ZapLog = logger.WithOptions(zap.AddCallerSkip(1)).Sugar()
+	initAudit(config)
 	defer ZapLog.Sync()
 }
 
@@ -55,10 +59,11 @@ func defaultLoggerConfig() *LoggerConfig {
 
 // construct a new zap.Logger based on the config
 func newLogger(cfg *LoggerConfig) *zap.Logger {
-	level := zapcore.InfoLevel
+	atomicLevel.SetLevel(zapcore.InfoLevel)
 	if cfg.Mode == "debug" {
-		level = zapcore.DebugLevel
+		atomicLevel.SetLevel(zapcore.DebugLevel)
 	}
+	level := atomicLevel
 
 	// create log directory if not exists
 	if err := os.MkdirAll(cfg.LogDir, 0755); err != nil {
@@ -139,6 +144,16 @@ func Level() zapcore.Level {
 	return ZapLog.Level()
 }
 
+// SetLevel changes the log level at runtime, e.g. "debug", "info", "warn"
+func SetLevel(level string) error {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	atomicLevel.SetLevel(lvl)
+	return nil
+}
+
 // common log methods
 func Log(lvl zapcore.Level, args ...interface{}) { ZapLog.Log(lvl, args...) }
 func Logw(lvl zapcore.Level, msg string, keysAndValues ...interface{}) {
@@ -0,0 +1,370 @@
+// Package models builds the aggregated model list served by GET /v1/models
+package models
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/util"
+	"golang.org/x/sync/singleflight"
+)
+
+// Model is an entry of the OpenAI style model list
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"` // route serving the model, e.g. "openai"
+}
+
+const (
+	fetchTimeout = 10 * time.Second
+	retryAfter   = 30 * time.Second // failed fetches are retried after this, even with a longer TTL
+
+	// sent to chat_to_anthropic upstreams that set no version, like the converter does
+	anthropicVersion = "2023-06-01"
+)
+
+// listWait is how long List waits for upstream lists. Slower routes keep being
+// fetched in the background and are listed from the cache once they answer.
+var listWait = 3 * time.Second
+
+type upstreamModel struct {
+	ID      string
+	Created int64
+}
+
+type cacheEntry struct {
+	route   string // routeKey of the route, entries of removed routes are pruned
+	models  []upstreamModel
+	err     error // the last fetch failed, models is the last good list
+	expires time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*cacheEntry) // key: see cacheKey, only routes of the current config
+
+	// concurrent misses of one route share a single upstream request
+	fetches singleflight.Group
+
+	client = &http.Client{
+		Timeout: fetchTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+		},
+	}
+)
+
+// List returns the models of all enabled routes, and the paths of the routes
+// whose upstream list is missing because it failed or did not arrive in time.
+//
+// Each route contributes its model_map aliases and its upstream list, filtered
+// by models_allow / models_deny. A model that the unified endpoint would route
+// elsewhere is listed as "route/model", so every listed id can be requested.
+//
+// clientKey is the upstream API key sent by the client, used for routes whose
+// headers set none.
+func List(ctx context.Context, cfg *config.RoutesConfig, ttl time.Duration, clientKey string) ([]Model, []string) {
+	prune(cfg)
+	upstream := make([][]upstreamModel, len(cfg.Routes))
+	failed := make([]bool, len(cfg.Routes))
+
+	type result struct {
+		i      int
+		models []upstreamModel
+		err    error
+	}
+	// buffered, so fetches that finish after the wait do not block
+	results := make(chan result, len(cfg.Routes))
+	pending := make(map[int]bool)
+
+	for i := range cfg.Routes {
+		if cfg.Routes[i].Disabled {
+			continue
+		}
+		pending[i] = true
+		go func(i int) {
+			models, err := cached(ctx, &cfg.Routes[i], ttl, clientKey)
+			results <- result{i, models, err}
+		}(i)
+	}
+
+	timer := time.NewTimer(listWait)
+	defer timer.Stop()
+wait:
+	for len(pending) > 0 {
+		select {
+		case res := <-results:
+			delete(pending, res.i)
+			upstream[res.i], failed[res.i] = res.models, res.err != nil
+		case <-timer.C:
+			break wait
+		case <-ctx.Done():
+			break wait
+		}
+	}
+	// routes still fetching are listed with their last good list, if any
+	for i := range pending {
+		if entry := lookup(cacheKey(&cfg.Routes[i], clientKey)); entry != nil {
+			upstream[i] = entry.models
+		}
+		failed[i] = true
+	}
+
+	now := time.Now().Unix()
+	seen := make(map[string]bool)
+	out := make([]Model, 0)
+	var unlisted []string
+
+	for i := range cfg.Routes {
+		r := &cfg.Routes[i]
+		if r.Disabled {
+			continue
+		}
+		if failed[i] {
+			unlisted = append(unlisted, r.Path)
+		}
+		owner := strings.TrimPrefix(r.Path, "/")
+
+		// aliases first, then upstream models, each sorted by name
+		aliases := make([]upstreamModel, 0, len(r.ModelMap))
+		for alias := range r.ModelMap {
+			if config.IsModelPattern(alias) {
+				continue
+			}
+			aliases = append(aliases, upstreamModel{ID: alias, Created: now})
+		}
+		sort.Slice(aliases, func(a, b int) bool { return aliases[a].ID < aliases[b].ID })
+
+		for _, m := range append(aliases, upstream[i]...) {
+			if !r.ModelAllowed(m.ID) {
+				continue
+			}
+
+			id := m.ID
+			if matched, _, ok := cfg.MatchModel(id); !ok || matched.Path != r.Path {
+				id = owner + "/" + id
+			}
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			out = append(out, Model{ID: id, Object: "model", Created: m.Created, OwnedBy: owner})
+		}
+	}
+
+	return out, unlisted
+}
+
+func routeKey(r *config.Route) string {
+	return r.Path + " " + r.Target
+}
+
+// cacheKey identifies the list of r. Lists fetched with a client's key are
+// cached per key, by hash, as the upstream may list different models per key.
+func cacheKey(r *config.Route, clientKey string) string {
+	if upstreamKey(r, clientKey) == "" || hasAuthHeader(r) {
+		return routeKey(r)
+	}
+	sum := sha256.Sum256([]byte(clientKey))
+	return routeKey(r) + " " + hex.EncodeToString(sum[:8])
+}
+
+// prune drops cache entries of routes that were removed, retargeted or disabled,
+// and expired entries of client keys, so the cache only holds current routes
+func prune(cfg *config.RoutesConfig) {
+	keep := make(map[string]bool, len(cfg.Routes))
+	for i := range cfg.Routes {
+		if !cfg.Routes[i].Disabled {
+			keep[routeKey(&cfg.Routes[i])] = true
+		}
+	}
+
+	now := time.Now()
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	for key, entry := range cache {
+		if !keep[entry.route] || (key != entry.route && now.After(entry.expires)) {
+			delete(cache, key)
+		}
+	}
+}
+
+// cached returns the upstream models of r, fetching them when the cache entry expired.
+// The error is that of the last fetch, the models are then the last good list.
+func cached(ctx context.Context, r *config.Route, ttl time.Duration, clientKey string) ([]upstreamModel, error) {
+	key := cacheKey(r, clientKey)
+
+	if entry := lookup(key); entry != nil && time.Now().Before(entry.expires) {
+		return entry.models, entry.err
+	}
+
+	// a client going away must not be cached as an upstream failure
+	v, _, _ := fetches.Do(key, func() (interface{}, error) {
+		return refresh(context.WithoutCancel(ctx), r, ttl, clientKey), nil
+	})
+	entry := v.(*cacheEntry)
+	return entry.models, entry.err
+}
+
+func lookup(key string) *cacheEntry {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	return cache[key]
+}
+
+// refresh fetches the models of r and caches them, keeping the last good list on errors
+func refresh(ctx context.Context, r *config.Route, ttl time.Duration, clientKey string) *cacheEntry {
+	key := cacheKey(r, clientKey)
+
+	// a flight that finished just before this one may have refreshed the entry
+	entry := lookup(key)
+	if entry != nil && time.Now().Before(entry.expires) {
+		return entry
+	}
+
+	models, err := fetch(ctx, r, clientKey)
+	if err != nil {
+		logger.Warnf("[models] failed to list models of route %s (%s): %s",
+			r.Path, r.Public().Target, util.RedactURLError(err, r.Target))
+		// keep serving the last good list and retry later
+		next := &cacheEntry{route: routeKey(r), err: err, expires: time.Now().Add(min(ttl, retryAfter))}
+		if entry != nil {
+			next.models = entry.models
+		}
+		entry = next
+	} else {
+		entry = &cacheEntry{route: routeKey(r), models: models, expires: time.Now().Add(ttl)}
+	}
+
+	if ttl > 0 {
+		cacheMu.Lock()
+		cache[key] = entry
+		cacheMu.Unlock()
+	}
+
+	return entry
+}
+
+// authHeaders are the headers upstreams take API keys from
+var authHeaders = []string{"Authorization", "x-api-key", "x-goog-api-key", "api-key"}
+
+// hasAuthHeader reports whether the route's headers set an upstream API key
+func hasAuthHeader(r *config.Route) bool {
+	for k := range r.Headers {
+		for _, h := range authHeaders {
+			if strings.EqualFold(k, h) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upstreamKey returns the client's key if it is sent for r
+func upstreamKey(r *config.Route, clientKey string) string {
+	if hasAuthHeader(r) {
+		return ""
+	}
+	return clientKey
+}
+
+// fetch requests the model list of r in the format of its upstream: Gemini's
+// /v1beta/models for chat_to_gemini routes, {target}/v1/models otherwise.
+// The route's headers are sent, or the client's key when they set none.
+func fetch(ctx context.Context, r *config.Route, clientKey string) ([]upstreamModel, error) {
+	endpoint := util.JoinURL(r.Target, "/v1/models")
+	if r.Transform == config.TransformChatToGemini {
+		endpoint = util.JoinURL(r.Target, "/v1beta/models") + "?pageSize=1000"
+	} else if r.Transform == config.TransformChatToAnthropic {
+		endpoint += "?limit=1000"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := upstreamKey(r, clientKey); key != "" {
+		switch r.Transform {
+		case config.TransformChatToGemini:
+			req.Header.Set("x-goog-api-key", key)
+		case config.TransformChatToAnthropic:
+			req.Header.Set("x-api-key", key)
+		default:
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+	}
+	if r.Transform == config.TransformChatToAnthropic {
+		req.Header.Set("anthropic-version", anthropicVersion)
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+
+	return parseModelList(body)
+}
+
+// parseModelList accepts OpenAI style {"data":[{"id"}]}, Anthropic style
+// {"data":[{"id","created_at"}]} and Gemini style {"models":[{"name"}]} lists
+func parseModelList(body []byte) ([]upstreamModel, error) {
+	var list struct {
+		Data []struct {
+			ID        string `json:"id"`
+			Created   int64  `json:"created"`
+			CreatedAt string `json:"created_at"`
+		} `json:"data"`
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("invalid model list: %v", err)
+	}
+
+	var out []upstreamModel
+	for _, m := range list.Data {
+		if m.ID == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, m.CreatedAt); err == nil && m.Created == 0 {
+			m.Created = t.Unix()
+		}
+		out = append(out, upstreamModel{ID: m.ID, Created: m.Created})
+	}
+	for _, m := range list.Models {
+		if id := strings.TrimPrefix(m.Name, "models/"); id != "" {
+			out = append(out, upstreamModel{ID: id})
+		}
+	}
+
+	sort.Slice(out, func(a, b int) bool { return out[a].ID < out[b].ID })
+	return out, nil
+}
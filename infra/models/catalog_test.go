@@ -0,0 +1,171 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.ZapLog = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
+
+// upstream serves a model list slowly and counts the requests
+func upstream(t *testing.T, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(`{"data":[{"id":"gpt-4o","created":1}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func resetCache() {
+	cacheMu.Lock()
+	cache = make(map[string]*cacheEntry)
+	cacheMu.Unlock()
+}
+
+func TestListSharesConcurrentFetches(t *testing.T) {
+	resetCache()
+	for _, ttl := range []time.Duration{time.Minute, 0} {
+		var requests atomic.Int32
+		srv := upstream(t, &requests)
+		cfg := &config.RoutesConfig{Routes: []config.Route{{Path: "/openai", Target: srv.URL, Models: []string{"gpt-*"}}}}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if list, _ := List(context.Background(), cfg, ttl, ""); len(list) != 1 || list[0].ID != "gpt-4o" {
+					t.Errorf("list = %v", list)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if n := requests.Load(); n != 1 {
+			t.Errorf("ttl %s: %d upstream requests, want 1", ttl, n)
+		}
+	}
+}
+
+func TestListPrunesRemovedRoutes(t *testing.T) {
+	resetCache()
+	var requests atomic.Int32
+	srv := upstream(t, &requests)
+
+	cfg := &config.RoutesConfig{Routes: []config.Route{
+		{Path: "/a", Target: srv.URL},
+		{Path: "/b", Target: srv.URL},
+	}}
+	List(context.Background(), cfg, time.Minute, "")
+
+	// /a is removed and /b is retargeted
+	cfg = &config.RoutesConfig{Routes: []config.Route{{Path: "/b", Target: srv.URL + "/"}}}
+	List(context.Background(), cfg, time.Minute, "")
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if len(cache) != 1 || cache["/b "+srv.URL+"/"] == nil {
+		t.Errorf("cache keys = %v, want only the current route", keys(cache))
+	}
+}
+
+func keys(m map[string]*cacheEntry) []string {
+	var out []string
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestListProviderFormats(t *testing.T) {
+	resetCache()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1beta/models" && r.Header.Get("x-goog-api-key") == "client-key":
+			w.Write([]byte(`{"models":[{"name":"models/gemini-2.5-pro"}]}`))
+		case r.URL.Path == "/v1/models" && r.Header.Get("x-api-key") == "client-key" && r.Header.Get("anthropic-version") != "":
+			w.Write([]byte(`{"data":[{"type":"model","id":"claude-sonnet-4","created_at":"2025-05-22T00:00:00Z"}]}`))
+		case r.URL.Path == "/v1/models" && r.Header.Get("Authorization") == "Bearer route-key":
+			w.Write([]byte(`{"data":[{"id":"gpt-4o","created":1}]}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &config.RoutesConfig{Routes: []config.Route{
+		{Path: "/gemini", Target: srv.URL, Transform: config.TransformChatToGemini},
+		{Path: "/claude", Target: srv.URL, Transform: config.TransformChatToAnthropic},
+		{Path: "/openai", Target: srv.URL, Headers: map[string]string{"Authorization": "Bearer route-key"}},
+	}}
+	list, unlisted := List(context.Background(), cfg, time.Minute, "client-key")
+
+	var got []string
+	for _, m := range list {
+		got = append(got, m.ID)
+	}
+	want := "gemini/gemini-2.5-pro claude/claude-sonnet-4 openai/gpt-4o"
+	if strings.Join(got, " ") != want || len(unlisted) != 0 {
+		t.Errorf("list = %v, unlisted = %v, want %s", got, unlisted, want)
+	}
+	if list[1].Created != 1747872000 {
+		t.Errorf("created = %d, want created_at", list[1].Created)
+	}
+
+	// lists fetched with a client's key are not shared with other clients
+	if list, unlisted := List(context.Background(), cfg, time.Minute, "other-key"); len(list) != 1 || len(unlisted) != 2 {
+		t.Errorf("other key: list = %v, unlisted = %v", list, unlisted)
+	}
+}
+
+func TestListReportsSlowAndFailingRoutes(t *testing.T) {
+	resetCache()
+	defer func(d time.Duration) { listWait = d }(listWait)
+	listWait = 100 * time.Millisecond
+
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte(`{"data":[{"id":"slow-model"}]}`))
+	}))
+	defer slow.Close()
+	defer close(release)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	cfg := &config.RoutesConfig{Routes: []config.Route{
+		{Path: "/slow", Target: slow.URL},
+		{Path: "/failing", Target: failing.URL, ModelMap: map[string]string{"alias": "real"}},
+	}}
+
+	start := time.Now()
+	list, unlisted := List(context.Background(), cfg, time.Minute, "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("List took %s", elapsed)
+	}
+	if len(list) != 1 || list[0].ID != "alias" {
+		t.Errorf("list = %v, want only the alias", list)
+	}
+	if strings.Join(unlisted, " ") != "/slow /failing" {
+		t.Errorf("unlisted = %v", unlisted)
+	}
+}
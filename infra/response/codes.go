@@ -5,4 +5,7 @@ const (
 	INVALID_REQUEST_ERROR = "invalid_request_error"
 	SERVICE_UNAVAILABLE   = "service_unavailable"
 	NOT_FOUND_ERROR       = "not_found_error"
+	CONFLICT_ERROR        = "conflict_error"
+	PERMISSION_ERROR      = "permission_error"
+	UPSTREAM_ERROR        = "upstream_error"
 )
@@ -0,0 +1,161 @@
+package stream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// RestoreModel wraps an upstream body so the top-level "model" field of the JSON
+// body, or of each SSE data object, reports model, the name the client asked for,
+// instead of the upstream model. The objects of Anthropic message_start and Responses
+// API events ("message", "response") count as top-level. Other nested "model" fields,
+// e.g. in tool arguments or metadata, are left as they are.
+func RestoreModel(body io.ReadCloser, model string) io.ReadCloser {
+	quoted, _ := json.Marshal(model)
+	return &modelRestorer{
+		src:    body,
+		r:      bufio.NewReader(body),
+		quoted: quoted,
+	}
+}
+
+// position in a member of the top-level object
+const (
+	memberKey = iota
+	memberColon
+	memberValue
+	memberRest
+)
+
+type modelRestorer struct {
+	src     io.ReadCloser
+	r       *bufio.Reader
+	quoted  []byte
+	pending []byte
+	err     error
+
+	// JSON scanner state, kept across lines for pretty-printed bodies
+	depth    int  // nesting of objects and arrays
+	inObject bool // the top-level value is an object
+	envelope bool // inside the "message" / "response" object of the top-level object
+	member   [3]int
+	key      [3][]byte // key read last, by depth
+	inString bool
+	escaped  bool
+	inKey    bool // reading a key of a scanned object
+	dropping bool // skipping the upstream model value
+}
+
+// envelopes are top-level members whose object holds the model of the response
+var envelopes = map[string]bool{"message": true, "response": true}
+
+func (m *modelRestorer) Read(p []byte) (int, error) {
+	for len(m.pending) == 0 {
+		if m.err != nil {
+			return 0, m.err
+		}
+		var line []byte
+		line, m.err = m.r.ReadBytes('\n')
+		m.pending = m.restoreLine(line)
+	}
+
+	n := copy(p, m.pending)
+	m.pending = m.pending[n:]
+	return n, nil
+}
+
+// restoreLine copies line, replacing the value of a top-level "model" member.
+// Each SSE data line starts a new JSON value.
+func (m *modelRestorer) restoreLine(line []byte) []byte {
+	if bytes.HasPrefix(line, []byte("data:")) {
+		m.depth, m.inString, m.escaped, m.inKey, m.dropping = 0, false, false, false, false
+	}
+
+	out := make([]byte, 0, len(line)+len(m.quoted))
+	for _, c := range line {
+		d := m.depth
+		if m.inString {
+			if !m.dropping {
+				out = append(out, c)
+			}
+			switch {
+			case m.escaped:
+				m.escaped = false
+			case c == '\\':
+				m.escaped = true
+			case c == '"':
+				m.inString, m.dropping = false, false
+				if m.inKey {
+					m.inKey = false
+					m.member[d] = memberColon
+					continue
+				}
+			}
+			if m.inKey {
+				m.key[d] = append(m.key[d], c)
+			}
+			continue
+		}
+
+		scanned := (d == 1 && m.inObject) || (d == 2 && m.envelope)
+		switch c {
+		case '"':
+			m.inString = true
+			switch {
+			case scanned && m.member[d] == memberKey:
+				m.inKey = true
+				m.key[d] = m.key[d][:0]
+			case scanned && m.member[d] == memberValue && string(m.key[d]) == "model":
+				// the upstream value is dropped up to its closing quote
+				out = append(out, m.quoted...)
+				m.dropping = true
+				m.member[d] = memberRest
+				continue
+			case scanned && m.member[d] == memberValue:
+				m.member[d] = memberRest
+			}
+		case '{', '[':
+			if d == 0 {
+				m.inObject = c == '{'
+				m.envelope = false
+				m.member[1] = memberKey
+			} else if scanned && m.member[d] == memberValue {
+				m.member[d] = memberRest
+				if d == 1 && c == '{' && envelopes[string(m.key[1])] {
+					m.envelope = true
+					m.member[2] = memberKey
+				}
+			}
+			m.depth++
+		case '}', ']':
+			if d > 0 {
+				m.depth--
+			}
+			if d == 2 {
+				m.envelope = false
+			}
+		case ':':
+			if scanned && m.member[d] == memberColon {
+				m.member[d] = memberValue
+			}
+		case ',':
+			if scanned {
+				m.member[d] = memberKey
+			}
+		case ' ', '\t', '\r', '\n':
+		default:
+			// numbers, true, false, null
+			if scanned && m.member[d] == memberValue {
+				m.member[d] = memberRest
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (m *modelRestorer) Close() error {
+	return m.src.Close()
+}
@@ -0,0 +1,111 @@
+package stream
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func restore(t *testing.T, body, model string) string {
+	t.Helper()
+	out, err := io.ReadAll(RestoreModel(io.NopCloser(strings.NewReader(body)), model))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestRestoreModel(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "json body",
+			body: `{"id":"x","model":"gpt-4o-2024-08-06","object":"chat.completion"}`,
+			want: `{"id":"x","model":"gpt-4o","object":"chat.completion"}`,
+		},
+		{
+			name: "spacing is kept",
+			body: `{ "model" : "upstream" , "n": 1 }`,
+			want: `{ "model" : "gpt-4o" , "n": 1 }`,
+		},
+		{
+			name: "nested model fields are kept",
+			body: `{"model":"upstream","choices":[{"message":{"tool_calls":[{"function":{"arguments":"{\"model\":\"x\"}"}}]}}],"metadata":{"model":"m"}}`,
+			want: `{"model":"gpt-4o","choices":[{"message":{"tool_calls":[{"function":{"arguments":"{\"model\":\"x\"}"}}]}}],"metadata":{"model":"m"}}`,
+		},
+		{
+			name: "model after a nested object",
+			body: `{"usage":{"model":"a"},"model":"upstream"}`,
+			want: `{"usage":{"model":"a"},"model":"gpt-4o"}`,
+		},
+		{
+			name: "model as a string value is kept",
+			body: `{"name":"model","x":"model","model":"upstream"}`,
+			want: `{"name":"model","x":"model","model":"gpt-4o"}`,
+		},
+		{
+			name: "escaped quotes in the upstream value",
+			body: `{"model":"a\"b\\","id":"1"}`,
+			want: `{"model":"gpt-4o","id":"1"}`,
+		},
+		{
+			name: "non-string model values are kept",
+			body: `{"model":null,"id":"1"}`,
+			want: `{"model":null,"id":"1"}`,
+		},
+		{
+			name: "pretty printed body",
+			body: "{\n  \"metadata\": {\n    \"model\": \"inner\"\n  },\n  \"model\": \"upstream\"\n}\n",
+			want: "{\n  \"metadata\": {\n    \"model\": \"inner\"\n  },\n  \"model\": \"gpt-4o\"\n}\n",
+		},
+		{
+			name: "top-level arrays are kept",
+			body: `[{"model":"upstream"}]`,
+			want: `[{"model":"upstream"}]`,
+		},
+		{
+			name: "sse stream",
+			body: "event: message_start\n" +
+				`data: {"type":"message_start","message":{"model":"claude-upstream"}}` + "\n\n" +
+				`data: {"id":"1","model":"upstream","choices":[{"delta":{"content":"\"model\":\"x\""}}]}` + "\n\n" +
+				`data: {"id":"2","model":"upstream"}` + "\n\n" +
+				"data: [DONE]\n\n",
+			want: "event: message_start\n" +
+				`data: {"type":"message_start","message":{"model":"gpt-4o"}}` + "\n\n" +
+				`data: {"id":"1","model":"gpt-4o","choices":[{"delta":{"content":"\"model\":\"x\""}}]}` + "\n\n" +
+				`data: {"id":"2","model":"gpt-4o"}` + "\n\n" +
+				"data: [DONE]\n\n",
+		},
+		{
+			name: "responses api events",
+			body: `data: {"type":"response.created","response":{"id":"r","metadata":{"model":"m"},"model":"upstream"}}` + "\n",
+			want: `data: {"type":"response.created","response":{"id":"r","metadata":{"model":"m"},"model":"gpt-4o"}}` + "\n",
+		},
+		{
+			name: "envelopes below the top level are kept",
+			body: `{"choices":[{"message":{"model":"a"}}],"response":"x","model":"upstream"}`,
+			want: `{"choices":[{"message":{"model":"a"}}],"response":"x","model":"gpt-4o"}`,
+		},
+		{
+			name: "truncated sse event does not leak into the next",
+			body: `data: {"a":{"b":"` + "\n" + `data: {"model":"upstream"}` + "\n",
+			want: `data: {"a":{"b":"` + "\n" + `data: {"model":"gpt-4o"}` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := restore(t, tt.body, "gpt-4o"); got != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRestoreModelQuotesName(t *testing.T) {
+	got := restore(t, `{"model":"x"}`, `a"b`)
+	if got != `{"model":"a\"b"}` {
+		t.Errorf("got %s", got)
+	}
+}
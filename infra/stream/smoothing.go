@@ -6,11 +6,11 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/watcher"
 )
 
 type chunk struct {
@@ -251,7 +251,7 @@ func writeToClient(c *gin.Context, resp *http.Response, out <-chan chunk) {
 	}
 
 	// === 2. Heartbeat config ===
-	heartbeatEnabled := os.Getenv("STREAM_HEARTBEAT_ENABLED") == "true"
+	heartbeatEnabled := watcher.GetSettings().StreamHeartbeat
 	pingInterval := 1 * time.Second
 	var lastPing time.Time
 	if heartbeatEnabled {
@@ -0,0 +1,105 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/poixeai/proxify/infra/logger"
+)
+
+const defaultDebounce = 200 * time.Millisecond
+
+// watchPaths watches files and directories through their parent directories, so atomic
+// renames, editor save-as-new-file and Kubernetes ConfigMap symlink swaps are all noticed.
+// onChange is called once per burst of events, after the debounce delay.
+func watchPaths(files, dirs []string, onChange func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	fileSet := make(map[string]bool)
+	dirSet := make(map[string]bool)
+	parents := make(map[string]bool)
+
+	for _, f := range files {
+		f = filepath.Clean(f)
+		fileSet[f] = true
+		parents[filepath.Dir(f)] = true
+	}
+	for _, d := range dirs {
+		if d == "" {
+			continue
+		}
+		d = filepath.Clean(d)
+		dirSet[d] = true
+		parents[filepath.Dir(d)] = true
+	}
+
+	watched := 0
+	for p := range parents {
+		if err := w.Add(p); err != nil {
+			logger.Warnf("watcher: dir [%s] not watchable: %v", p, err)
+			continue
+		}
+		watched++
+	}
+	for d := range dirSet {
+		if err := w.Add(d); err == nil {
+			watched++
+		}
+	}
+	if watched == 0 {
+		w.Close()
+		return os.ErrNotExist
+	}
+
+	relevant := func(name string) bool {
+		name = filepath.Clean(name)
+		dir := filepath.Dir(name)
+
+		// the watched file itself, or a ConfigMap "..data" swap next to it
+		if fileSet[name] || (parents[dir] && strings.HasPrefix(filepath.Base(name), "..")) {
+			return true
+		}
+		// the watched directory itself or anything inside it
+		return dirSet[name] || dirSet[dir]
+	}
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if !relevant(event.Name) {
+					continue
+				}
+
+				// a watched directory was (re)created, start watching its contents
+				if dirSet[filepath.Clean(event.Name)] && event.Op&fsnotify.Create != 0 {
+					w.Add(event.Name)
+				}
+
+				// editors and atomic writers emit several events per save, fire once
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(defaultDebounce, onChange)
+
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				logger.Warnf("watcher: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/poixeai/proxify/util"
+)
+
+// waitChanges waits for the debounced callback, if one is expected, and returns how often it fired
+func waitChanges(changes *atomic.Int32, expected bool) int32 {
+	deadline := time.Now().Add(2 * time.Second)
+	for expected && changes.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// a second burst would fire after another debounce delay
+	time.Sleep(2 * defaultDebounce)
+	return changes.Swap(0)
+}
+
+func TestWatchPaths(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "routes.json")
+	routesDir := filepath.Join(dir, "routes.d")
+	os.WriteFile(file, []byte(`{}`), 0644)
+
+	var changes atomic.Int32
+	if err := watchPaths([]string{file}, []string{routesDir}, func() { changes.Add(1) }); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name   string
+		change func() error
+		want   int32
+	}{
+		{"atomic rename", func() error { return util.WriteFileAtomic(file, []byte(`{"routes":[]}`), 0644) }, 1},
+		// the watch follows the path, not the replaced inode
+		{"second atomic rename", func() error { return util.WriteFileAtomic(file, []byte(`{}`), 0644) }, 1},
+		{"other file", func() error { return os.WriteFile(filepath.Join(dir, "other.json"), []byte(`{}`), 0644) }, 0},
+		{"routes.d created", func() error { return os.Mkdir(routesDir, 0755) }, 1},
+		{"file in routes.d", func() error { return os.WriteFile(filepath.Join(routesDir, "a.json"), []byte(`{}`), 0644) }, 1},
+		{"ConfigMap swap", func() error {
+			if err := os.Symlink(dir, filepath.Join(dir, "..data_tmp")); err != nil {
+				return err
+			}
+			return os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data"))
+		}, 1},
+	}
+	for _, s := range steps {
+		if err := s.change(); err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if got := waitChanges(&changes, s.want > 0); got != s.want {
+			t.Errorf("%s: %d reloads, want %d", s.name, got, s.want)
+		}
+	}
+}
@@ -1,84 +1,234 @@
 package watcher
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
-	"fmt"
 	"os"
+	"path/filepath"
+	"sync"
 	"sync/atomic"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/history"
 	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/util"
 )
 
 var ConfigValue atomic.Value // global config value
 
-func WatchJSON(file string) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		logger.Errorf("failed to create fsnotify watcher: %v", err)
-		return
-	}
-
-	go func() {
-		for event := range watcher.Events {
-			if event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-				cfg, err := config.LoadRoutesConfig(file)
-				if err != nil {
-					logger.Errorf("[routes.json] file reload failed:", err)
-					continue
-				}
-
-				if err := validateRoutes(cfg); err != nil {
-					logger.Errorf("[routes.json] validation failed: %v", err)
-					continue
-				}
-
-				ConfigValue.Store(cfg)
-				logger.Info("[routes.json] file reloaded successfully.")
-			}
-		}
-	}()
-
-	if err := watcher.Add(file); err != nil {
-		logger.Warnf("watcher: file [%s] not found, skip watching", file)
-	}
-}
+// routes reload sources
+var (
+	routesMu      sync.Mutex
+	routesFile    string
+	routesDir     string
+	routesVersion [sha256.Size]byte // hash of the last applied config
+	routesHistory = history.Default()
+)
 
-func InitRoutesWatcher() error {
-	const file = "routes.json"
+// InitRoutesWatcher loads the routes file and the routes.d directory, validates them
+// and starts watching both for changes. An empty dir disables routes.d.
+func InitRoutesWatcher(file, dir string) error {
+	routesMu.Lock()
+	routesFile = file
+	routesDir = dir
+	routesMu.Unlock()
 
-	cfg, err := config.LoadRoutesConfig(file)
+	cfg, err := config.LoadRoutes(file, dir)
 	if err != nil {
 		if os.IsNotExist(err) {
 			// if file not found, load default config
-			logger.Warnf("[routes.json] not found, loading default config.")
-			cfg = &config.RoutesConfig{
-				Routes: []config.Route{
-					// default routes, add more
-					{Path: "/openai", Target: "https://api.openai.com"},
-				},
-			}
+			logger.Warnf("[routes] %s not found, loading default config.", file)
+			cfg = config.DefaultRoutesConfig()
 		} else {
 			logger.Errorf("failed to load routes config: %v", err)
 			return err
 		}
 	} else {
-		logger.Infof("[routes.json] loaded successfully (%d routes)", len(cfg.Routes))
+		logger.Infof("[routes] loaded successfully (%d routes)", len(cfg.Routes))
 	}
 
 	// validate routes
-	if err := validateRoutes(cfg); err != nil {
+	if err := config.ValidateRoutes(cfg); err != nil {
 		logger.Errorf("route validation failed: %v", err)
 		return err
 	}
 
+	warnUnifiedEndpoint(cfg)
+
+	routesMu.Lock()
 	ConfigValue.Store(cfg)
-	WatchJSON(file)
+	routesVersion = hashRoutes(cfg)
+	routesHistory = history.Default()
+	recordHistory(cfg, history.SourceStartup)
+	routesMu.Unlock()
+
+	if err := watchPaths([]string{file}, []string{dir}, func() { ReloadRoutes() }); err != nil {
+		logger.Warnf("watcher: routes config [%s] not watchable, skip watching: %v", file, err)
+	}
+
+	return nil
+}
+
+// ReloadRoutes re-reads all routes sources and swaps the config in if it is valid.
+// On error the current config is kept.
+func ReloadRoutes() error {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+
+	// not initialized yet
+	if routesFile == "" {
+		return nil
+	}
+
+	cfg, err := config.LoadRoutes(routesFile, routesDir)
+	if err != nil {
+		logger.Errorf("[routes] reload failed: %v", err)
+		return err
+	}
 
+	if err := config.ValidateRoutes(cfg); err != nil {
+		logger.Errorf("[routes] validation failed: %v", err)
+		return err
+	}
+
+	// several events may belong to one change, skip no-op reloads
+	version := hashRoutes(cfg)
+	if version == routesVersion {
+		return nil
+	}
+
+	ConfigValue.Store(cfg)
+	routesVersion = version
+	recordHistory(cfg, history.SourceFile)
+	logger.Infof("[routes] reloaded successfully (%d routes)", len(cfg.Routes))
+	warnUnifiedEndpoint(cfg)
 	return nil
 }
 
+// warnUnifiedEndpoint tells configs from before the unified endpoint how to enable it
+func warnUnifiedEndpoint(cfg *config.RoutesConfig) {
+	if !cfg.UnifiedEndpoint() {
+		logger.Warnf("[routes] route /v1 is proxied as configured, so the unified /v1 endpoint is off. " +
+			"To route /v1 by model, move the route to another path like /openai and list its models.")
+	}
+}
+
+// RoutesFile returns the main routes file path, the one written by UpdateRoutesFile
+func RoutesFile() string {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	return routesFile
+}
+
+// UpdateRoutesFile applies fn to the routes of the main routes file (as written,
+// with ${...} references), validates the result merged with routes.d, writes the
+// file back atomically and swaps the new config in. source is recorded in history.
+func UpdateRoutesFile(source string, fn func(routes []config.Route) ([]config.Route, error)) (*config.RoutesConfig, error) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+
+	if routesFile == "" {
+		return nil, errors.New("routes watcher is not initialized")
+	}
+
+	// current content of the main file, a missing file starts from the default routes
+	current := &config.RoutesConfig{}
+	if cfg, err := config.LoadRoutesConfig(routesFile); err == nil {
+		current = cfg
+	} else if os.IsNotExist(err) {
+		for _, r := range GetRoutes().Routes {
+			if r.Source == "" {
+				current.Routes = append(current.Routes, r)
+			}
+		}
+	} else {
+		return nil, err
+	}
+
+	routes, err := fn(current.PublicRoutes())
+	if err != nil {
+		return nil, err
+	}
+
+	merged, err := WriteRoutesFile(routesFile, routesDir, routes)
+	if err != nil {
+		return nil, err
+	}
+
+	ConfigValue.Store(merged)
+	routesVersion = hashRoutes(merged)
+	recordHistory(merged, source)
+	logger.Infof("[routes] %s updated by %s (%d routes)", routesFile, source, len(merged.Routes))
+
+	return merged, nil
+}
+
+// WriteRoutesFile validates routes merged with dir and atomically writes them to file
+// in the file's format. It returns the merged config, and does not touch the live config.
+func WriteRoutesFile(file, dir string, routes []config.Route) (*config.RoutesConfig, error) {
+	ext := filepath.Ext(file)
+	data, err := config.EncodeRoutesConfig(&config.RoutesConfig{Routes: routes}, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse what we are about to write, so interpolation errors are caught too
+	main, err := config.ParseRoutesConfig(data, ext)
+	if err != nil {
+		return nil, err
+	}
+	for i := range main.Routes {
+		main.Routes[i].Source = file
+	}
+
+	merged, err := config.MergeRoutesDir(main, dir)
+	if err != nil {
+		return nil, err
+	}
+	if merged == nil {
+		merged = &config.RoutesConfig{}
+	}
+	if err := config.ValidateRoutes(merged); err != nil {
+		return nil, err
+	}
+
+	if err := util.WriteFileAtomic(file, data, 0644); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+// Rollback restores the main routes file to the given history revision.
+// Routes from routes.d are not changed.
+func Rollback(id int) (*config.RoutesConfig, error) {
+	rev, err := History().Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	routes, err := rev.MainRoutes(RoutesFile())
+	if err != nil {
+		return nil, err
+	}
+	return UpdateRoutesFile(history.SourceRollback, func([]config.Route) ([]config.Route, error) {
+		return routes, nil
+	})
+}
+
+// History returns the routes history store, InitRoutesWatcher replaces it
+func History() *history.Store {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	return routesHistory
+}
+
+func recordHistory(cfg *config.RoutesConfig, source string) {
+	if _, err := routesHistory.Record(cfg, source); err != nil {
+		logger.Warnf("[routes] failed to record history: %v", err)
+	}
+}
+
 func GetRoutes() *config.RoutesConfig {
 	v := ConfigValue.Load()
 	if v == nil {
@@ -87,26 +237,16 @@ func GetRoutes() *config.RoutesConfig {
 	return v.(*config.RoutesConfig)
 }
 
-func validateRoutes(cfg *config.RoutesConfig) error {
-	seen := make(map[string]bool)
+func hashRoutes(cfg *config.RoutesConfig) [sha256.Size]byte {
+	h := sha256.New()
 	for _, r := range cfg.Routes {
-		path := r.Path
-
-		// 1. check empty
-		if path == "" {
-			return errors.New("invalid route: empty path is not allowed")
-		}
-
-		// 2. check reserved
-		if config.ReservedTopRoutes[path] {
-			return fmt.Errorf("invalid route: path '%s' is reserved by system", path)
-		}
-
-		// 3. check duplicate
-		if seen[path] {
-			return fmt.Errorf("invalid route: duplicate path '%s'", path)
-		}
-		seen[path] = true
+		h.Write([]byte(r.Source))
+		h.Write([]byte{0})
 	}
-	return nil
+	data, _ := json.Marshal(cfg)
+	h.Write(data)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
 }
@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/history"
+	"github.com/poixeai/proxify/infra/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	logger.ZapLog = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
+
+func TestUpdateAndRollback(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ROUTES_HISTORY_DIR", filepath.Join(dir, "history"))
+	file := filepath.Join(dir, "routes.json")
+	os.WriteFile(file, []byte(`{"routes":[{"path":"/a","target":"https://a.example.com"}]}`), 0644)
+
+	if err := InitRoutesWatcher(file, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := UpdateRoutesFile(history.SourceAdmin, func(routes []config.Route) ([]config.Route, error) {
+		return append(routes, config.Route{Path: "/b", Target: "https://b.example.com"}), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(GetRoutes().Routes); n != 2 {
+		t.Fatalf("routes after update = %d, want 2", n)
+	}
+
+	// history is read while the rollback runs, see go test -race
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			History().List()
+		}()
+	}
+
+	cfg, err := Rollback(1)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Routes) != 1 || cfg.Routes[0].Path != "/a" || len(GetRoutes().Routes) != 1 {
+		t.Errorf("routes after rollback = %+v", cfg.Routes)
+	}
+
+	written, err := config.LoadRoutesConfig(file)
+	if err != nil || len(written.Routes) != 1 {
+		t.Errorf("routes file after rollback = %+v, %v", written, err)
+	}
+
+	list, _ := History().List()
+	if len(list) != 3 || list[0].Source != history.SourceRollback {
+		t.Errorf("history = %+v, want startup, admin and rollback revisions", list)
+	}
+
+	if _, err := Rollback(99); err != history.ErrNotFound {
+		t.Errorf("Rollback of unknown revision: err = %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package watcher
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/joho/godotenv"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/logger"
+)
+
+var SettingsValue atomic.Value // global settings value
+
+// settings reload sources
+var (
+	settingsMu      sync.Mutex
+	settingsEnvFile string
+	settingsBaseEnv map[string]string // real process env, takes precedence over the .env file
+)
+
+// InitSettingsWatcher loads settings from the process env and envFile, then reloads them
+// on SIGHUP or whenever envFile changes. baseEnv is the process env captured before
+// envFile was loaded, so values set by the orchestrator keep winning over the file.
+func InitSettingsWatcher(envFile string, baseEnv map[string]string) error {
+	settingsEnvFile = envFile
+	settingsBaseEnv = baseEnv
+
+	if err := ReloadSettings(); err != nil {
+		return err
+	}
+
+	go watchReloadSignal()
+	if err := watchPaths([]string{envFile}, nil, func() {
+		logger.Infof("[settings] %s changed, reloading", envFile)
+		ReloadSettings()
+	}); err != nil {
+		logger.Warnf("watcher: [%s] not watchable, skip watching: %v", envFile, err)
+	}
+
+	return nil
+}
+
+// ReloadSettings re-reads the settings and swaps them in if they are valid.
+// On error the current settings are kept.
+func ReloadSettings() error {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	fileEnv, err := godotenv.Read(settingsEnvFile)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Errorf("[settings] failed to read %s: %v", settingsEnvFile, err)
+		return err
+	}
+
+	getenv := func(key string) string {
+		if v, ok := settingsBaseEnv[key]; ok {
+			return v
+		}
+		return fileEnv[key]
+	}
+
+	s, err := config.LoadSettingsFrom(getenv)
+	if err != nil {
+		logger.Errorf("[settings] validation failed, keeping previous settings: %v", err)
+		return err
+	}
+
+	if err := logger.SetLevel(s.LogLevel); err != nil {
+		logger.Errorf("[settings] failed to set log level: %v", err)
+		return err
+	}
+
+	SettingsValue.Store(s)
+	logger.Infof("[settings] loaded (token_auth=%v, ip_rules=%d, admin_users=%d, smoothing=%v, heartbeat=%v, log_level=%s)",
+		s.Auth.TokenAuthEnabled(), len(s.Auth.IPNets), len(s.Admin.Users), s.StreamSmoothing, s.StreamHeartbeat, s.LogLevel)
+	return nil
+}
+
+func GetSettings() *config.Settings {
+	v := SettingsValue.Load()
+	if v == nil {
+		return &config.Settings{Auth: &config.AuthConfig{}, Admin: &config.AdminConfig{}}
+	}
+	return v.(*config.Settings)
+}
+
+// watchReloadSignal reloads settings and routes on SIGHUP
+func watchReloadSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		logger.Infof("[watcher] received SIGHUP, reloading")
+		ReloadSettings()
+		ReloadRoutes()
+	}
+}
@@ -1,73 +1,59 @@
 package main
 
 import (
-	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
-	"github.com/poixeai/proxify/infra/config"
-	"github.com/poixeai/proxify/infra/logger"
-	"github.com/poixeai/proxify/infra/watcher"
-	"github.com/poixeai/proxify/router"
-	"github.com/poixeai/proxify/util"
+	"fmt"
+	"os"
+	"strings"
 )
 
-func main() {
-	// load .env
-	_ = godotenv.Load()
+// exit codes, stable for CI usage
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
 
-	// init logger
-	logger.InitLogger()
+const usage = `Proxify - AI API reverse proxy
 
-	// check .env
-	authCfg, err := config.LoadAuthConfig()
-	if err != nil {
-		logger.Fatalf("Auth config error: %v", err)
-	}
+Usage:
+  proxify [serve] [flags]          start the server (default)
+  proxify validate [flags]         validate .env and routes config
+  proxify routes list [flags]      list configured routes
+  proxify routes test <path>       show which route and target a request path resolves to
+  proxify routes history          list applied routes config revisions
+  proxify routes diff <a> <b>      diff two revisions
+  proxify routes rollback <id>     restore the routes file to a revision
+  proxify keys create --name <n>   create an API key (printed once)
+  proxify keys revoke <id>         revoke an API key
+  proxify keys list                list API keys
 
-	// Token auth
-	if authCfg.TokenKey != "" {
-		if len(authCfg.TokenKey) < 16 {
-			logger.Errorf("AUTH_TOKEN_KEY is too short (<16), refused to start")
-			return
-		}
-		if authCfg.TokenHeader == "" {
-			logger.Errorf("AUTH_TOKEN_HEADER is required when token auth enabled")
-			return
-		}
-		logger.Infof("Token auth enabled, header=%s", authCfg.TokenHeader)
-	}
+Run 'proxify <command> -h' for command flags.
+`
 
-	if len(authCfg.IPNets) > 0 {
-		logger.Infof("IP whitelist enabled, rules=%d", len(authCfg.IPNets))
-	}
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
 
-	// init routes watcher
-	if err := watcher.InitRoutesWatcher(); err != nil {
-		logger.Errorf("Failed to load routes config: %v", err)
-		return
+func run(args []string) int {
+	// no subcommand (or only flags) -> serve, keeps `./proxify` working as before
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "--help" {
+		return cmdServe(args)
 	}
 
-	// init gin
-	r := gin.New()
-	r.SetTrustedProxies(nil)
-
-	// Inject authCfg into Gin context
-	r.Use(func(c *gin.Context) {
-		c.Set("auth_config", authCfg)
-		c.Next()
-	})
-
-	// setup routes
-	router.SetRoutes(r)
-
-	// setup frontend static files
-	MountFrontend(r)
-
-	// start server
-	port := util.GetEnvPort()
-	if err := r.Run(":" + port); err != nil {
-		logger.Errorf("Failed to start server: %v", err)
-		return
+	switch args[0] {
+	case "serve":
+		return cmdServe(args[1:])
+	case "validate":
+		return cmdValidate(args[1:])
+	case "routes":
+		return cmdRoutes(args[1:])
+	case "keys":
+		return cmdKeys(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
 	}
-
-	logger.Infof("Server running on port %s", port)
 }
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+	"github.com/poixeai/proxify/infra/watcher"
+)
+
+// AdminAuth protects management endpoints with the admin credentials (ADMIN_TOKENS)
+// and requires at least minRole. Proxy tokens and API keys are never accepted, and
+// without admin credentials configured it fails closed.
+func AdminAuth(minRole config.AdminRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		settings := watcher.GetSettings()
+
+		if !ipAllowed(c, settings.Auth) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "IP not allowed",
+			})
+			return
+		}
+
+		admin := settings.Admin
+		if !admin.Enabled() {
+			response.RespondError(c, http.StatusForbidden,
+				"The admin API requires admin credentials (ADMIN_TOKENS) to be configured.",
+				response.PERMISSION_ERROR)
+			c.Abort()
+			return
+		}
+
+		user, ok := admin.Lookup(c.GetHeader(admin.TokenHeader))
+		if !ok {
+			response.RespondError(c, http.StatusUnauthorized,
+				"Invalid or missing admin token.", response.PERMISSION_ERROR)
+			c.Abort()
+			return
+		}
+
+		c.Set(ctx.AdminUser, user)
+
+		if user.Role < minRole {
+			response.RespondError(c, http.StatusForbidden,
+				fmt.Sprintf("This action requires the %s role, current role is %s.", minRole, user.Role),
+				response.PERMISSION_ERROR)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AdminAudit writes every mutating admin call to the audit log
+func AdminAudit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		user, role := "-", "-"
+		if v, ok := c.Get(ctx.AdminUser); ok {
+			u := v.(*config.AdminUser)
+			user, role = u.Name, u.Role.String()
+		}
+
+		logger.Audit("admin_action",
+			"request_id", c.GetString(ctx.RequestID),
+			"user", user,
+			"role", role,
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"client_ip", c.ClientIP(),
+			"latency", time.Since(start).String(),
+		)
+	}
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/watcher"
+)
+
+const (
+	viewerToken   = "viewer-token-0123456789"
+	operatorToken = "operator-token-0123456789"
+	adminToken    = "admin-token-0123456789"
+	proxyToken    = "proxy-token-0123456789"
+)
+
+// useSettings installs settings built from env for the duration of a test
+func useSettings(t *testing.T, env map[string]string) {
+	t.Helper()
+
+	s, err := config.LoadSettingsFrom(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := watcher.SettingsValue.Load()
+	watcher.SettingsValue.Store(s)
+	t.Cleanup(func() {
+		if prev != nil {
+			watcher.SettingsValue.Store(prev)
+		}
+	})
+}
+
+func adminStatus(minRole config.AdminRole, header, token string) int {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/x", AdminAuth(minRole), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/api/x", nil)
+	if token != "" {
+		req.Header.Set(header, token)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestAdminAuthRoles(t *testing.T) {
+	useSettings(t, map[string]string{
+		"ADMIN_TOKENS":      "ann:viewer:" + viewerToken + ",olga:operator:" + operatorToken + ",adam:admin:" + adminToken,
+		"AUTH_TOKEN_HEADER": "Authorization",
+		"AUTH_TOKEN_KEY":    proxyToken,
+	})
+
+	tokens := map[string]string{
+		"viewer":   viewerToken,
+		"operator": operatorToken,
+		"admin":    adminToken,
+	}
+	// minimum role of the endpoint -> status for each caller
+	matrix := []struct {
+		minRole config.AdminRole
+		want    map[string]int
+	}{
+		{config.RoleViewer, map[string]int{"viewer": 200, "operator": 200, "admin": 200}},
+		{config.RoleOperator, map[string]int{"viewer": 403, "operator": 200, "admin": 200}},
+		{config.RoleAdmin, map[string]int{"viewer": 403, "operator": 403, "admin": 200}},
+	}
+
+	for _, row := range matrix {
+		for caller, want := range row.want {
+			if got := adminStatus(row.minRole, "X-Admin-Token", tokens[caller]); got != want {
+				t.Errorf("%s calling a %s endpoint: status = %d, want %d", caller, row.minRole, got, want)
+			}
+		}
+
+		if got := adminStatus(row.minRole, "X-Admin-Token", ""); got != http.StatusUnauthorized {
+			t.Errorf("no token on a %s endpoint: status = %d", row.minRole, got)
+		}
+		if got := adminStatus(row.minRole, "X-Admin-Token", "wrong-token-0123456789"); got != http.StatusUnauthorized {
+			t.Errorf("wrong token on a %s endpoint: status = %d", row.minRole, got)
+		}
+		// proxy credentials never grant management access
+		if got := adminStatus(row.minRole, "Authorization", proxyToken); got != http.StatusUnauthorized {
+			t.Errorf("proxy token on a %s endpoint: status = %d", row.minRole, got)
+		}
+	}
+}
+
+func TestAdminAuthFailsClosed(t *testing.T) {
+	// no ADMIN_TOKENS: even /api/routes is closed, with or without proxy auth
+	for _, env := range []map[string]string{
+		{},
+		{"AUTH_TOKEN_HEADER": "Authorization", "AUTH_TOKEN_KEY": proxyToken},
+	} {
+		useSettings(t, env)
+		if got := adminStatus(config.RoleViewer, "Authorization", proxyToken); got != http.StatusForbidden {
+			t.Errorf("env %v: status = %d, want 403", env, got)
+		}
+	}
+}
@@ -0,0 +1,643 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/util"
+)
+
+// AnthropicRequest represents the Anthropic Messages API request format
+type AnthropicRequest struct {
+	Model         string               `json:"model"`
+	System        interface{}          `json:"system,omitempty"` // string or text blocks
+	Messages      []AnthropicMessage   `json:"messages"`
+	MaxTokens     int                  `json:"max_tokens"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Temperature   json.RawMessage      `json:"temperature,omitempty"`
+	TopP          json.RawMessage      `json:"top_p,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *AnthropicToolChoice `json:"tool_choice,omitempty"`
+	Metadata      *struct {
+		UserID string `json:"user_id,omitempty"`
+	} `json:"metadata,omitempty"`
+}
+
+// AnthropicMessage is a message of the Messages API, content is a string or blocks
+type AnthropicMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// AnthropicBlock is a content block: text, image, tool_use, tool_result or thinking
+type AnthropicBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+
+	Source *AnthropicSource `json:"source,omitempty"` // image
+
+	ID    string          `json:"id,omitempty"` // tool_use
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	ToolUseID string          `json:"tool_use_id,omitempty"` // tool_result
+	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+// AnthropicSource is the source of an image block
+type AnthropicSource struct {
+	Type      string `json:"type"` // "base64" or "url"
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// AnthropicTool is a tool definition of the Messages API
+type AnthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"input_schema,omitempty"`
+}
+
+// AnthropicToolChoice is {"type": "auto" | "any" | "tool" | "none", "name": ...}
+type AnthropicToolChoice struct {
+	Type                   string `json:"type"`
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+// AnthropicToChat converts Anthropic Messages API requests to Chat Completions API format
+// and converts Chat Completions responses and streams back to Messages API format
+func AnthropicToChat() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := ctx.GetRoute(c)
+		if route == nil || route.Transform != config.TransformAnthropicToChat {
+			c.Next()
+			return
+		}
+
+		// Only handle POST requests to /messages endpoint
+		if c.Request.Method != "POST" || !strings.HasSuffix(c.Request.URL.Path, "/messages") {
+			c.Next()
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			logger.Warnf("AnthropicToChat: failed to read request body: %v", err)
+			c.Next()
+			return
+		}
+
+		var req AnthropicRequest
+		if err := json.Unmarshal(bodyBytes, &req); err != nil {
+			logger.Warnf("AnthropicToChat: failed to parse Messages API request: %v", err)
+			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			c.Next()
+			return
+		}
+
+		newBody, err := json.Marshal(convertAnthropicToChat(&req))
+		if err != nil {
+			logger.Warnf("AnthropicToChat: failed to marshal Chat Completions request: %v", err)
+			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			c.Next()
+			return
+		}
+
+		// /v1/messages -> /v1/chat/completions
+		subPath := c.GetString(ctx.SubPath)
+		c.Set(ctx.SubPath, strings.Replace(subPath, "/messages", "/chat/completions", 1))
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(newBody))
+		c.Request.ContentLength = int64(len(newBody))
+
+		// Anthropic SDKs send the API key as x-api-key, Chat Completions upstreams expect a bearer token
+		if key := strings.TrimSpace(c.Request.Header.Get("x-api-key")); key != "" {
+			if c.Request.Header.Get("Authorization") == "" {
+				c.Request.Header.Set("Authorization", "Bearer "+key)
+			}
+			c.Request.Header.Del("x-api-key")
+		}
+		c.Request.Header.Del("anthropic-version")
+		c.Request.Header.Del("anthropic-beta")
+
+		// the response is converted, so it must not be compressed
+		c.Request.Header.Del("Accept-Encoding")
+
+		logger.Infof("AnthropicToChat: converted request for model=%s", req.Model)
+
+		w := newConvertWriter(c.Writer, &anthropicConverter{model: req.Model})
+		c.Writer = w
+
+		c.Next()
+
+		w.finish(c)
+	}
+}
+
+// convertAnthropicToChat converts a Messages API request to Chat Completions format
+func convertAnthropicToChat(req *AnthropicRequest) *ChatCompletionRequest {
+	chatReq := &ChatCompletionRequest{
+		Model:  req.Model,
+		Stream: req.Stream,
+		Params: make(map[string]json.RawMessage),
+	}
+
+	if system := anthropicSystemText(req.System); system != "" {
+		chatReq.Messages = append(chatReq.Messages, ChatMessage{Role: "system", Content: system})
+	}
+	for _, msg := range req.Messages {
+		chatReq.Messages = append(chatReq.Messages, convertAnthropicMessage(msg)...)
+	}
+
+	for _, t := range req.Tools {
+		function := map[string]interface{}{"name": t.Name}
+		if t.Description != "" {
+			function["description"] = t.Description
+		}
+		if len(t.InputSchema) > 0 {
+			function["parameters"] = t.InputSchema
+		}
+		chatReq.Tools = append(chatReq.Tools, map[string]interface{}{
+			"type":     "function",
+			"function": function,
+		})
+	}
+
+	params := chatReq.Params
+	if req.MaxTokens > 0 {
+		params["max_tokens"] = marshalRaw(req.MaxTokens)
+	}
+	if len(req.Temperature) > 0 {
+		params["temperature"] = req.Temperature
+	}
+	if len(req.TopP) > 0 {
+		params["top_p"] = req.TopP
+	}
+	if len(req.StopSequences) > 0 {
+		params["stop"] = marshalRaw(req.StopSequences)
+	}
+	if req.Metadata != nil && req.Metadata.UserID != "" {
+		params["user"] = marshalRaw(req.Metadata.UserID)
+	}
+	if req.Stream {
+		// usage is reported in message_delta
+		params["stream_options"] = json.RawMessage(`{"include_usage":true}`)
+	}
+
+	if tc := req.ToolChoice; tc != nil {
+		switch tc.Type {
+		case "auto", "none":
+			params["tool_choice"] = marshalRaw(tc.Type)
+		case "any":
+			params["tool_choice"] = marshalRaw("required")
+		case "tool":
+			params["tool_choice"] = marshalRaw(map[string]interface{}{
+				"type":     "function",
+				"function": map[string]string{"name": tc.Name},
+			})
+		}
+		if tc.DisableParallelToolUse {
+			params["parallel_tool_calls"] = json.RawMessage("false")
+		}
+	}
+
+	return chatReq
+}
+
+func marshalRaw(v interface{}) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// anthropicBlocks decodes message content, a plain string becomes a text block
+func anthropicBlocks(content json.RawMessage) []AnthropicBlock {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return []AnthropicBlock{{Type: "text", Text: text}}
+	}
+	var blocks []AnthropicBlock
+	json.Unmarshal(content, &blocks)
+	return blocks
+}
+
+// anthropicSystemText joins a system prompt given as string or text blocks
+func anthropicSystemText(system interface{}) string {
+	if system == nil {
+		return ""
+	}
+	raw, _ := json.Marshal(system)
+
+	var texts []string
+	for _, b := range anthropicBlocks(raw) {
+		if b.Type == "text" && b.Text != "" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// convertAnthropicMessage converts a message to Chat Completions messages:
+// tool_result blocks become tool messages ahead of the remaining user content,
+// tool_use blocks become tool calls of the assistant message
+func convertAnthropicMessage(msg AnthropicMessage) []ChatMessage {
+	var messages []ChatMessage
+	var texts []string
+	var parts []interface{}
+	var toolCalls []ChatToolCall
+	multimodal := false
+
+	for _, b := range anthropicBlocks(msg.Content) {
+		switch b.Type {
+		case "text":
+			texts = append(texts, b.Text)
+			parts = append(parts, map[string]interface{}{"type": "text", "text": b.Text})
+		case "image":
+			if url := anthropicImageURL(b.Source); url != "" {
+				multimodal = true
+				parts = append(parts, map[string]interface{}{
+					"type":      "image_url",
+					"image_url": map[string]interface{}{"url": url},
+				})
+			}
+		case "tool_use":
+			call := ChatToolCall{ID: b.ID, Type: "function"}
+			call.Function.Name = b.Name
+			call.Function.Arguments = "{}"
+			if len(b.Input) > 0 {
+				call.Function.Arguments = string(b.Input)
+			}
+			toolCalls = append(toolCalls, call)
+		case "tool_result":
+			messages = append(messages, ChatMessage{
+				Role:       "tool",
+				Content:    anthropicToolResultText(b),
+				ToolCallID: b.ToolUseID,
+			})
+		default:
+			// thinking blocks carry signatures only Anthropic can verify
+			logger.Debugf("AnthropicToChat: skipping %s block", b.Type)
+		}
+	}
+
+	out := ChatMessage{Role: msg.Role, ToolCalls: toolCalls}
+	switch {
+	case multimodal && msg.Role == "user":
+		out.Content = parts
+	case len(texts) > 0:
+		out.Content = strings.Join(texts, "\n")
+	}
+
+	if out.Content != nil || len(out.ToolCalls) > 0 {
+		messages = append(messages, out)
+	}
+	return messages
+}
+
+// anthropicImageURL returns the URL of an image source, base64 images as data URL
+func anthropicImageURL(src *AnthropicSource) string {
+	if src == nil {
+		return ""
+	}
+	switch src.Type {
+	case "url":
+		return src.URL
+	case "base64":
+		return "data:" + src.MediaType + ";base64," + src.Data
+	}
+	return ""
+}
+
+// anthropicToolResultText flattens tool_result content, tool messages only carry text
+func anthropicToolResultText(b AnthropicBlock) string {
+	var texts []string
+	if len(b.Content) > 0 {
+		for _, part := range anthropicBlocks(b.Content) {
+			switch part.Type {
+			case "text":
+				texts = append(texts, part.Text)
+			case "image":
+				texts = append(texts, imagePlaceholder)
+			}
+		}
+	}
+
+	text := strings.Join(texts, "\n")
+	if b.IsError {
+		text = "Error: " + text
+	}
+	return text
+}
+
+// anthropicStopReason maps a Chat Completions finish_reason to an Anthropic stop_reason
+func anthropicStopReason(finishReason string) string {
+	switch finishReason {
+	case "length":
+		return "max_tokens"
+	case "tool_calls", "function_call":
+		return "tool_use"
+	case "content_filter":
+		return "refusal"
+	}
+	return "end_turn"
+}
+
+// anthropicConverter converts Chat Completions responses to Messages API responses and events
+type anthropicConverter struct {
+	model string // requested model, used when the upstream reports none
+
+	started    bool
+	done       bool
+	id         string
+	nextIndex  int    // index of the next content block
+	openType   string // type of the open content block, "" if none
+	openTool   int    // Chat Completions index of the open tool_use block
+	stopReason string
+
+	// tool calls, by Chat Completions index and in order of appearance
+	tools     map[int]*anthropicStreamTool
+	toolOrder []int
+	usage     *ChatUsage
+}
+
+// anthropicStreamTool is a streamed tool call. Its arguments are sent as input_json_delta
+// as they arrive, once its name is known. Content blocks are sequential, so a tool call
+// that starts closes the previous one, like upstreams that send tool calls one by one.
+type anthropicStreamTool struct {
+	id      string
+	name    string
+	pending string // arguments not sent yet
+	started bool
+	closed  bool
+}
+
+// newToolUseID generates an id for tool calls the upstream sent without one
+func newToolUseID() string {
+	return "toolu_" + util.RandGenerater(util.RandAlphanumeric, 24)
+}
+
+func (a *anthropicConverter) event(typ string, data map[string]interface{}) []byte {
+	data["type"] = typ
+	b, err := json.Marshal(data)
+	if err != nil {
+		logger.Warnf("AnthropicToChat: failed to marshal event: %v", err)
+		return nil
+	}
+	return sseEvent(typ, b)
+}
+
+func (a *anthropicConverter) usageMap() map[string]interface{} {
+	usage := map[string]interface{}{"input_tokens": 0, "output_tokens": 0}
+	if a.usage != nil {
+		usage["input_tokens"] = a.usage.PromptTokens
+		usage["output_tokens"] = a.usage.CompletionTokens
+	}
+	return usage
+}
+
+func (a *anthropicConverter) convertEvent(data string) []byte {
+	if data == "[DONE]" {
+		return a.finishStream()
+	}
+
+	var chunk ChatCompletionStreamChunk
+	if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+		logger.Warnf("AnthropicToChat: failed to parse chunk: %v", err)
+		return nil
+	}
+	if a.done {
+		return nil
+	}
+
+	var out []byte
+	if chunk.Usage != nil {
+		a.usage = chunk.Usage
+	}
+
+	if !a.started {
+		a.started = true
+		a.id = "msg_" + util.RandGenerater(util.RandAlphanumeric, 24)
+		a.tools = make(map[int]*anthropicStreamTool)
+		model := chunk.Model
+		if model == "" {
+			model = a.model
+		}
+		out = append(out, a.event("message_start", map[string]interface{}{
+			"message": map[string]interface{}{
+				"id":            a.id,
+				"type":          "message",
+				"role":          "assistant",
+				"model":         model,
+				"content":       []interface{}{},
+				"stop_reason":   nil,
+				"stop_sequence": nil,
+				"usage":         a.usageMap(),
+			},
+		})...)
+	}
+
+	if len(chunk.Choices) == 0 {
+		return out
+	}
+	choice := chunk.Choices[0]
+
+	if choice.Delta.Content != "" {
+		if a.openType != "text" {
+			out = append(out, a.closeBlock()...)
+			out = append(out, a.startBlock(map[string]interface{}{"type": "text", "text": ""})...)
+		}
+		out = append(out, a.event("content_block_delta", map[string]interface{}{
+			"index": a.nextIndex - 1,
+			"delta": map[string]interface{}{"type": "text_delta", "text": choice.Delta.Content},
+		})...)
+	}
+
+	for _, tc := range choice.Delta.ToolCalls {
+		tool := a.tools[tc.Index]
+		if tool == nil {
+			tool = &anthropicStreamTool{}
+			a.tools[tc.Index] = tool
+			a.toolOrder = append(a.toolOrder, tc.Index)
+		}
+		if tool.id == "" {
+			tool.id = tc.ID
+		}
+		if tool.name == "" {
+			tool.name = tc.Function.Name
+		}
+		tool.pending += tc.Function.Arguments
+		out = append(out, a.streamTool(tc.Index, false)...)
+	}
+
+	// message_delta follows at the end of the stream, after the usage chunk
+	if choice.FinishReason != nil {
+		a.stopReason = anthropicStopReason(*choice.FinishReason)
+		out = append(out, a.flushTools()...)
+	}
+
+	return out
+}
+
+// streamTool sends the pending arguments of a tool call, opening its tool_use block
+// first. Until the name is known the arguments are held back, unless force is set.
+func (a *anthropicConverter) streamTool(index int, force bool) []byte {
+	tool := a.tools[index]
+	if tool.closed {
+		if tool.pending != "" {
+			// arguments interleaved with a later tool call, its block cannot be reopened
+			logger.Warnf("AnthropicToChat: dropping arguments of tool call %d sent after the next one started", index)
+			tool.pending = ""
+		}
+		return nil
+	}
+
+	var out []byte
+	if a.openType != "tool_use" || a.openTool != index {
+		if tool.name == "" && !force {
+			return nil
+		}
+		out = append(out, a.closeBlock()...)
+		if tool.id == "" {
+			tool.id = newToolUseID()
+		}
+		tool.started = true
+		a.openTool = index
+		out = append(out, a.startBlock(map[string]interface{}{
+			"type":  "tool_use",
+			"id":    tool.id,
+			"name":  tool.name,
+			"input": map[string]interface{}{},
+		})...)
+	}
+
+	if tool.pending != "" {
+		out = append(out, a.event("content_block_delta", map[string]interface{}{
+			"index": a.nextIndex - 1,
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": tool.pending},
+		})...)
+		tool.pending = ""
+	}
+	return out
+}
+
+// flushTools closes the open block and sends the tool calls that never got a block
+// because the upstream sent no name
+func (a *anthropicConverter) flushTools() []byte {
+	out := a.closeBlock()
+	for _, index := range a.toolOrder {
+		if !a.tools[index].started {
+			out = append(out, a.streamTool(index, true)...)
+			out = append(out, a.closeBlock()...)
+		}
+	}
+	a.toolOrder = nil
+	return out
+}
+
+func (a *anthropicConverter) startBlock(block map[string]interface{}) []byte {
+	a.openType, _ = block["type"].(string)
+	index := a.nextIndex
+	a.nextIndex++
+	return a.event("content_block_start", map[string]interface{}{
+		"index":         index,
+		"content_block": block,
+	})
+}
+
+func (a *anthropicConverter) closeBlock() []byte {
+	if a.openType == "" {
+		return nil
+	}
+	if a.openType == "tool_use" {
+		a.tools[a.openTool].closed = true
+	}
+	a.openType = ""
+	return a.event("content_block_stop", map[string]interface{}{"index": a.nextIndex - 1})
+}
+
+func (a *anthropicConverter) finishStream() []byte {
+	if !a.started || a.done {
+		return nil
+	}
+	a.done = true
+
+	stopReason := a.stopReason
+	if stopReason == "" {
+		stopReason = "end_turn"
+	}
+
+	out := a.flushTools()
+	out = append(out, a.event("message_delta", map[string]interface{}{
+		"delta": map[string]interface{}{"stop_reason": stopReason, "stop_sequence": nil},
+		"usage": a.usageMap(),
+	})...)
+	return append(out, a.event("message_stop", map[string]interface{}{})...)
+}
+
+// respondError answers in the error format of the Messages API
+func (a *anthropicConverter) respondError(c *gin.Context, code int, message string) {
+	c.JSON(code, gin.H{
+		"type":  "error",
+		"error": gin.H{"type": "api_error", "message": message},
+	})
+}
+
+func (a *anthropicConverter) convertBody(body []byte) ([]byte, bool) {
+	var cc ChatCompletion
+	if err := json.Unmarshal(body, &cc); err != nil || len(cc.Choices) == 0 {
+		return nil, false
+	}
+	a.usage = cc.Usage
+
+	choice := cc.Choices[0]
+	content := []interface{}{}
+	if choice.Message.Content != "" {
+		content = append(content, map[string]interface{}{"type": "text", "text": choice.Message.Content})
+	}
+	for _, tc := range choice.Message.ToolCalls {
+		input := json.RawMessage(tc.Function.Arguments)
+		if !json.Valid(input) {
+			input = json.RawMessage("{}")
+		}
+		id := tc.ID
+		if id == "" {
+			id = newToolUseID()
+		}
+		content = append(content, map[string]interface{}{
+			"type":  "tool_use",
+			"id":    id,
+			"name":  tc.Function.Name,
+			"input": input,
+		})
+	}
+
+	model := cc.Model
+	if model == "" {
+		model = a.model
+	}
+	out, err := json.Marshal(map[string]interface{}{
+		"id":            "msg_" + util.RandGenerater(util.RandAlphanumeric, 24),
+		"type":          "message",
+		"role":          "assistant",
+		"model":         model,
+		"content":       content,
+		"stop_reason":   anthropicStopReason(choice.FinishReason),
+		"stop_sequence": nil,
+		"usage":         a.usageMap(),
+	})
+	if err != nil {
+		logger.Warnf("AnthropicToChat: failed to marshal response: %v", err)
+		return nil, false
+	}
+	return out, true
+}
@@ -0,0 +1,249 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/poixeai/proxify/infra/config"
+)
+
+var anthropicToChatRoute = &config.Route{Name: "glm", Path: "/glm", Transform: config.TransformAnthropicToChat}
+
+func TestAnthropicToChatRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string // upstream Chat Completions body
+	}{
+		{
+			name: "system string and params",
+			body: `{"model":"glm-4","system":"be brief","max_tokens":256,"temperature":0.2,"top_p":0.9,
+				"stop_sequences":["END"],"metadata":{"user_id":"u1"},
+				"messages":[{"role":"user","content":"hi"}]}`,
+			want: `{"model":"glm-4","max_tokens":256,"temperature":0.2,"top_p":0.9,"stop":["END"],"user":"u1",
+				"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`,
+		},
+		{
+			name: "system blocks and stream",
+			body: `{"model":"glm-4","max_tokens":1,"stream":true,
+				"system":[{"type":"text","text":"a"},{"type":"text","text":"b"}],
+				"messages":[{"role":"user","content":[{"type":"text","text":"x"},{"type":"text","text":"y"}]}]}`,
+			want: `{"model":"glm-4","max_tokens":1,"stream":true,"stream_options":{"include_usage":true},
+				"messages":[{"role":"system","content":"a\nb"},{"role":"user","content":"x\ny"}]}`,
+		},
+		{
+			name: "images",
+			body: `{"model":"m","max_tokens":1,"messages":[{"role":"user","content":[
+				{"type":"text","text":"what is this"},
+				{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}},
+				{"type":"image","source":{"type":"url","url":"https://example.com/a.png"}}]}]}`,
+			want: `{"model":"m","max_tokens":1,"messages":[{"role":"user","content":[
+				{"type":"text","text":"what is this"},
+				{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}},
+				{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}]}`,
+		},
+		{
+			name: "tool use and results",
+			body: `{"model":"m","max_tokens":1,
+				"tools":[{"name":"get_weather","description":"Weather","input_schema":{"type":"object"}}],
+				"tool_choice":{"type":"any","disable_parallel_tool_use":true},
+				"messages":[
+					{"role":"user","content":"weather?"},
+					{"role":"assistant","content":[{"type":"thinking","thinking":"hm"},{"type":"text","text":"checking"},
+						{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}]},
+					{"role":"user","content":[
+						{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"sunny"}]},
+						{"type":"tool_result","tool_use_id":"toolu_2","content":"boom","is_error":true},
+						{"type":"text","text":"thanks"}]}]}`,
+			want: `{"model":"m","max_tokens":1,"tool_choice":"required","parallel_tool_calls":false,
+				"tools":[{"type":"function","function":{"name":"get_weather","description":"Weather","parameters":{"type":"object"}}}],
+				"messages":[
+					{"role":"user","content":"weather?"},
+					{"role":"assistant","content":"checking","tool_calls":[{"id":"toolu_1","type":"function",
+						"function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},
+					{"role":"tool","content":"sunny","tool_call_id":"toolu_1"},
+					{"role":"tool","content":"Error: boom","tool_call_id":"toolu_2"},
+					{"role":"user","content":"thanks"}]}`,
+		},
+		{
+			name: "named tool choice",
+			body: `{"model":"m","max_tokens":1,"tool_choice":{"type":"tool","name":"f"},"messages":[{"role":"user","content":"x"}]}`,
+			want: `{"model":"m","max_tokens":1,"tool_choice":{"type":"function","function":{"name":"f"}},
+				"messages":[{"role":"user","content":"x"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got := conversion{route: anthropicToChatRoute, path: "/v1/messages", body: tt.body}.run(t, AnthropicToChat())
+		jsonEqual(t, tt.name, got.body, tt.want)
+		if got.subPath != "/v1/chat/completions" {
+			t.Errorf("%s: sub path = %q", tt.name, got.subPath)
+		}
+	}
+}
+
+func TestAnthropicToChatHeaders(t *testing.T) {
+	got := conversion{
+		route: anthropicToChatRoute,
+		path:  "/v1/messages",
+		header: map[string]string{
+			"x-api-key":         "sk-client",
+			"anthropic-version": "2023-06-01",
+			"anthropic-beta":    "tools-2024-04-04",
+		},
+		body: `{"model":"m","max_tokens":1,"messages":[{"role":"user","content":"x"}]}`,
+	}.run(t, AnthropicToChat())
+
+	if auth := got.header.Get("Authorization"); auth != "Bearer sk-client" {
+		t.Errorf("Authorization = %q", auth)
+	}
+	for _, h := range []string{"x-api-key", "anthropic-version", "anthropic-beta"} {
+		if v := got.header.Get(h); v != "" {
+			t.Errorf("%s sent upstream: %q", h, v)
+		}
+	}
+
+	// a bearer token of the client is kept
+	got = conversion{
+		route:  anthropicToChatRoute,
+		path:   "/v1/messages",
+		header: map[string]string{"Authorization": "Bearer sk-own", "x-api-key": "sk-client"},
+		body:   `{"model":"m","max_tokens":1,"messages":[{"role":"user","content":"x"}]}`,
+	}.run(t, AnthropicToChat())
+	if auth := got.header.Get("Authorization"); auth != "Bearer sk-own" || got.header.Get("x-api-key") != "" {
+		t.Errorf("Authorization = %q, x-api-key = %q", auth, got.header.Get("x-api-key"))
+	}
+}
+
+func TestAnthropicToChatResponse(t *testing.T) {
+	got := conversion{
+		route:    anthropicToChatRoute,
+		path:     "/v1/messages",
+		body:     `{"model":"claude-alias","max_tokens":1,"messages":[{"role":"user","content":"x"}]}`,
+		respType: "application/json",
+		respBody: `{"id":"chatcmpl-1","model":"glm-4","choices":[{"index":0,"finish_reason":"tool_calls","message":{
+			"role":"assistant","content":"let me check","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+				{"type":"function","function":{"name":"get_time","arguments":"not json"}}]}}],
+			"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`,
+	}.run(t, AnthropicToChat())
+
+	var resp map[string]interface{}
+	jsonUnmarshal(t, got.resp, &resp)
+	content := resp["content"].([]interface{})
+	id, _ := content[2].(map[string]interface{})["id"].(string)
+	if !strings.HasPrefix(id, "toolu_") || len(id) != len("toolu_")+24 {
+		t.Errorf("generated tool_use id = %q", id)
+	}
+	content[2].(map[string]interface{})["id"] = "generated"
+	delete(resp, "id")
+
+	jsonEqual(t, "response", resp, `{"type":"message","role":"assistant","model":"glm-4",
+		"content":[
+			{"type":"text","text":"let me check"},
+			{"type":"tool_use","id":"call_1","name":"get_weather","input":{"city":"Paris"}},
+			{"type":"tool_use","id":"generated","name":"get_time","input":{}}],
+		"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":10,"output_tokens":5}}`)
+}
+
+func TestAnthropicToChatStream(t *testing.T) {
+	got := conversion{
+		route:    anthropicToChatRoute,
+		path:     "/v1/messages",
+		body:     `{"model":"claude-alias","max_tokens":1,"stream":true,"messages":[{"role":"user","content":"x"}]}`,
+		respType: "text/event-stream",
+		respBody: sseChunks(
+			`{"id":"c","model":"glm-4","choices":[{"index":0,"delta":{"role":"assistant","content":"Let me "}}]}`,
+			`{"id":"c","choices":[{"index":0,"delta":{"content":"check."}}]}`,
+			`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+			`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+			`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+			`{"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+			`{"id":"c","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":7,"total_tokens":17}}`,
+		),
+	}.run(t, AnthropicToChat())
+
+	var seq []string
+	events := parseSSE(t, got.resp)
+	for _, ev := range events {
+		s := ev["event"].(string)
+		if ev["type"] != s {
+			t.Errorf("event %s has type %v", s, ev["type"])
+		}
+		if d, ok := ev["delta"].(map[string]interface{}); ok && s == "content_block_delta" {
+			if d["type"] == "text_delta" {
+				s += ":" + d["text"].(string)
+			} else {
+				s += ":" + d["partial_json"].(string)
+			}
+		}
+		if b, ok := ev["content_block"].(map[string]interface{}); ok {
+			s += ":" + b["type"].(string)
+			if b["type"] == "tool_use" {
+				s += ":" + b["name"].(string)
+			}
+		}
+		seq = append(seq, s)
+	}
+
+	// tool arguments are sent as they arrive, one delta per upstream fragment
+	want := []string{
+		"message_start",
+		"content_block_start:text",
+		"content_block_delta:Let me ",
+		"content_block_delta:check.",
+		"content_block_stop",
+		"content_block_start:tool_use:get_weather",
+		`content_block_delta:{"city":`,
+		`content_block_delta:"Paris"}`,
+		"content_block_stop",
+		"content_block_start:tool_use:get_time",
+		"content_block_delta:{}",
+		"content_block_stop",
+		"message_delta",
+		"message_stop",
+	}
+	if strings.Join(seq, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(seq, "\n"), strings.Join(want, "\n"))
+	}
+
+	start := events[0]["message"].(map[string]interface{})
+	if start["model"] != "glm-4" {
+		t.Errorf("message_start model = %v", start["model"])
+	}
+	if id := events[5]["content_block"].(map[string]interface{})["id"]; id != "call_1" {
+		t.Errorf("tool_use id = %v", id)
+	}
+	if id, _ := events[9]["content_block"].(map[string]interface{})["id"].(string); !strings.HasPrefix(id, "toolu_") {
+		t.Errorf("generated tool_use id = %q", id)
+	}
+	for i, ev := range events[1:12] {
+		if idx := ev["index"].(float64); int(idx) != []int{0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2}[i] {
+			t.Errorf("event %d index = %v", i+1, idx)
+		}
+	}
+	jsonEqual(t, "message_delta", events[12], `{"event":"message_delta","type":"message_delta",
+		"delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"input_tokens":10,"output_tokens":7}}`)
+}
+
+func TestAnthropicToChatStreamToolNameLate(t *testing.T) {
+	got := conversion{
+		route:    anthropicToChatRoute,
+		path:     "/v1/messages",
+		body:     `{"model":"m","max_tokens":1,"stream":true,"messages":[{"role":"user","content":"x"}]}`,
+		respType: "text/event-stream",
+		respBody: sseChunks(
+			`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","function":{"arguments":"{\"a\""}}]}}]}`,
+			`{"id":"c","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"name":"f","arguments":":1}"}}]}}]}`,
+			`{"id":"c","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		),
+	}.run(t, AnthropicToChat())
+
+	// arguments wait for the name, which opens the block
+	events := parseSSE(t, got.resp)
+	if len(events) != 6 || events[1]["event"] != "content_block_start" ||
+		events[2]["delta"].(map[string]interface{})["partial_json"] != `{"a":1}` {
+		t.Errorf("events = %v", events)
+	}
+}
@@ -1,55 +1,93 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"net"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/watcher"
 )
 
+// Auth protects proxy traffic. The /api endpoints are protected by AdminAuth instead.
 func Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		v, exists := c.Get("auth_config")
-		if !exists {
+		if c.GetString(ctx.TopRoute) == config.APITopRoute {
 			c.Next()
 			return
 		}
 
-		cfg := v.(*config.AuthConfig)
-
-		// ===== IP Whitelist =====
-		if len(cfg.IPNets) > 0 {
-			ipStr := c.ClientIP()
-			ip := net.ParseIP(ipStr)
-			allowed := false
-
-			for _, netw := range cfg.IPNets {
-				if netw.Contains(ip) {
-					allowed = true
-					break
-				}
-			}
-
-			if !allowed {
-				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
-					"error": "IP not allowed",
-				})
-				return
-			}
+		if !authorizeProxy(c) {
+			return
 		}
 
-		// ===== Token Auth =====
-		if cfg.TokenKey != "" {
-			token := c.GetHeader(cfg.TokenHeader)
-			if token == "" || token != cfg.TokenKey {
-				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-					"error": "Invalid token",
-				})
-				return
-			}
+		c.Next()
+	}
+}
+
+// authorizeProxy checks the IP whitelist and proxy token, aborting the request on failure
+func authorizeProxy(c *gin.Context) bool {
+	// read on every request so reloaded settings apply immediately
+	cfg := watcher.GetSettings().Auth
+
+	// ===== IP Whitelist =====
+	if !ipAllowed(c, cfg) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "IP not allowed",
+		})
+		return false
+	}
+
+	// ===== Token Auth =====
+	if cfg.TokenAuthEnabled() {
+		token := c.GetHeader(cfg.TokenHeader)
+		if !checkToken(c, cfg, token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "Invalid token",
+			})
+			return false
 		}
+	}
 
-		c.Next()
+	return true
+}
+
+func ipAllowed(c *gin.Context, cfg *config.AuthConfig) bool {
+	if len(cfg.IPNets) == 0 {
+		return true
+	}
+
+	ip := net.ParseIP(c.ClientIP())
+	for _, netw := range cfg.IPNets {
+		if netw.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkToken accepts the static AUTH_TOKEN_KEY or any active stored key
+func checkToken(c *gin.Context, cfg *config.AuthConfig, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	if cfg.TokenKey != "" && subtle.ConstantTimeCompare([]byte(token), []byte(cfg.TokenKey)) == 1 {
+		return true
+	}
+
+	if cfg.Keys == nil {
+		return false
+	}
+
+	// allow "Authorization: Bearer pk-..." style headers
+	secret := strings.TrimSpace(strings.TrimPrefix(token, "Bearer "))
+	if key, ok := cfg.Keys.Lookup(secret); ok {
+		c.Set(ctx.APIKey, key)
+		return true
 	}
+	return false
 }
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/logger"
+)
+
+// BodyRules applies the route's body_rules (defaults, overrides, caps, deletions)
+// to JSON request bodies. It runs after the format conversions and ModelRewrite, so
+// rules apply to the body as sent upstream: on a chat_to_gemini route they address
+// Gemini fields, on anthropic_to_chat and responses_to_chat routes Chat Completions fields.
+func BodyRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := ctx.GetRoute(c)
+		if route == nil || len(route.BodyRules) == 0 {
+			c.Next()
+			return
+		}
+
+		// no body or a streamed upload, nothing to do
+		if c.Request.Body == nil || c.Request.Body == http.NoBody ||
+			strings.HasPrefix(c.Request.Header.Get("Content-Type"), "multipart/") {
+			c.Next()
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			logger.Warnf("BodyRules: failed to read request body: %v", err)
+			c.Next()
+			return
+		}
+
+		var body map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(bodyBytes))
+		dec.UseNumber() // keep numbers as sent
+		if err := dec.Decode(&body); err == nil && body != nil {
+			changed, skipped := config.ApplyBodyRules(body, route.BodyRules)
+			if len(skipped) > 0 {
+				logger.Warnf("BodyRules: route=%s clamp skipped, not a number: %s", route.Name, strings.Join(skipped, ", "))
+			}
+			if changed {
+				if newBody, err := json.Marshal(body); err == nil {
+					logger.Infof("BodyRules: route=%s body rewritten", route.Name)
+					bodyBytes = newBody
+				} else {
+					logger.Warnf("BodyRules: failed to encode body: %v", err)
+				}
+			}
+		}
+
+		// IMPORTANT: restore body for downstream handlers
+		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		c.Request.ContentLength = int64(len(bodyBytes))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,555 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/logger"
+)
+
+const (
+	// max_tokens is required by the Messages API, but optional in Chat Completions
+	defaultAnthropicMaxTokens = 4096
+	defaultAnthropicVersion   = "2023-06-01"
+)
+
+// ChatToAnthropic converts Chat Completions API requests to Anthropic Messages API format
+// and converts Messages API responses and streams back to Chat Completions format
+func ChatToAnthropic() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := ctx.GetRoute(c)
+		if route == nil || route.Transform != config.TransformChatToAnthropic {
+			c.Next()
+			return
+		}
+
+		// Only handle POST requests to /chat/completions endpoint
+		if c.Request.Method != "POST" || !strings.HasSuffix(c.Request.URL.Path, "/chat/completions") {
+			c.Next()
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			logger.Warnf("ChatToAnthropic: failed to read request body: %v", err)
+			c.Next()
+			return
+		}
+
+		var chatReq ChatCompletionRequest
+		if err := json.Unmarshal(bodyBytes, &chatReq); err != nil {
+			logger.Warnf("ChatToAnthropic: failed to parse Chat Completions request: %v", err)
+			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			c.Next()
+			return
+		}
+
+		newBody, err := json.Marshal(convertChatToAnthropic(&chatReq))
+		if err != nil {
+			logger.Warnf("ChatToAnthropic: failed to marshal Messages API request: %v", err)
+			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			c.Next()
+			return
+		}
+
+		// /v1/chat/completions -> /v1/messages
+		subPath := c.GetString(ctx.SubPath)
+		c.Set(ctx.SubPath, strings.Replace(subPath, "/chat/completions", "/messages", 1))
+
+		// route headers may still override the version
+		if c.Request.Header.Get("anthropic-version") == "" {
+			c.Request.Header.Set("anthropic-version", defaultAnthropicVersion)
+		}
+
+		// OpenAI SDKs send the API key as a bearer token, Anthropic expects x-api-key
+		if c.Request.Header.Get("x-api-key") == "" {
+			if key, ok := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer "); ok {
+				c.Request.Header.Set("x-api-key", strings.TrimSpace(key))
+				c.Request.Header.Del("Authorization")
+			}
+		}
+
+		// the response is converted, so it must not be compressed
+		c.Request.Header.Del("Accept-Encoding")
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(newBody))
+		c.Request.ContentLength = int64(len(newBody))
+
+		logger.Infof("ChatToAnthropic: converted request for model=%s", chatReq.Model)
+
+		var streamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		}
+		json.Unmarshal(chatReq.Params["stream_options"], &streamOptions)
+
+		w := newConvertWriter(c.Writer, &chatFromAnthropicConverter{
+			model:        chatReq.Model,
+			includeUsage: streamOptions.IncludeUsage,
+		})
+		c.Writer = w
+
+		c.Next()
+
+		w.finish(c)
+	}
+}
+
+// convertChatToAnthropic converts a Chat Completions request to Messages API format
+func convertChatToAnthropic(chatReq *ChatCompletionRequest) *AnthropicRequest {
+	req := &AnthropicRequest{
+		Model:     chatReq.Model,
+		Stream:    chatReq.Stream,
+		MaxTokens: defaultAnthropicMaxTokens,
+	}
+	params := chatReq.Params
+
+	// system and developer messages become the system prompt
+	var system []string
+	var messages []AnthropicMessage
+	var blocks []AnthropicBlock
+	role := ""
+
+	// consecutive messages of one role are merged, the Messages API expects alternating roles
+	flush := func() {
+		if len(blocks) > 0 {
+			content, _ := json.Marshal(blocks)
+			messages = append(messages, AnthropicMessage{Role: role, Content: content})
+		}
+		blocks = nil
+	}
+	add := func(r string, b ...AnthropicBlock) {
+		if r != role {
+			flush()
+			role = r
+		}
+		blocks = append(blocks, b...)
+	}
+
+	for _, msg := range chatReq.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := chatContentText(msg.Content); text != "" {
+				system = append(system, text)
+			}
+		case "tool":
+			content, _ := json.Marshal(chatContentText(msg.Content))
+			add("user", AnthropicBlock{Type: "tool_result", ToolUseID: msg.ToolCallID, Content: content})
+		case "assistant":
+			var b []AnthropicBlock
+			if text := chatContentText(msg.Content); text != "" {
+				b = append(b, AnthropicBlock{Type: "text", Text: text})
+			}
+			for _, tc := range msg.ToolCalls {
+				input := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(input) {
+					input = json.RawMessage("{}")
+				}
+				b = append(b, AnthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+			}
+			add("assistant", b...)
+		default:
+			add("user", chatContentBlocks(msg.Content)...)
+		}
+	}
+	flush()
+
+	req.Messages = messages
+	if len(system) > 0 {
+		req.System = strings.Join(system, "\n\n")
+	}
+
+	for _, tool := range chatReq.Tools {
+		var t struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name        string          `json:"name"`
+				Description string          `json:"description"`
+				Parameters  json.RawMessage `json:"parameters"`
+			} `json:"function"`
+		}
+		raw, _ := json.Marshal(tool)
+		if json.Unmarshal(raw, &t) != nil || t.Type != "function" {
+			continue
+		}
+		schema := t.Function.Parameters
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object"}`)
+		}
+		req.Tools = append(req.Tools, AnthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+
+	// max_completion_tokens replaced max_tokens in newer OpenAI clients
+	for _, key := range []string{"max_tokens", "max_completion_tokens"} {
+		var n int
+		if json.Unmarshal(params[key], &n) == nil && n > 0 {
+			req.MaxTokens = n
+		}
+	}
+	req.Temperature = params["temperature"]
+	req.TopP = params["top_p"]
+
+	var stop []string
+	if json.Unmarshal(params["stop"], &stop) != nil {
+		var s string
+		if json.Unmarshal(params["stop"], &s) == nil && s != "" {
+			stop = []string{s}
+		}
+	}
+	req.StopSequences = stop
+
+	var user string
+	if json.Unmarshal(params["user"], &user) == nil && user != "" {
+		req.Metadata = &struct {
+			UserID string `json:"user_id,omitempty"`
+		}{UserID: user}
+	}
+
+	req.ToolChoice = anthropicToolChoice(params["tool_choice"])
+	var parallel bool
+	if json.Unmarshal(params["parallel_tool_calls"], &parallel) == nil && !parallel {
+		if req.ToolChoice == nil {
+			req.ToolChoice = &AnthropicToolChoice{Type: "auto"}
+		}
+		req.ToolChoice.DisableParallelToolUse = true
+	}
+
+	return req
+}
+
+// anthropicToolChoice converts a Chat Completions tool_choice
+func anthropicToolChoice(v json.RawMessage) *AnthropicToolChoice {
+	var mode string
+	if json.Unmarshal(v, &mode) == nil {
+		switch mode {
+		case "auto", "none":
+			return &AnthropicToolChoice{Type: mode}
+		case "required":
+			return &AnthropicToolChoice{Type: "any"}
+		}
+		return nil
+	}
+
+	var choice struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if json.Unmarshal(v, &choice) == nil && choice.Function.Name != "" {
+		return &AnthropicToolChoice{Type: "tool", Name: choice.Function.Name}
+	}
+	return nil
+}
+
+// chatContentText joins the text of string or array message content
+func chatContentText(content interface{}) string {
+	var texts []string
+	for _, b := range chatContentBlocks(content) {
+		if b.Type == "text" {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// chatContentBlocks converts Chat Completions message content to text and image blocks
+func chatContentBlocks(content interface{}) []AnthropicBlock {
+	switch c := content.(type) {
+	case string:
+		if c == "" {
+			return nil
+		}
+		return []AnthropicBlock{{Type: "text", Text: c}}
+	case []interface{}:
+		var blocks []AnthropicBlock
+		for _, part := range c {
+			p, ok := part.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch p["type"] {
+			case "text":
+				if text, _ := p["text"].(string); text != "" {
+					blocks = append(blocks, AnthropicBlock{Type: "text", Text: text})
+				}
+			case "image_url":
+				image, _ := p["image_url"].(map[string]interface{})
+				url, _ := image["url"].(string)
+				if src := anthropicImageSource(url); src != nil {
+					blocks = append(blocks, AnthropicBlock{Type: "image", Source: src})
+				}
+			}
+		}
+		return blocks
+	}
+	return nil
+}
+
+// anthropicImageSource converts an image URL, data URLs become base64 sources
+func anthropicImageSource(url string) *AnthropicSource {
+	if url == "" {
+		return nil
+	}
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		mediaType, data, ok := strings.Cut(rest, ";base64,")
+		if !ok {
+			return nil
+		}
+		return &AnthropicSource{Type: "base64", MediaType: mediaType, Data: data}
+	}
+	return &AnthropicSource{Type: "url", URL: url}
+}
+
+// chatFinishReason maps an Anthropic stop_reason to a Chat Completions finish_reason
+func chatFinishReason(stopReason string) string {
+	switch stopReason {
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "refusal":
+		return "content_filter"
+	}
+	return "stop"
+}
+
+// anthropicUsage is the usage reported by the Messages API
+type anthropicUsage struct {
+	InputTokens          int `json:"input_tokens"`
+	OutputTokens         int `json:"output_tokens"`
+	CacheReadInputTokens int `json:"cache_read_input_tokens"`
+}
+
+func (u anthropicUsage) chatUsage() map[string]interface{} {
+	return map[string]interface{}{
+		"prompt_tokens":         u.InputTokens,
+		"completion_tokens":     u.OutputTokens,
+		"total_tokens":          u.InputTokens + u.OutputTokens,
+		"prompt_tokens_details": map[string]interface{}{"cached_tokens": u.CacheReadInputTokens},
+	}
+}
+
+// anthropicEvent is a Messages API stream event, only the fields used for conversion
+type anthropicEvent struct {
+	Type    string `json:"type"`
+	Index   int    `json:"index"`
+	Message struct {
+		ID    string         `json:"id"`
+		Model string         `json:"model"`
+		Usage anthropicUsage `json:"usage"`
+	} `json:"message"`
+	ContentBlock AnthropicBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		Thinking    string `json:"thinking"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta"`
+	Usage *anthropicUsage `json:"usage"`
+	Error json.RawMessage `json:"error"`
+}
+
+// chatFromAnthropicConverter converts Messages API responses to Chat Completions responses and chunks
+type chatFromAnthropicConverter struct {
+	model        string // requested model, used when the upstream reports none
+	includeUsage bool   // stream_options.include_usage of the request
+
+	started bool
+	done    bool
+	id      string
+	created int64
+	usage   anthropicUsage
+	tools   map[int]int // content block index -> tool call index
+}
+
+func (a *chatFromAnthropicConverter) chunk(delta map[string]interface{}, finishReason interface{}) []byte {
+	b, err := json.Marshal(map[string]interface{}{
+		"id":      a.id,
+		"object":  "chat.completion.chunk",
+		"created": a.created,
+		"model":   a.model,
+		"choices": []interface{}{map[string]interface{}{
+			"index":         0,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	})
+	if err != nil {
+		logger.Warnf("ChatToAnthropic: failed to marshal chunk: %v", err)
+		return nil
+	}
+	return sseData(b)
+}
+
+func (a *chatFromAnthropicConverter) convertEvent(data string) []byte {
+	var ev anthropicEvent
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		logger.Warnf("ChatToAnthropic: failed to parse event: %v", err)
+		return nil
+	}
+	if a.done {
+		return nil
+	}
+
+	switch ev.Type {
+	case "message_start":
+		a.started = true
+		a.id = ev.Message.ID
+		a.created = time.Now().Unix()
+		a.usage = ev.Message.Usage
+		a.tools = make(map[int]int)
+		if ev.Message.Model != "" {
+			a.model = ev.Message.Model
+		}
+		return a.chunk(map[string]interface{}{"role": "assistant", "content": ""}, nil)
+
+	case "content_block_start":
+		if ev.ContentBlock.Type != "tool_use" {
+			return nil
+		}
+		index := len(a.tools)
+		a.tools[ev.Index] = index
+		return a.chunk(map[string]interface{}{"tool_calls": []interface{}{map[string]interface{}{
+			"index":    index,
+			"id":       ev.ContentBlock.ID,
+			"type":     "function",
+			"function": map[string]interface{}{"name": ev.ContentBlock.Name, "arguments": ""},
+		}}}, nil)
+
+	case "content_block_delta":
+		switch ev.Delta.Type {
+		case "text_delta":
+			return a.chunk(map[string]interface{}{"content": ev.Delta.Text}, nil)
+		case "thinking_delta":
+			return a.chunk(map[string]interface{}{"reasoning_content": ev.Delta.Thinking}, nil)
+		case "input_json_delta":
+			index, ok := a.tools[ev.Index]
+			if !ok {
+				return nil
+			}
+			return a.chunk(map[string]interface{}{"tool_calls": []interface{}{map[string]interface{}{
+				"index":    index,
+				"function": map[string]interface{}{"arguments": ev.Delta.PartialJSON},
+			}}}, nil)
+		}
+
+	case "message_delta":
+		if ev.Usage != nil {
+			a.usage.OutputTokens = ev.Usage.OutputTokens
+		}
+		if ev.Delta.StopReason != "" {
+			return a.chunk(map[string]interface{}{}, chatFinishReason(ev.Delta.StopReason))
+		}
+
+	case "message_stop":
+		return a.finishStream()
+
+	case "error":
+		if len(ev.Error) > 0 {
+			return sseData([]byte(`{"error":` + string(ev.Error) + `}`))
+		}
+	}
+
+	return nil
+}
+
+func (a *chatFromAnthropicConverter) finishStream() []byte {
+	if !a.started || a.done {
+		return nil
+	}
+	a.done = true
+
+	var out []byte
+	if a.includeUsage {
+		b, err := json.Marshal(map[string]interface{}{
+			"id":      a.id,
+			"object":  "chat.completion.chunk",
+			"created": a.created,
+			"model":   a.model,
+			"choices": []interface{}{},
+			"usage":   a.usage.chatUsage(),
+		})
+		if err == nil {
+			out = sseData(b)
+		}
+	}
+	return append(out, sseData([]byte("[DONE]"))...)
+}
+
+func (a *chatFromAnthropicConverter) convertBody(body []byte) ([]byte, bool) {
+	var msg struct {
+		ID         string           `json:"id"`
+		Type       string           `json:"type"`
+		Model      string           `json:"model"`
+		Content    []AnthropicBlock `json:"content"`
+		StopReason string           `json:"stop_reason"`
+		Usage      anthropicUsage   `json:"usage"`
+	}
+	if err := json.Unmarshal(body, &msg); err != nil || msg.Type != "message" {
+		return nil, false
+	}
+
+	var texts, thinking []string
+	var toolCalls []interface{}
+	for _, b := range msg.Content {
+		switch b.Type {
+		case "text":
+			texts = append(texts, b.Text)
+		case "thinking":
+			thinking = append(thinking, b.Thinking)
+		case "tool_use":
+			input := string(b.Input)
+			if input == "" {
+				input = "{}"
+			}
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"id":       b.ID,
+				"type":     "function",
+				"function": map[string]interface{}{"name": b.Name, "arguments": input},
+			})
+		}
+	}
+
+	message := map[string]interface{}{"role": "assistant", "content": nil}
+	if len(texts) > 0 {
+		message["content"] = strings.Join(texts, "")
+	}
+	if len(thinking) > 0 {
+		message["reasoning_content"] = strings.Join(thinking, "")
+	}
+	if len(toolCalls) > 0 {
+		message["tool_calls"] = toolCalls
+	}
+
+	model := msg.Model
+	if model == "" {
+		model = a.model
+	}
+	out, err := json.Marshal(map[string]interface{}{
+		"id":      msg.ID,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []interface{}{map[string]interface{}{
+			"index":         0,
+			"message":       message,
+			"finish_reason": chatFinishReason(msg.StopReason),
+		}},
+		"usage": msg.Usage.chatUsage(),
+	})
+	if err != nil {
+		logger.Warnf("ChatToAnthropic: failed to marshal response: %v", err)
+		return nil, false
+	}
+	return out, true
+}
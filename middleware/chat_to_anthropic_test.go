@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/poixeai/proxify/infra/config"
+)
+
+var chatToAnthropicRoute = &config.Route{Name: "claude", Path: "/claude", Transform: config.TransformChatToAnthropic}
+
+func TestChatToAnthropicRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string // upstream Messages API body
+	}{
+		{
+			name: "system, default max_tokens and params",
+			body: `{"model":"claude-sonnet-4","temperature":0.5,"top_p":0.8,"stop":"END","user":"u1",
+				"messages":[{"role":"system","content":"be brief"},{"role":"developer","content":"really"},
+					{"role":"user","content":"hi"},{"role":"user","content":"there"}]}`,
+			want: `{"model":"claude-sonnet-4","system":"be brief\n\nreally","max_tokens":4096,"temperature":0.5,"top_p":0.8,
+				"stop_sequences":["END"],"metadata":{"user_id":"u1"},
+				"messages":[{"role":"user","content":[{"type":"text","text":"hi"},{"type":"text","text":"there"}]}]}`,
+		},
+		{
+			name: "max_completion_tokens and stream",
+			body: `{"model":"m","max_completion_tokens":300,"stream":true,"stop":["a","b"],"messages":[{"role":"user","content":"x"}]}`,
+			want: `{"model":"m","max_tokens":300,"stream":true,"stop_sequences":["a","b"],
+				"messages":[{"role":"user","content":[{"type":"text","text":"x"}]}]}`,
+		},
+		{
+			name: "images",
+			body: `{"model":"m","max_tokens":1,"messages":[{"role":"user","content":[
+				{"type":"text","text":"what is this"},
+				{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}},
+				{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}]}`,
+			want: `{"model":"m","max_tokens":1,"messages":[{"role":"user","content":[
+				{"type":"text","text":"what is this"},
+				{"type":"image","source":{"type":"base64","media_type":"image/png","data":"AAAA"}},
+				{"type":"image","source":{"type":"url","url":"https://example.com/a.png"}}]}]}`,
+		},
+		{
+			name: "tools, calls and results",
+			body: `{"model":"m","max_tokens":1,"tool_choice":"required","parallel_tool_calls":false,
+				"tools":[{"type":"function","function":{"name":"get_weather","description":"Weather","parameters":{"type":"object"}}},
+					{"type":"function","function":{"name":"now"}}],
+				"messages":[{"role":"user","content":"weather?"},
+					{"role":"assistant","content":"checking","tool_calls":[
+						{"id":"toolu_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+						{"id":"toolu_2","type":"function","function":{"name":"now","arguments":"oops"}}]},
+					{"role":"tool","tool_call_id":"toolu_1","content":"sunny"},
+					{"role":"tool","tool_call_id":"toolu_2","content":[{"type":"text","text":"noon"}]},
+					{"role":"user","content":"thanks"}]}`,
+			want: `{"model":"m","max_tokens":1,
+				"tools":[{"name":"get_weather","description":"Weather","input_schema":{"type":"object"}},
+					{"name":"now","input_schema":{"type":"object"}}],
+				"tool_choice":{"type":"any","disable_parallel_tool_use":true},
+				"messages":[{"role":"user","content":[{"type":"text","text":"weather?"}]},
+					{"role":"assistant","content":[{"type":"text","text":"checking"},
+						{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}},
+						{"type":"tool_use","id":"toolu_2","name":"now","input":{}}]},
+					{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"sunny"},
+						{"type":"tool_result","tool_use_id":"toolu_2","content":"noon"},
+						{"type":"text","text":"thanks"}]}]}`,
+		},
+		{
+			name: "named tool choice",
+			body: `{"model":"m","max_tokens":1,"tool_choice":{"type":"function","function":{"name":"f"}},"messages":[{"role":"user","content":"x"}]}`,
+			want: `{"model":"m","max_tokens":1,"tool_choice":{"type":"tool","name":"f"},
+				"messages":[{"role":"user","content":[{"type":"text","text":"x"}]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got := conversion{route: chatToAnthropicRoute, path: "/v1/chat/completions", body: tt.body}.run(t, ChatToAnthropic())
+		jsonEqual(t, tt.name, got.body, tt.want)
+		if got.subPath != "/v1/messages" {
+			t.Errorf("%s: sub path = %q", tt.name, got.subPath)
+		}
+	}
+}
+
+func TestChatToAnthropicHeaders(t *testing.T) {
+	got := conversion{
+		route:  chatToAnthropicRoute,
+		path:   "/v1/chat/completions",
+		header: map[string]string{"Authorization": "Bearer sk-ant"},
+		body:   `{"model":"m","messages":[{"role":"user","content":"x"}]}`,
+	}.run(t, ChatToAnthropic())
+
+	if got.header.Get("x-api-key") != "sk-ant" || got.header.Get("Authorization") != "" {
+		t.Errorf("auth headers = %v", got.header)
+	}
+	if v := got.header.Get("anthropic-version"); v != defaultAnthropicVersion {
+		t.Errorf("anthropic-version = %q", v)
+	}
+
+	got = conversion{
+		route:  chatToAnthropicRoute,
+		path:   "/v1/chat/completions",
+		header: map[string]string{"anthropic-version": "2024-01-01"},
+		body:   `{"model":"m","messages":[{"role":"user","content":"x"}]}`,
+	}.run(t, ChatToAnthropic())
+	if v := got.header.Get("anthropic-version"); v != "2024-01-01" {
+		t.Errorf("anthropic-version of the client = %q", v)
+	}
+}
+
+func TestChatToAnthropicResponse(t *testing.T) {
+	got := conversion{
+		route:    chatToAnthropicRoute,
+		path:     "/v1/chat/completions",
+		body:     `{"model":"claude-alias","messages":[{"role":"user","content":"x"}]}`,
+		respType: "application/json",
+		respBody: `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-20250514",
+			"content":[{"type":"thinking","thinking":"hm","signature":"s"},{"type":"text","text":"It is "},{"type":"text","text":"sunny."},
+				{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{"city":"Paris"}}],
+			"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":4}}`,
+	}.run(t, ChatToAnthropic())
+
+	var resp map[string]interface{}
+	jsonUnmarshal(t, got.resp, &resp)
+	delete(resp, "created")
+	jsonEqual(t, "response", resp, `{"id":"msg_1","object":"chat.completion","model":"claude-sonnet-4-20250514",
+		"choices":[{"index":0,"finish_reason":"tool_calls","message":{"role":"assistant","content":"It is sunny.",
+			"reasoning_content":"hm","tool_calls":[
+				{"id":"toolu_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]}}],
+		"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15,"prompt_tokens_details":{"cached_tokens":4}}}`)
+}
+
+func TestChatToAnthropicStream(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4","usage":{"input_tokens":10,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hm"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Checking"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":12}}`,
+		`{"type":"message_stop"}`,
+	}
+	var body strings.Builder
+	for _, ev := range events {
+		body.WriteString("event: x\ndata: " + ev + "\n\n")
+	}
+
+	got := conversion{
+		route:    chatToAnthropicRoute,
+		path:     "/v1/chat/completions",
+		body:     `{"model":"claude-alias","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"x"}]}`,
+		respType: "text/event-stream",
+		respBody: body.String(),
+	}.run(t, ChatToAnthropic())
+
+	chunks := parseSSE(t, got.resp)
+	var deltas []string
+	for _, c := range chunks {
+		if len(c) == 0 {
+			deltas = append(deltas, "[DONE]")
+			continue
+		}
+		if c["id"] != "msg_1" || c["model"] != "claude-sonnet-4" {
+			t.Errorf("chunk = %v", c)
+		}
+		choices := c["choices"].([]interface{})
+		if len(choices) == 0 {
+			deltas = append(deltas, "usage "+jsonString(c["usage"]))
+			continue
+		}
+		choice := choices[0].(map[string]interface{})
+		deltas = append(deltas, jsonString(choice["delta"])+" "+jsonString(choice["finish_reason"]))
+	}
+
+	want := []string{
+		`{"content":"","role":"assistant"} null`,
+		`{"reasoning_content":"hm"} null`,
+		`{"content":"Checking"} null`,
+		`{"tool_calls":[{"function":{"arguments":"","name":"get_weather"},"id":"toolu_1","index":0,"type":"function"}]} null`,
+		`{"tool_calls":[{"function":{"arguments":"{\"city\":"},"index":0}]} null`,
+		`{"tool_calls":[{"function":{"arguments":"\"Paris\"}"},"index":0}]} null`,
+		`{} "tool_calls"`,
+		`usage {"completion_tokens":12,"prompt_tokens":10,"prompt_tokens_details":{"cached_tokens":0},"total_tokens":22}`,
+		"[DONE]",
+	}
+	if strings.Join(deltas, "\n") != strings.Join(want, "\n") {
+		t.Errorf("chunks:\n%s\nwant:\n%s", strings.Join(deltas, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,709 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+	"github.com/poixeai/proxify/util"
+)
+
+// GeminiRequest represents a Gemini generateContent request
+type GeminiRequest struct {
+	Contents          []GeminiContent            `json:"contents"`
+	SystemInstruction *GeminiContent             `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool               `json:"tools,omitempty"`
+	ToolConfig        *GeminiToolConfig          `json:"toolConfig,omitempty"`
+	GenerationConfig  map[string]json.RawMessage `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent is a turn of the conversation, role is "user" or "model"
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is one part of a turn, exactly one of the fields is set
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FileData         *GeminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+
+	// set on functionCall parts of thinking models, must be sent back with the call
+	ThoughtSignature string `json:"thoughtSignature,omitempty"`
+}
+
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type GeminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type GeminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type GeminiFunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response"`
+}
+
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+type GeminiFunctionDeclaration struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+type GeminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode                 string   `json:"mode"`
+		AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
+	} `json:"functionCallingConfig"`
+}
+
+// geminiParams maps Chat Completions parameters to generationConfig fields.
+// stop, response_format and the token limits need conversion, see convertChatToGemini.
+var geminiParams = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "topP",
+	"n":                 "candidateCount",
+	"presence_penalty":  "presencePenalty",
+	"frequency_penalty": "frequencyPenalty",
+	"seed":              "seed",
+	"logprobs":          "responseLogprobs",
+	"top_logprobs":      "logprobs",
+}
+
+// ChatToGemini converts Chat Completions API requests to Gemini generateContent format
+// and converts Gemini responses and streams back to Chat Completions format
+func ChatToGemini() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := ctx.GetRoute(c)
+		if route == nil || route.Transform != config.TransformChatToGemini {
+			c.Next()
+			return
+		}
+
+		// Only handle POST requests to /chat/completions endpoint
+		if c.Request.Method != "POST" || !strings.HasSuffix(c.Request.URL.Path, "/chat/completions") {
+			c.Next()
+			return
+		}
+
+		bodyBytes, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			logger.Warnf("ChatToGemini: failed to read request body: %v", err)
+			c.Next()
+			return
+		}
+
+		var chatReq ChatCompletionRequest
+		if err := json.Unmarshal(bodyBytes, &chatReq); err != nil {
+			logger.Warnf("ChatToGemini: failed to parse Chat Completions request: %v", err)
+			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			c.Next()
+			return
+		}
+
+		geminiReq, err := convertChatToGemini(&chatReq)
+		if err != nil {
+			logger.Warnf("ChatToGemini: invalid Chat Completions request: %v", err)
+			response.RespondError(c, http.StatusBadRequest, err.Error(), response.INVALID_REQUEST_ERROR)
+			c.Abort()
+			return
+		}
+
+		newBody, err := json.Marshal(geminiReq)
+		if err != nil {
+			logger.Warnf("ChatToGemini: failed to marshal generateContent request: %v", err)
+			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			c.Next()
+			return
+		}
+
+		// the model moves into the path, where ModelRewrite still applies model_map
+		c.Set(ctx.SubPath, geminiSubPath(c.GetString(ctx.SubPath), chatReq.Model, chatReq.Stream))
+
+		// OpenAI SDKs send the API key as a bearer token, Gemini expects x-goog-api-key
+		if c.Request.Header.Get("x-goog-api-key") == "" {
+			if key, ok := strings.CutPrefix(c.Request.Header.Get("Authorization"), "Bearer "); ok {
+				c.Request.Header.Set("x-goog-api-key", strings.TrimSpace(key))
+				c.Request.Header.Del("Authorization")
+			}
+		}
+
+		// the response is converted, so it must not be compressed
+		c.Request.Header.Del("Accept-Encoding")
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(newBody))
+		c.Request.ContentLength = int64(len(newBody))
+
+		logger.Infof("ChatToGemini: converted request for model=%s", chatReq.Model)
+
+		var streamOptions struct {
+			IncludeUsage bool `json:"include_usage"`
+		}
+		json.Unmarshal(chatReq.Params["stream_options"], &streamOptions)
+
+		w := newConvertWriter(c.Writer, &chatFromGeminiConverter{
+			model:        chatReq.Model,
+			restoreModel: route.RestoreModel,
+			includeUsage: streamOptions.IncludeUsage,
+		})
+		c.Writer = w
+
+		c.Next()
+
+		w.finish(c)
+	}
+}
+
+// geminiSubPath builds the generateContent path for a /chat/completions sub path,
+// the query string of the client (e.g. ?key=) is kept
+func geminiSubPath(subPath, model string, stream bool) string {
+	_, query, _ := strings.Cut(subPath, "?")
+
+	model = strings.TrimPrefix(model, "models/")
+	p := "/v1beta/models/" + url.PathEscape(model)
+	if !stream {
+		p += ":generateContent"
+		if query != "" {
+			p += "?" + query
+		}
+		return p
+	}
+
+	p += ":streamGenerateContent?alt=sse"
+	if query != "" {
+		p += "&" + query
+	}
+	return p
+}
+
+// geminiSignatureSep separates a tool call id from the thoughtSignature of its
+// functionCall part. Chat Completions has no field for the signature, so it travels
+// in the id, which clients send back unchanged with the assistant message.
+const geminiSignatureSep = "__ts__"
+
+// splitToolCallID returns the Gemini call id and the thoughtSignature encoded in a tool call id
+func splitToolCallID(id string) (string, string) {
+	callID, signature, _ := strings.Cut(id, geminiSignatureSep)
+	return callID, signature
+}
+
+// convertChatToGemini converts a Chat Completions request to generateContent format.
+// It fails for tool messages that answer no tool call, Gemini needs the function name.
+func convertChatToGemini(chatReq *ChatCompletionRequest) (*GeminiRequest, error) {
+	req := &GeminiRequest{Contents: []GeminiContent{}}
+	params := chatReq.Params
+
+	var system []GeminiPart
+	toolNames := make(map[string]string) // tool call id -> function name
+
+	// consecutive turns of one role are merged, function responses of parallel calls share a turn
+	add := func(role string, parts ...GeminiPart) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(req.Contents); n > 0 && req.Contents[n-1].Role == role {
+			req.Contents[n-1].Parts = append(req.Contents[n-1].Parts, parts...)
+			return
+		}
+		req.Contents = append(req.Contents, GeminiContent{Role: role, Parts: parts})
+	}
+
+	for _, msg := range chatReq.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			if text := chatContentText(msg.Content); text != "" {
+				system = append(system, GeminiPart{Text: text})
+			}
+		case "tool":
+			name, ok := toolNames[msg.ToolCallID]
+			if !ok {
+				return nil, fmt.Errorf("The tool message with tool_call_id `%s` does not answer a tool call of an earlier assistant message.", msg.ToolCallID)
+			}
+			callID, _ := splitToolCallID(msg.ToolCallID)
+			add("user", GeminiPart{FunctionResponse: &GeminiFunctionResponse{
+				ID:       callID,
+				Name:     name,
+				Response: geminiFunctionResponse(chatContentText(msg.Content)),
+			}})
+		case "assistant":
+			var parts []GeminiPart
+			if text := chatContentText(msg.Content); text != "" {
+				parts = append(parts, GeminiPart{Text: text})
+			}
+			for _, tc := range msg.ToolCalls {
+				toolNames[tc.ID] = tc.Function.Name
+				args := json.RawMessage(tc.Function.Arguments)
+				if !json.Valid(args) {
+					args = json.RawMessage("{}")
+				}
+				callID, signature := splitToolCallID(tc.ID)
+				parts = append(parts, GeminiPart{
+					FunctionCall: &GeminiFunctionCall{
+						ID:   callID,
+						Name: tc.Function.Name,
+						Args: args,
+					},
+					ThoughtSignature: signature,
+				})
+			}
+			add("model", parts...)
+		default:
+			add("user", geminiParts(msg.Content)...)
+		}
+	}
+
+	if len(system) > 0 {
+		req.SystemInstruction = &GeminiContent{Parts: system}
+	}
+
+	var declarations []GeminiFunctionDeclaration
+	for _, tool := range chatReq.Tools {
+		var t struct {
+			Type     string `json:"type"`
+			Function struct {
+				Name        string                 `json:"name"`
+				Description string                 `json:"description"`
+				Parameters  map[string]interface{} `json:"parameters"`
+			} `json:"function"`
+		}
+		raw, _ := json.Marshal(tool)
+		if json.Unmarshal(raw, &t) != nil || t.Type != "function" {
+			continue
+		}
+		decl := GeminiFunctionDeclaration{Name: t.Function.Name, Description: t.Function.Description}
+		// Gemini rejects object schemas without properties, functions without arguments have none
+		if props, _ := t.Function.Parameters["properties"].(map[string]interface{}); len(props) > 0 {
+			decl.Parameters, _ = json.Marshal(geminiSchema(t.Function.Parameters))
+		}
+		declarations = append(declarations, decl)
+	}
+	if len(declarations) > 0 {
+		req.Tools = []GeminiTool{{FunctionDeclarations: declarations}}
+		req.ToolConfig = geminiToolConfig(params["tool_choice"])
+	}
+
+	gen := make(map[string]json.RawMessage)
+	for _, key := range sortedKeys(params) {
+		if to, ok := geminiParams[key]; ok && string(params[key]) != "null" {
+			gen[to] = params[key]
+		}
+	}
+
+	// max_completion_tokens replaced max_tokens in newer OpenAI clients
+	for _, key := range []string{"max_tokens", "max_completion_tokens"} {
+		var n int
+		if json.Unmarshal(params[key], &n) == nil && n > 0 {
+			gen["maxOutputTokens"] = params[key]
+		}
+	}
+
+	var stop []string
+	if json.Unmarshal(params["stop"], &stop) != nil {
+		var s string
+		if json.Unmarshal(params["stop"], &s) == nil && s != "" {
+			stop = []string{s}
+		}
+	}
+	if len(stop) > 0 {
+		gen["stopSequences"], _ = json.Marshal(stop)
+	}
+
+	var format struct {
+		Type       string `json:"type"`
+		JSONSchema struct {
+			Schema map[string]interface{} `json:"schema"`
+		} `json:"json_schema"`
+	}
+	if json.Unmarshal(params["response_format"], &format) == nil {
+		switch format.Type {
+		case "json_object":
+			gen["responseMimeType"] = json.RawMessage(`"application/json"`)
+		case "json_schema":
+			gen["responseMimeType"] = json.RawMessage(`"application/json"`)
+			if format.JSONSchema.Schema != nil {
+				gen["responseSchema"], _ = json.Marshal(geminiSchema(format.JSONSchema.Schema))
+			}
+		}
+	}
+
+	if len(gen) > 0 {
+		req.GenerationConfig = gen
+	}
+
+	return req, nil
+}
+
+// geminiParts converts Chat Completions user content to text, inlineData and fileData parts
+func geminiParts(content interface{}) []GeminiPart {
+	var parts []GeminiPart
+	for _, b := range chatContentBlocks(content) {
+		switch {
+		case b.Type == "text":
+			parts = append(parts, GeminiPart{Text: b.Text})
+		case b.Source != nil && b.Source.Type == "base64":
+			parts = append(parts, GeminiPart{InlineData: &GeminiBlob{MimeType: b.Source.MediaType, Data: b.Source.Data}})
+		case b.Source != nil:
+			parts = append(parts, GeminiPart{FileData: &GeminiFileData{
+				MimeType: mime.TypeByExtension(path.Ext(strings.SplitN(b.Source.URL, "?", 2)[0])),
+				FileURI:  b.Source.URL,
+			}})
+		}
+	}
+	return parts
+}
+
+// geminiFunctionResponse wraps a tool result, the response of a function must be a JSON object
+func geminiFunctionResponse(text string) json.RawMessage {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal([]byte(text), &obj) == nil {
+		return json.RawMessage(text)
+	}
+	out, _ := json.Marshal(map[string]string{"content": text})
+	return out
+}
+
+// geminiSchema removes the JSON Schema keywords Gemini does not accept
+func geminiSchema(v interface{}) interface{} {
+	switch s := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(s))
+		for k, sv := range s {
+			if k == "$schema" || k == "additionalProperties" || k == "strict" {
+				continue
+			}
+			out[k] = geminiSchema(sv)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(s))
+		for i, sv := range s {
+			out[i] = geminiSchema(sv)
+		}
+		return out
+	}
+	return v
+}
+
+// geminiToolConfig converts a Chat Completions tool_choice
+func geminiToolConfig(v json.RawMessage) *GeminiToolConfig {
+	cfg := &GeminiToolConfig{}
+
+	var mode string
+	if json.Unmarshal(v, &mode) == nil {
+		switch mode {
+		case "auto":
+			cfg.FunctionCallingConfig.Mode = "AUTO"
+		case "none":
+			cfg.FunctionCallingConfig.Mode = "NONE"
+		case "required":
+			cfg.FunctionCallingConfig.Mode = "ANY"
+		default:
+			return nil
+		}
+		return cfg
+	}
+
+	var choice struct {
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if json.Unmarshal(v, &choice) == nil && choice.Function.Name != "" {
+		cfg.FunctionCallingConfig.Mode = "ANY"
+		cfg.FunctionCallingConfig.AllowedFunctionNames = []string{choice.Function.Name}
+		return cfg
+	}
+	return nil
+}
+
+// geminiFinishReason maps a Gemini finishReason to a Chat Completions finish_reason
+func geminiFinishReason(reason string, toolCalls bool) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "IMAGE_SAFETY":
+		return "content_filter"
+	}
+	if toolCalls {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+// geminiUsage is the usageMetadata of a Gemini response
+type geminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+func (u geminiUsage) chatUsage() map[string]interface{} {
+	// thinking tokens are billed as output, like reasoning tokens in Chat Completions
+	completion := u.CandidatesTokenCount + u.ThoughtsTokenCount
+	total := u.TotalTokenCount
+	if total == 0 {
+		total = u.PromptTokenCount + completion
+	}
+	return map[string]interface{}{
+		"prompt_tokens":             u.PromptTokenCount,
+		"completion_tokens":         completion,
+		"total_tokens":              total,
+		"prompt_tokens_details":     map[string]interface{}{"cached_tokens": u.CachedContentTokenCount},
+		"completion_tokens_details": map[string]interface{}{"reasoning_tokens": u.ThoughtsTokenCount},
+	}
+}
+
+// geminiResponse is a generateContent response, also sent as each event of a stream
+type geminiResponse struct {
+	Candidates []struct {
+		Index        int           `json:"index"`
+		Content      GeminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	UsageMetadata *geminiUsage `json:"usageMetadata"`
+	ModelVersion  string       `json:"modelVersion"`
+	ResponseID    string       `json:"responseId"`
+}
+
+// geminiToolCall converts a functionCall part, Gemini only sometimes returns call ids.
+// The thoughtSignature of the part is kept in the id, see geminiSignatureSep.
+func geminiToolCall(part GeminiPart) map[string]interface{} {
+	fc := part.FunctionCall
+	id := fc.ID
+	if id == "" {
+		id = "call_" + util.RandGenerater(util.RandAlphanumeric, 24)
+	}
+	if part.ThoughtSignature != "" {
+		id += geminiSignatureSep + part.ThoughtSignature
+	}
+	args := string(fc.Args)
+	if args == "" || args == "null" {
+		args = "{}"
+	}
+	return map[string]interface{}{
+		"id":       id,
+		"type":     "function",
+		"function": map[string]interface{}{"name": fc.Name, "arguments": args},
+	}
+}
+
+// chatFromGeminiConverter converts Gemini responses to Chat Completions responses and chunks
+type chatFromGeminiConverter struct {
+	model        string // requested model, used when the upstream reports none
+	includeUsage bool   // stream_options.include_usage of the request
+
+	// report the requested model instead of modelVersion, stream.RestoreModel
+	// only rewrites "model" fields and Gemini responses have none
+	restoreModel bool
+
+	started   bool
+	done      bool
+	id        string
+	created   int64
+	usage     *geminiUsage
+	toolCalls map[int]int // candidate index -> tool calls sent
+}
+
+func (g *chatFromGeminiConverter) chunk(index int, delta map[string]interface{}, finishReason interface{}) []byte {
+	b, err := json.Marshal(map[string]interface{}{
+		"id":      g.id,
+		"object":  "chat.completion.chunk",
+		"created": g.created,
+		"model":   g.model,
+		"choices": []interface{}{map[string]interface{}{
+			"index":         index,
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	})
+	if err != nil {
+		logger.Warnf("ChatToGemini: failed to marshal chunk: %v", err)
+		return nil
+	}
+	return sseData(b)
+}
+
+func (g *chatFromGeminiConverter) convertEvent(data string) []byte {
+	var resp geminiResponse
+	if err := json.Unmarshal([]byte(data), &resp); err != nil {
+		logger.Warnf("ChatToGemini: failed to parse event: %v", err)
+		return nil
+	}
+	if g.done {
+		return nil
+	}
+
+	var out []byte
+	if !g.started {
+		g.started = true
+		g.id = resp.ResponseID
+		if g.id == "" {
+			g.id = "chatcmpl-" + util.RandGenerater(util.RandAlphanumeric, 24)
+		}
+		g.created = time.Now().Unix()
+		g.toolCalls = make(map[int]int)
+		if resp.ModelVersion != "" && !g.restoreModel {
+			g.model = resp.ModelVersion
+		}
+	}
+	if resp.UsageMetadata != nil {
+		g.usage = resp.UsageMetadata
+	}
+
+	for _, cand := range resp.Candidates {
+		if _, ok := g.toolCalls[cand.Index]; !ok {
+			g.toolCalls[cand.Index] = 0
+			out = append(out, g.chunk(cand.Index, map[string]interface{}{"role": "assistant", "content": ""}, nil)...)
+		}
+
+		for _, part := range cand.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				// Gemini streams function calls whole, not as argument deltas
+				call := geminiToolCall(part)
+				call["index"] = g.toolCalls[cand.Index]
+				g.toolCalls[cand.Index]++
+				out = append(out, g.chunk(cand.Index, map[string]interface{}{"tool_calls": []interface{}{call}}, nil)...)
+			case part.Thought && part.Text != "":
+				out = append(out, g.chunk(cand.Index, map[string]interface{}{"reasoning_content": part.Text}, nil)...)
+			case part.Text != "":
+				out = append(out, g.chunk(cand.Index, map[string]interface{}{"content": part.Text}, nil)...)
+			}
+		}
+
+		if cand.FinishReason != "" {
+			reason := geminiFinishReason(cand.FinishReason, g.toolCalls[cand.Index] > 0)
+			out = append(out, g.chunk(cand.Index, map[string]interface{}{}, reason)...)
+		}
+	}
+
+	return out
+}
+
+// finishStream ends the stream, Gemini streams have no terminating event
+func (g *chatFromGeminiConverter) finishStream() []byte {
+	if !g.started || g.done {
+		return nil
+	}
+	g.done = true
+
+	var out []byte
+	if g.includeUsage && g.usage != nil {
+		b, err := json.Marshal(map[string]interface{}{
+			"id":      g.id,
+			"object":  "chat.completion.chunk",
+			"created": g.created,
+			"model":   g.model,
+			"choices": []interface{}{},
+			"usage":   g.usage.chatUsage(),
+		})
+		if err == nil {
+			out = sseData(b)
+		}
+	}
+	return append(out, sseData([]byte("[DONE]"))...)
+}
+
+func (g *chatFromGeminiConverter) convertBody(body []byte) ([]byte, bool) {
+	var resp geminiResponse
+	if err := json.Unmarshal(body, &resp); err != nil || (resp.Candidates == nil && resp.UsageMetadata == nil) {
+		return nil, false
+	}
+
+	sort.SliceStable(resp.Candidates, func(i, j int) bool {
+		return resp.Candidates[i].Index < resp.Candidates[j].Index
+	})
+
+	choices := make([]interface{}, 0, len(resp.Candidates))
+	for _, cand := range resp.Candidates {
+		var texts, thoughts []string
+		var toolCalls []interface{}
+		for _, part := range cand.Content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				toolCalls = append(toolCalls, geminiToolCall(part))
+			case part.Thought:
+				thoughts = append(thoughts, part.Text)
+			case part.Text != "":
+				texts = append(texts, part.Text)
+			}
+		}
+
+		message := map[string]interface{}{"role": "assistant", "content": nil}
+		if len(texts) > 0 {
+			message["content"] = strings.Join(texts, "")
+		}
+		if len(thoughts) > 0 {
+			message["reasoning_content"] = strings.Join(thoughts, "")
+		}
+		if len(toolCalls) > 0 {
+			message["tool_calls"] = toolCalls
+		}
+
+		choices = append(choices, map[string]interface{}{
+			"index":         cand.Index,
+			"message":       message,
+			"finish_reason": geminiFinishReason(cand.FinishReason, len(toolCalls) > 0),
+		})
+	}
+
+	model := resp.ModelVersion
+	if model == "" || g.restoreModel {
+		model = g.model
+	}
+	id := resp.ResponseID
+	if id == "" {
+		id = "chatcmpl-" + util.RandGenerater(util.RandAlphanumeric, 24)
+	}
+
+	completion := map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": choices,
+	}
+	if resp.UsageMetadata != nil {
+		completion["usage"] = resp.UsageMetadata.chatUsage()
+	}
+
+	out, err := json.Marshal(completion)
+	if err != nil {
+		logger.Warnf("ChatToGemini: failed to marshal response: %v", err)
+		return nil, false
+	}
+	return out, true
+}
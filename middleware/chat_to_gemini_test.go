@@ -0,0 +1,207 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/poixeai/proxify/infra/config"
+)
+
+var chatToGeminiRoute = &config.Route{Name: "gemini", Path: "/gemini", Transform: config.TransformChatToGemini}
+
+func TestChatToGeminiRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string // upstream generateContent body
+	}{
+		{
+			name: "system, turns and params",
+			body: `{"model":"gemini-2.5-flash","temperature":0.3,"top_p":0.9,"n":2,"seed":7,"max_tokens":100,
+				"stop":"END","presence_penalty":null,"user":"u1",
+				"messages":[{"role":"system","content":"be brief"},{"role":"developer","content":"really"},
+					{"role":"user","content":"hi"},{"role":"assistant","content":"hello"},{"role":"user","content":"bye"}]}`,
+			want: `{"contents":[{"role":"user","parts":[{"text":"hi"}]},{"role":"model","parts":[{"text":"hello"}]},
+					{"role":"user","parts":[{"text":"bye"}]}],
+				"systemInstruction":{"parts":[{"text":"be brief"},{"text":"really"}]},
+				"generationConfig":{"temperature":0.3,"topP":0.9,"candidateCount":2,"seed":7,
+					"maxOutputTokens":100,"stopSequences":["END"]}}`,
+		},
+		{
+			name: "images",
+			body: `{"model":"m","max_completion_tokens":5,"messages":[{"role":"user","content":[
+				{"type":"text","text":"what is this"},
+				{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}},
+				{"type":"image_url","image_url":{"url":"https://example.com/a.jpg?x=1"}}]}]}`,
+			want: `{"contents":[{"role":"user","parts":[{"text":"what is this"},
+					{"inlineData":{"mimeType":"image/png","data":"AAAA"}},
+					{"fileData":{"mimeType":"image/jpeg","fileUri":"https://example.com/a.jpg?x=1"}}]}],
+				"generationConfig":{"maxOutputTokens":5}}`,
+		},
+		{
+			name: "tools, calls and results",
+			body: `{"model":"m","tool_choice":"required","tools":[
+					{"type":"function","function":{"name":"get_weather","description":"Weather","parameters":{
+						"$schema":"x","type":"object","additionalProperties":false,
+						"properties":{"city":{"type":"string"}}}}},
+					{"type":"function","function":{"name":"now","parameters":{"type":"object","properties":{}}}}],
+				"messages":[{"role":"user","content":"weather?"},
+					{"role":"assistant","content":null,"tool_calls":[
+						{"id":"call_1__ts__c2lnMQ==","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+						{"id":"call_2","type":"function","function":{"name":"now","arguments":""}}]},
+					{"role":"tool","tool_call_id":"call_1__ts__c2lnMQ==","content":"sunny"},
+					{"role":"tool","tool_call_id":"call_2","content":"{\"time\":\"noon\"}"}]}`,
+			want: `{"contents":[{"role":"user","parts":[{"text":"weather?"}]},
+					{"role":"model","parts":[
+						{"functionCall":{"id":"call_1","name":"get_weather","args":{"city":"Paris"}},"thoughtSignature":"c2lnMQ=="},
+						{"functionCall":{"id":"call_2","name":"now","args":{}}}]},
+					{"role":"user","parts":[
+						{"functionResponse":{"id":"call_1","name":"get_weather","response":{"content":"sunny"}}},
+						{"functionResponse":{"id":"call_2","name":"now","response":{"time":"noon"}}}]}],
+				"tools":[{"functionDeclarations":[
+					{"name":"get_weather","description":"Weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}},
+					{"name":"now"}]}],
+				"toolConfig":{"functionCallingConfig":{"mode":"ANY"}}}`,
+		},
+		{
+			name: "named tool choice",
+			body: `{"model":"m","tool_choice":{"type":"function","function":{"name":"f"}},
+				"tools":[{"type":"function","function":{"name":"f"}}],"messages":[{"role":"user","content":"x"}]}`,
+			want: `{"contents":[{"role":"user","parts":[{"text":"x"}]}],"tools":[{"functionDeclarations":[{"name":"f"}]}],
+				"toolConfig":{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["f"]}}}`,
+		},
+		{
+			name: "json schema response format",
+			body: `{"model":"m","stop":["a","b"],"response_format":{"type":"json_schema","json_schema":{"name":"x","strict":true,
+				"schema":{"type":"object","additionalProperties":false,"properties":{"n":{"type":"integer"}}}}},
+				"messages":[{"role":"user","content":"x"}]}`,
+			want: `{"contents":[{"role":"user","parts":[{"text":"x"}]}],"generationConfig":{"stopSequences":["a","b"],
+				"responseMimeType":"application/json","responseSchema":{"type":"object","properties":{"n":{"type":"integer"}}}}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got := conversion{route: chatToGeminiRoute, path: "/v1/chat/completions", body: tt.body}.run(t, ChatToGemini())
+		jsonEqual(t, tt.name, got.body, tt.want)
+	}
+}
+
+func TestChatToGeminiPathAndHeaders(t *testing.T) {
+	tests := []struct {
+		path, body, want string
+	}{
+		{"/v1/chat/completions", `{"model":"gemini-2.5-pro","messages":[]}`, "/v1beta/models/gemini-2.5-pro:generateContent"},
+		{"/v1/chat/completions?key=k", `{"model":"models/gemini-2.5-pro","messages":[]}`, "/v1beta/models/gemini-2.5-pro:generateContent?key=k"},
+		{"/v1/chat/completions?key=k", `{"model":"gemini-2.5-pro","stream":true,"messages":[]}`,
+			"/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse&key=k"},
+	}
+	for _, tt := range tests {
+		got := conversion{
+			route:  chatToGeminiRoute,
+			path:   tt.path,
+			header: map[string]string{"Authorization": "Bearer sk-client"},
+			body:   tt.body,
+		}.run(t, ChatToGemini())
+		if got.subPath != tt.want {
+			t.Errorf("sub path = %q, want %q", got.subPath, tt.want)
+		}
+		if got.header.Get("x-goog-api-key") != "sk-client" || got.header.Get("Authorization") != "" {
+			t.Errorf("headers = %v", got.header)
+		}
+	}
+}
+
+func TestChatToGeminiUnknownToolCall(t *testing.T) {
+	got := conversion{
+		route: chatToGeminiRoute,
+		path:  "/v1/chat/completions",
+		body: `{"model":"m","messages":[{"role":"user","content":"x"},
+			{"role":"tool","tool_call_id":"call_unknown","content":"result"}]}`,
+	}.run(t, ChatToGemini())
+
+	if got.status != http.StatusBadRequest || !strings.Contains(got.resp, "call_unknown") || got.body != nil {
+		t.Errorf("status = %d, response = %s, upstream body = %v", got.status, got.resp, got.body)
+	}
+}
+
+func TestChatToGeminiResponse(t *testing.T) {
+	got := conversion{
+		route:    chatToGeminiRoute,
+		path:     "/v1/chat/completions",
+		body:     `{"model":"gemini-alias","messages":[{"role":"user","content":"x"}]}`,
+		respType: "application/json",
+		respBody: `{"responseId":"r1","modelVersion":"gemini-2.5-flash","candidates":[{"index":0,"finishReason":"STOP",
+			"content":{"role":"model","parts":[{"text":"thinking...","thought":true},{"text":"It is "},{"text":"sunny."},
+				{"functionCall":{"id":"fc1","name":"get_weather","args":{"city":"Paris"}},"thoughtSignature":"c2ln"},
+				{"functionCall":{"name":"now"}}]}}],
+			"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":5,"thoughtsTokenCount":3,"totalTokenCount":18}}`,
+	}.run(t, ChatToGemini())
+
+	var resp map[string]interface{}
+	jsonUnmarshal(t, got.resp, &resp)
+	calls := resp["choices"].([]interface{})[0].(map[string]interface{})["message"].(map[string]interface{})["tool_calls"].([]interface{})
+	if id := calls[1].(map[string]interface{})["id"].(string); !strings.HasPrefix(id, "call_") {
+		t.Errorf("generated tool call id = %q", id)
+	}
+	calls[1].(map[string]interface{})["id"] = "generated"
+	delete(resp, "created")
+
+	jsonEqual(t, "response", resp, `{"id":"r1","object":"chat.completion","model":"gemini-2.5-flash","choices":[{"index":0,
+		"finish_reason":"tool_calls","message":{"role":"assistant","content":"It is sunny.","reasoning_content":"thinking...",
+		"tool_calls":[
+			{"id":"fc1__ts__c2ln","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+			{"id":"generated","type":"function","function":{"name":"now","arguments":"{}"}}]}}],
+		"usage":{"prompt_tokens":10,"completion_tokens":8,"total_tokens":18,
+			"prompt_tokens_details":{"cached_tokens":0},"completion_tokens_details":{"reasoning_tokens":3}}}`)
+}
+
+func TestChatToGeminiStream(t *testing.T) {
+	route := *chatToGeminiRoute
+	route.RestoreModel = true
+	got := conversion{
+		route:    &route,
+		path:     "/v1/chat/completions",
+		body:     `{"model":"gemini-alias","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"x"}]}`,
+		respType: "text/event-stream",
+		respBody: "data: " + `{"responseId":"r1","modelVersion":"gemini-2.5-flash","candidates":[{"index":0,"content":{"role":"model","parts":[{"text":"hm","thought":true}]}}]}` + "\r\n\r\n" +
+			"data: " + `{"responseId":"r1","candidates":[{"index":0,"content":{"role":"model","parts":[{"text":"Hello"}]}}]}` + "\r\n\r\n" +
+			"data: " + `{"responseId":"r1","candidates":[{"index":0,"content":{"role":"model","parts":[{"functionCall":{"name":"f","args":{"a":1}},"thoughtSignature":"c2ln"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":2,"totalTokenCount":6}}` + "\r\n\r\n",
+	}.run(t, ChatToGemini())
+
+	events := parseSSE(t, got.resp)
+	if len(events) != 7 || len(events[6]) != 0 {
+		t.Fatalf("events = %v", events)
+	}
+	deltas := make([]string, 0, 4)
+	for _, ev := range events[:5] {
+		if ev["id"] != "r1" || ev["model"] != "gemini-alias" || ev["object"] != "chat.completion.chunk" {
+			t.Errorf("chunk = %v", ev)
+		}
+		choice := ev["choices"].([]interface{})[0].(map[string]interface{})
+		delta := choice["delta"].(map[string]interface{})
+		if calls, ok := delta["tool_calls"].([]interface{}); ok {
+			call := calls[0].(map[string]interface{})
+			id, _ := call["id"].(string)
+			if !strings.HasPrefix(id, "call_") || !strings.HasSuffix(id, "__ts__c2ln") || call["index"] != float64(0) {
+				t.Errorf("tool call = %v", call)
+			}
+			delete(call, "id")
+		}
+		deltas = append(deltas, jsonString(delta)+" "+jsonString(choice["finish_reason"]))
+	}
+
+	want := []string{
+		`{"content":"","role":"assistant"} null`,
+		`{"reasoning_content":"hm"} null`,
+		`{"content":"Hello"} null`,
+		`{"tool_calls":[{"function":{"arguments":"{\"a\":1}","name":"f"},"index":0,"type":"function"}]} null`,
+		`{} "tool_calls"`,
+	}
+	if strings.Join(deltas, "\n") != strings.Join(want, "\n") {
+		t.Errorf("deltas:\n%s\nwant:\n%s", strings.Join(deltas, "\n"), strings.Join(want, "\n"))
+	}
+
+	jsonEqual(t, "usage", events[5]["usage"], `{"prompt_tokens":4,"completion_tokens":2,"total_tokens":6,
+		"prompt_tokens_details":{"cached_tokens":0},"completion_tokens_details":{"reasoning_tokens":0}}`)
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+)
+
+// conversion is a request through a converting middleware to a fake upstream
+type conversion struct {
+	route    *config.Route
+	path     string // request path, also the sub path of the route
+	header   map[string]string
+	body     string
+	respType string // Content-Type of the upstream response
+	respBody string // upstream response, written in small chunks
+}
+
+// converted is what the upstream received and what the client got back
+type converted struct {
+	subPath string
+	header  http.Header
+	body    map[string]interface{}
+	status  int
+	resp    string
+}
+
+func (cv conversion) run(t *testing.T, mw gin.HandlerFunc) converted {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	var got converted
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(ctx.RouteConfig, cv.route)
+		c.Set(ctx.SubPath, cv.path)
+	})
+	r.Use(mw)
+	r.Any("/*path", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		got.subPath = c.GetString(ctx.SubPath)
+		got.header = c.Request.Header.Clone()
+		if err := json.Unmarshal(body, &got.body); err != nil {
+			t.Errorf("upstream body is not JSON: %s", body)
+		}
+
+		if cv.respBody == "" {
+			return
+		}
+		c.Header("Content-Type", cv.respType)
+		c.Status(http.StatusOK)
+		data := []byte(cv.respBody)
+		for len(data) > 0 {
+			n := min(17, len(data))
+			c.Writer.Write(data[:n])
+			data = data[n:]
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost, cv.path, strings.NewReader(cv.body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range cv.header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	got.status = rec.Code
+	got.resp = rec.Body.String()
+	return got
+}
+
+// sseChunks renders Chat Completions stream chunks as an upstream SSE body
+func sseChunks(chunks ...string) string {
+	var sb strings.Builder
+	for _, c := range chunks {
+		sb.WriteString("data: " + c + "\n\n")
+	}
+	sb.WriteString("data: [DONE]\n\n")
+	return sb.String()
+}
+
+// parseSSE returns the data objects of a converted stream, with the event name
+// (if any) as "event". [DONE] is returned as an empty object.
+func parseSSE(t *testing.T, stream string) []map[string]interface{} {
+	t.Helper()
+	var events []map[string]interface{}
+	for _, block := range strings.Split(strings.TrimSpace(stream), "\n\n") {
+		var name string
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				name = strings.TrimPrefix(line, "event: ")
+			case line == "data: [DONE]":
+				events = append(events, map[string]interface{}{})
+			case strings.HasPrefix(line, "data: "):
+				var data map[string]interface{}
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &data); err != nil {
+					t.Fatalf("invalid event data %q: %v", line, err)
+				}
+				if name != "" {
+					data["event"] = name
+				}
+				events = append(events, data)
+			}
+		}
+	}
+	return events
+}
+
+// jsonEqual compares v, marshalled, with the JSON want
+func jsonEqual(t *testing.T, what string, v interface{}, want string) {
+	t.Helper()
+	got, _ := json.Marshal(v)
+	var a, b interface{}
+	json.Unmarshal(got, &a)
+	if err := json.Unmarshal([]byte(want), &b); err != nil {
+		t.Fatalf("%s: invalid want %s", what, want)
+	}
+	ga, _ := json.Marshal(a)
+	gb, _ := json.Marshal(b)
+	if string(ga) != string(gb) {
+		t.Errorf("%s:\n got %s\nwant %s", what, ga, gb)
+	}
+}
+
+func jsonUnmarshal(t *testing.T, data string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		t.Fatalf("invalid JSON %q: %v", data, err)
+	}
+}
+
+func jsonString(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+)
+
+// responseConverter converts upstream responses of one API format to another
+type responseConverter interface {
+	// convertEvent converts the payload of an upstream SSE data line, returns the SSE bytes to send
+	convertEvent(data string) []byte
+	// finishStream is called once the upstream stream ended, returns the SSE bytes to send
+	finishStream() []byte
+	// convertBody converts a complete JSON body, ok=false sends the body unchanged
+	convertBody(body []byte) (out []byte, ok bool)
+}
+
+// errorResponder is implemented by converters whose clients expect a non-OpenAI error format
+type errorResponder interface {
+	respondError(c *gin.Context, code int, message string)
+}
+
+// convertWriter applies a responseConverter to successful JSON and SSE responses.
+// Other responses, like upstream errors, are passed through.
+type convertWriter struct {
+	gin.ResponseWriter
+	conv responseConverter
+
+	pending   []byte // incomplete SSE line from the previous write
+	body      []byte // JSON body, converted by finish
+	streaming bool
+}
+
+func newConvertWriter(w gin.ResponseWriter, conv responseConverter) *convertWriter {
+	return &convertWriter{ResponseWriter: w, conv: conv}
+}
+
+func (w *convertWriter) Write(data []byte) (int, error) {
+	contentType := w.Header().Get("Content-Type")
+	ok := w.Status() < http.StatusMultipleChoices
+
+	switch {
+	case ok && strings.Contains(contentType, "text/event-stream"):
+		w.streaming = true
+		if err := w.writeSSE(data); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	case ok && strings.Contains(contentType, "application/json"):
+		w.body = append(w.body, data...)
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// writeSSE converts complete data lines, event names and comments of the upstream are not kept
+func (w *convertWriter) writeSSE(data []byte) error {
+	w.pending = append(w.pending, data...)
+
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			return nil
+		}
+		line := strings.TrimSpace(string(w.pending[:i]))
+		w.pending = w.pending[i+1:]
+
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		out := w.conv.convertEvent(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		if len(out) == 0 {
+			continue
+		}
+		if _, err := w.ResponseWriter.Write(out); err != nil {
+			return err
+		}
+	}
+}
+
+// finish writes the converted JSON body, or the end of a converted stream.
+// A body that cannot be converted is answered with 502, it is in the wrong format for the client.
+func (w *convertWriter) finish(c *gin.Context) {
+	if w.streaming {
+		if out := w.conv.finishStream(); len(out) > 0 {
+			if _, err := w.ResponseWriter.Write(out); err == nil {
+				w.Flush()
+			}
+		}
+		return
+	}
+	if w.body == nil {
+		return
+	}
+
+	out, ok := w.conv.convertBody(w.body)
+	if !ok {
+		logger.Warnf("convertWriter: failed to convert upstream response (Content-Encoding=%q, %d bytes)",
+			w.Header().Get("Content-Encoding"), len(w.body))
+		w.respondError(c)
+		return
+	}
+
+	// headers are still unsent, the upstream Content-Length is for the original body
+	w.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	if _, err := w.ResponseWriter.Write(out); err != nil {
+		logger.Warnf("convertWriter: failed to write response: %v", err)
+	}
+}
+
+// respondError replaces the unconverted upstream response, whose headers are still unsent
+func (w *convertWriter) respondError(c *gin.Context) {
+	w.Header().Del("Content-Length")
+	w.Header().Del("Content-Encoding")
+
+	msg := "The upstream response could not be converted to the API format of this route."
+	if r, ok := w.conv.(errorResponder); ok {
+		r.respondError(c, http.StatusBadGateway, msg)
+		return
+	}
+	response.RespondError(c, http.StatusBadGateway, msg, response.UPSTREAM_ERROR)
+}
+
+func (w *convertWriter) WriteHeader(code int) {
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *convertWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *convertWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
+
+// sseEvent formats a named SSE event
+func sseEvent(name string, data []byte) []byte {
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", name, data))
+}
+
+// sseData formats an unnamed SSE event, as used by Chat Completions streams
+func sseData(data []byte) []byte {
+	return []byte(fmt.Sprintf("data: %s\n\n", data))
+}
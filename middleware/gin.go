@@ -29,8 +29,13 @@ func GinRequestLogger() gin.HandlerFunc {
 		clientIP := c.ClientIP()
 		targetURL := c.GetString(ctx.TargetURL)
 
+		// log the target as configured, so interpolated secrets stay out of logs
+		if route := ctx.GetRoute(c); route != nil && targetURL != "" {
+			targetURL = util.JoinURL(route.Public().Target, c.GetString(ctx.SubPath))
+		}
+
 		topRoute := c.GetString(ctx.TopRoute)
-		if config.ReservedTopRoutes[topRoute] {
+		if config.ReservedTopRoutes[topRoute] || (topRoute == config.UnifiedTopRoute && targetURL == "") {
 			targetURL = "-"
 		}
 
@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"os"
+	"testing"
+
+	"github.com/poixeai/proxify/infra/logger"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	// the logger is set up by the serve command, tests log nowhere
+	logger.ZapLog = zap.NewNop().Sugar()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/keys"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+	"github.com/poixeai/proxify/util"
+)
+
+// max number of alternatives named in a rejection
+const maxModelAlternatives = 10
+
+// ModelPolicy rejects models not permitted by the route's models_allow / models_deny
+// or by the API key's policy. Every model of the request (path and body) is checked as
+// sent to the route and as mapped by model_map. With filters in place, requests whose
+// body names no model are rejected.
+func ModelPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := ctx.GetRoute(c)
+		if route == nil {
+			c.Next()
+			return
+		}
+
+		var key *keys.Key
+		if v, ok := c.Get(ctx.APIKey); ok {
+			key = v.(*keys.Key)
+		}
+
+		routeFiltered := len(route.ModelsAllow) > 0 || len(route.ModelsDeny) > 0
+		keyFiltered := key != nil && (len(key.ModelsAllow) > 0 || len(key.ModelsDeny) > 0)
+		if !routeFiltered && !keyFiltered {
+			c.Next()
+			return
+		}
+
+		models, err := requestModels(c)
+		if err != nil {
+			logger.Warnf("ModelPolicy: route=%s request without a model rejected", route.Path)
+			response.RespondError(c, http.StatusBadRequest, err.Error(), response.INVALID_REQUEST_ERROR)
+			c.Abort()
+			return
+		}
+
+		// requests without a body, such as model listings, select no model and pass
+		for _, model := range models {
+			names := []string{model}
+			if mapped, ok := route.MapModel(model); ok && mapped != "" && mapped != model {
+				names = append(names, mapped)
+			}
+
+			if !route.ModelAllowed(names...) {
+				rejectModel(c, names, "on this route", route, key)
+				return
+			}
+			if keyFiltered && !key.ModelAllowed(names...) {
+				rejectModel(c, names, "for this API key", route, key)
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+var (
+	errNoModel = errors.New(
+		"The model of the request could not be determined, it is required when models are restricted.")
+	errMultipartModel = errors.New(
+		"The `model` form field must come before any file when models are restricted.")
+)
+
+// requestModels returns the models named by the request path and by the JSON body (also
+// Responses API requests) or multipart form. A body that names no model is an error,
+// unless the path names one (Gemini, Azure).
+func requestModels(c *gin.Context) ([]string, error) {
+	var models []string
+	if model := util.PathModel(c.GetString(ctx.SubPath)); model != "" {
+		models = append(models, model)
+	}
+
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return models, nil
+	}
+
+	// multipart uploads are read up to the model field, like in ModelRewrite the rest is streamed
+	mediaType, params, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") {
+		model, body := util.MultipartModel(c.Request.Body, params["boundary"])
+		c.Request.Body = io.NopCloser(body)
+		if model != "" {
+			return append(models, model), nil
+		}
+		if len(models) > 0 {
+			return models, nil
+		}
+		return nil, errMultipartModel
+	}
+
+	bodyBytes, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		logger.Warnf("ModelPolicy: failed to read request body: %v", err)
+	}
+	// restore body for downstream handlers
+	c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	if len(bodyBytes) == 0 {
+		return models, nil
+	}
+
+	var body struct {
+		Model string `json:"model"`
+	}
+	if err := json.Unmarshal(bodyBytes, &body); err == nil && body.Model != "" {
+		return append(models, body.Model), nil
+	}
+	if len(models) > 0 {
+		return models, nil
+	}
+	return nil, errNoModel
+}
+
+// rejectModel answers 403, names are the requested model and its model_map target, if any
+func rejectModel(c *gin.Context, names []string, scope string, route *config.Route, key *keys.Key) {
+	model := names[0]
+	msg := fmt.Sprintf("The model `%s` is not allowed %s.", model, scope)
+	if len(names) > 1 {
+		msg = fmt.Sprintf("The model `%s` (mapped to `%s`) is not allowed %s.", model, names[1], scope)
+	}
+	if alts := modelAlternatives(route, key); len(alts) > 0 {
+		msg += " Allowed models: " + strings.Join(alts, ", ") + "."
+	}
+
+	logger.Warnf("ModelPolicy: route=%s model=%s rejected %s", route.Path, model, scope)
+	response.RespondError(c, http.StatusForbidden, msg, response.PERMISSION_ERROR)
+	c.Abort()
+}
+
+// modelAlternatives names models permitted by both the route and the key:
+// the allow patterns if there are any, otherwise the route's known model names
+func modelAlternatives(route *config.Route, key *keys.Key) []string {
+	allowed := func(m string) bool {
+		names := []string{m}
+		if mapped, ok := route.MapModel(m); ok && mapped != "" && mapped != m {
+			names = append(names, mapped)
+		}
+		return route.ModelAllowed(names...) && (key == nil || key.ModelAllowed(names...))
+	}
+
+	var candidates []string
+	switch {
+	case key != nil && len(key.ModelsAllow) > 0:
+		candidates = key.ModelsAllow
+	case len(route.ModelsAllow) > 0:
+		candidates = route.ModelsAllow
+	default:
+		candidates = append(candidates, route.Models...)
+		for alias := range route.ModelMap {
+			if !config.IsModelPattern(alias) {
+				candidates = append(candidates, alias)
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	for _, m := range candidates {
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		// patterns are named as they are, they cannot be checked against each other
+		if config.IsModelPattern(m) || allowed(m) {
+			out = append(out, m)
+		}
+	}
+
+	sort.Strings(out)
+	if len(out) > maxModelAlternatives {
+		out = out[:maxModelAlternatives]
+	}
+	return out
+}
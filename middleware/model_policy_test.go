@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+)
+
+// policyStatus runs a request through ModelPolicy and returns the status, 200 if it passed
+func policyStatus(t *testing.T, route *config.Route, subPath, contentType string, body []byte) int {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(ctx.RouteConfig, route)
+		c.Set(ctx.SubPath, subPath)
+	})
+	r.Use(ModelPolicy())
+	r.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPost, "/test"+subPath, bytes.NewReader(body))
+	if body == nil {
+		req = httptest.NewRequest(http.MethodGet, "/test"+subPath, nil)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestModelPolicy(t *testing.T) {
+	route := &config.Route{
+		Path:        "/test",
+		ModelsAllow: []string{"gpt-4o-mini", "gemini-*"},
+		ModelMap:    map[string]string{"cheap": "gpt-4o-mini", "sneaky": "gpt-4o"},
+	}
+	const jsonType = "application/json"
+
+	tests := []struct {
+		name    string
+		subPath string
+		body    string
+		want    int
+	}{
+		{"allowed body model", "/v1/chat/completions", `{"model":"gpt-4o-mini"}`, 200},
+		{"denied body model", "/v1/chat/completions", `{"model":"gpt-4o"}`, 403},
+		{"alias of allowed model", "/v1/chat/completions", `{"model":"cheap"}`, 200},
+		{"alias mapped to denied model", "/v1/chat/completions", `{"model":"sneaky"}`, 403},
+		{"body without model", "/v1/chat/completions", `{"messages":[]}`, 400},
+		{"invalid body", "/v1/chat/completions", `not json`, 400},
+		{"gemini path model", "/v1beta/models/gemini-2.5-pro:generateContent", `{"contents":[]}`, 200},
+		{"denied gemini path model", "/v1beta/models/gpt-4o:generateContent", `{"contents":[]}`, 403},
+		{"query does not name a model", "/v1/chat/completions?x=/models/gpt-4o-mini", `{"model":"gpt-4o"}`, 403},
+		{"path and body are both checked", "/v1beta/models/gemini-pro:generateContent", `{"model":"gpt-4o"}`, 403},
+		{"request without body", "/v1/models", "", 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body []byte
+			if tt.body != "" {
+				body = []byte(tt.body)
+			}
+			if got := policyStatus(t, route, tt.subPath, jsonType, body); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelPolicyMultipart(t *testing.T) {
+	route := &config.Route{Path: "/test", ModelsDeny: []string{"whisper-large*"}}
+
+	form := func(modelFirst bool, model string) ([]byte, string) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		if modelFirst {
+			mw.WriteField("model", model)
+		}
+		fw, _ := mw.CreateFormFile("file", "speech.mp3")
+		fw.Write([]byte(strings.Repeat("audio", 50000)))
+		if !modelFirst {
+			mw.WriteField("model", model)
+		}
+		mw.Close()
+		return buf.Bytes(), mw.FormDataContentType()
+	}
+
+	body, contentType := form(true, "whisper-1")
+	if got := policyStatus(t, route, "/v1/audio/transcriptions", contentType, body); got != 200 {
+		t.Errorf("allowed model: status = %d", got)
+	}
+	body, contentType = form(true, "whisper-large-v3")
+	if got := policyStatus(t, route, "/v1/audio/transcriptions", contentType, body); got != 403 {
+		t.Errorf("denied model: status = %d", got)
+	}
+	// a model behind a large file is not buffered for, the request fails closed
+	body, contentType = form(false, "whisper-1")
+	if got := policyStatus(t, route, "/v1/audio/transcriptions", contentType, body); got != 400 {
+		t.Errorf("model after file: status = %d", got)
+	}
+}
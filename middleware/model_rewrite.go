@@ -3,6 +3,9 @@ package middleware
 import (
 	"bytes"
 	"io"
+	"mime"
+	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/poixeai/proxify/infra/ctx"
@@ -10,8 +13,9 @@ import (
 	"github.com/poixeai/proxify/util"
 )
 
-// ModelRewrite rewrites the `model` field in Chat Completions style requests
-// based on route-level modelMap configuration.
+// ModelRewrite rewrites the requested model based on route-level modelMap configuration.
+// The model is looked up in the request path (Gemini, Azure), in multipart
+// form fields (audio uploads) and in the `model` field of JSON bodies.
 func ModelRewrite() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// get current route config from context
@@ -21,8 +25,27 @@ func ModelRewrite() gin.HandlerFunc {
 			return
 		}
 
+		// path-embedded model, e.g. /v1beta/models/{model}:generateContent
+		subPath := c.GetString(ctx.SubPath)
+		if newPath, original, ok := util.RewritePathModel(subPath, route.MapModel); ok {
+			logger.Infof("ModelRewrite: route=%s path model rewritten", route.Name)
+			c.Set(ctx.SubPath, newPath)
+			setRequestedModel(c, original)
+		}
+
 		// no body, nothing to do
-		if c.Request.Body == nil {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		// multipart bodies are rewritten while streaming, uploads can be large
+		mediaType, params, _ := mime.ParseMediaType(c.Request.Header.Get("Content-Type"))
+		if strings.HasPrefix(mediaType, "multipart/form-data") {
+			if boundary := params["boundary"]; boundary != "" {
+				c.Request.Body = util.RewriteMultipartModel(c.Request.Body, boundary, route.MapModel)
+				c.Request.ContentLength = -1
+			}
 			c.Next()
 			return
 		}
@@ -36,9 +59,13 @@ func ModelRewrite() gin.HandlerFunc {
 		}
 
 		// attempt to rewrite model
-		newBody, rewritten, err := util.RewriteChatCompletionModel(
+		var original string
+		newBody, rewritten, err := util.RewriteChatCompletionModelFunc(
 			bodyBytes,
-			route.ModelMap,
+			func(model string) (string, bool) {
+				original = model
+				return route.MapModel(model)
+			},
 		)
 		if err != nil {
 			logger.Warnf("ModelRewrite: rewrite failed: %v", err)
@@ -54,6 +81,7 @@ func ModelRewrite() gin.HandlerFunc {
 				route.Name,
 			)
 			bodyBytes = newBody
+			setRequestedModel(c, original)
 		}
 
 		// IMPORTANT: restore body for downstream handlers
@@ -63,3 +91,10 @@ func ModelRewrite() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// setRequestedModel records the client-facing model, keeping the name set by ModelRouter
+func setRequestedModel(c *gin.Context, model string) {
+	if _, ok := c.Get(ctx.RequestedModel); !ok {
+		c.Set(ctx.RequestedModel, model)
+	}
+}
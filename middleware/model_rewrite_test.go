@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+)
+
+// upstreamRequest is what ModelRewrite hands to the proxy handler
+type upstreamRequest struct {
+	subPath        string
+	contentType    string
+	body           []byte
+	requestedModel string
+}
+
+// rewriteRequest runs req through ModelRewrite for a route with modelMap
+func rewriteRequest(t *testing.T, modelMap map[string]string, subPath string, req *http.Request) upstreamRequest {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	route := &config.Route{Name: "test", Path: "/test", ModelMap: modelMap}
+
+	var got upstreamRequest
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(ctx.RouteConfig, route)
+		c.Set(ctx.SubPath, subPath)
+	})
+	r.Use(ModelRewrite())
+	r.Any("/*path", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			t.Errorf("reading rewritten body: %v", err)
+		}
+		got = upstreamRequest{
+			subPath:        c.GetString(ctx.SubPath),
+			contentType:    c.Request.Header.Get("Content-Type"),
+			body:           body,
+			requestedModel: c.GetString(ctx.RequestedModel),
+		}
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	return got
+}
+
+func TestModelRewriteGeminiPath(t *testing.T) {
+	body := `{"contents":[{"parts":[{"text":"hi"}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/gemini/v1beta/models/gemini-pro:streamGenerateContent?alt=sse",
+		bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	got := rewriteRequest(t, map[string]string{"gemini-pro": "gemini-2.5-pro"},
+		"/v1beta/models/gemini-pro:streamGenerateContent?alt=sse", req)
+
+	if got.subPath != "/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse" {
+		t.Errorf("subPath = %q", got.subPath)
+	}
+	if got.requestedModel != "gemini-pro" {
+		t.Errorf("requested model = %q, want gemini-pro", got.requestedModel)
+	}
+	if string(got.body) != body {
+		t.Errorf("body changed: %s", got.body)
+	}
+}
+
+func TestModelRewriteAzurePath(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/azure/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21",
+		bytes.NewBufferString(`{"messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	got := rewriteRequest(t, map[string]string{"gpt-4o": "prod-gpt4o"},
+		"/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21", req)
+
+	if got.subPath != "/openai/deployments/prod-gpt4o/chat/completions?api-version=2024-10-21" {
+		t.Errorf("subPath = %q", got.subPath)
+	}
+	if got.requestedModel != "gpt-4o" {
+		t.Errorf("requested model = %q, want gpt-4o", got.requestedModel)
+	}
+}
+
+func TestModelRewriteMultipart(t *testing.T) {
+	audio := make([]byte, 50000)
+	for i := range audio {
+		audio[i] = byte(i * 7)
+	}
+	audio = append(audio, "\r\n--fake\r\n"...)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="speech.wav"`)
+	h.Set("Content-Type", "audio/wav")
+	fw, _ := mw.CreatePart(h)
+	fw.Write(audio)
+	mw.WriteField("model", "whisper")
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/openai/v1/audio/transcriptions", &form)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+
+	got := rewriteRequest(t, map[string]string{"whisper": "whisper-large-v3"}, "/v1/audio/transcriptions", req)
+
+	// the Content-Type header is forwarded as is, its boundary has to match the body
+	if got.contentType != mw.FormDataContentType() {
+		t.Errorf("Content-Type = %q", got.contentType)
+	}
+	mr := multipart.NewReader(bytes.NewReader(got.body), mw.Boundary())
+
+	file, err := mr.NextPart()
+	if err != nil {
+		t.Fatalf("rewritten body does not parse with the original boundary: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	if file.FileName() != "speech.wav" || !bytes.Equal(data, audio) {
+		t.Errorf("file part changed: %s, %d bytes", file.FileName(), len(data))
+	}
+
+	model, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := io.ReadAll(model); string(value) != "whisper-large-v3" {
+		t.Errorf("model = %q, want whisper-large-v3", value)
+	}
+	if _, err := mr.NextPart(); err != io.EOF {
+		t.Errorf("expected end of form, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+	"github.com/poixeai/proxify/infra/watcher"
+	"github.com/poixeai/proxify/util"
+)
+
+// ModelRouter resolves requests on the unified /v1 endpoint to a route
+// by the `model` field of the request body. A route with path /v1 is proxied as usual.
+func ModelRouter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(ctx.TopRoute) != config.UnifiedTopRoute || c.GetBool(ctx.Proxified) {
+			c.Next()
+			return
+		}
+
+		// handled by the system, e.g. GET /v1/models
+		if c.Request.Method == http.MethodGet {
+			c.Next()
+			return
+		}
+
+		var bodyBytes []byte
+		if c.Request.Body != nil {
+			var err error
+			bodyBytes, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				logger.Warnf("ModelRouter: failed to read request body: %v", err)
+				response.RespondError(c, http.StatusBadRequest, "Failed to read request body.", response.INVALID_REQUEST_ERROR)
+				c.Abort()
+				return
+			}
+		}
+
+		var req struct {
+			Model string `json:"model"`
+		}
+		if err := json.Unmarshal(bodyBytes, &req); err != nil || req.Model == "" {
+			response.RespondError(c, http.StatusBadRequest,
+				"The unified endpoint requires a JSON body with a `model` field.",
+				response.INVALID_REQUEST_ERROR)
+			c.Abort()
+			return
+		}
+
+		route, upstreamModel, ok := watcher.GetRoutes().MatchModel(req.Model)
+		if !ok {
+			response.RespondError(c, http.StatusNotFound,
+				fmt.Sprintf("The model `%s` is not served by any route.", req.Model),
+				response.NOT_FOUND_ERROR)
+			c.Abort()
+			return
+		}
+
+		// "provider/model" is sent upstream as "model"
+		if upstreamModel != req.Model {
+			newBody, _, err := util.RewriteChatCompletionModel(
+				bodyBytes,
+				map[string]string{req.Model: upstreamModel},
+			)
+			if err != nil {
+				logger.Warnf("ModelRouter: rewrite failed: %v", err)
+			} else {
+				bodyBytes = newBody
+				c.Set(ctx.RequestedModel, req.Model)
+			}
+		}
+
+		// continue as if the request was sent to the route, keeping the /v1 prefix:
+		// /v1/chat/completions -> {target}/v1/chat/completions
+		c.Set(ctx.TopRoute, route.Path[1:])
+		c.Set(ctx.SubPath, "/"+config.UnifiedTopRoute+c.GetString(ctx.SubPath))
+		c.Set(ctx.TargetEndpoint, route.Target)
+		c.Set(ctx.RouteConfig, route)
+		c.Set(ctx.Proxified, true)
+
+		logger.Debugf("ModelRouter: model=%s route=%s", req.Model, route.Path)
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		c.Request.ContentLength = int64(len(bodyBytes))
+
+		c.Next()
+	}
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/watcher"
+)
+
+// routed is what ModelRouter hands to the proxy handler
+type routed struct {
+	status         int
+	route          string
+	target         string
+	subPath        string
+	model          string
+	requestedModel string
+}
+
+// routeRequest sends a request to the unified endpoint through ModelRouter
+func routeRequest(t *testing.T, method, body string) routed {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	var got routed
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(ctx.TopRoute, config.UnifiedTopRoute)
+		c.Set(ctx.SubPath, "/chat/completions")
+	})
+	r.Use(ModelRouter())
+	r.Any("/*path", func(c *gin.Context) {
+		if route := ctx.GetRoute(c); route != nil {
+			got.route = route.Path
+		}
+		got.target = c.GetString(ctx.TargetEndpoint)
+		got.subPath = c.GetString(ctx.SubPath)
+		got.requestedModel = c.GetString(ctx.RequestedModel)
+
+		var req struct {
+			Model string `json:"model"`
+		}
+		data, _ := io.ReadAll(c.Request.Body)
+		json.Unmarshal(data, &req)
+		got.model = req.Model
+		if c.Request.ContentLength != int64(len(data)) {
+			t.Errorf("Content-Length = %d, body has %d bytes", c.Request.ContentLength, len(data))
+		}
+	})
+
+	req := httptest.NewRequest(method, "/v1/chat/completions", bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	got.status = rec.Code
+	return got
+}
+
+func TestModelRouter(t *testing.T) {
+	cfg := &config.RoutesConfig{Routes: []config.Route{
+		{Path: "/openai", Target: "https://api.openai.com", Models: []string{"gpt-*"}},
+		{Path: "/groq", Target: "https://api.groq.com/openai"},
+	}}
+	if err := config.ValidateRoutes(cfg); err != nil {
+		t.Fatal(err)
+	}
+	prev := watcher.GetRoutes()
+	watcher.ConfigValue.Store(cfg)
+	defer watcher.ConfigValue.Store(prev)
+
+	tests := []struct {
+		name, method, body string
+		want               routed
+	}{
+		{
+			name: "model", method: http.MethodPost, body: `{"model":"gpt-4o","messages":[]}`,
+			want: routed{status: http.StatusOK, route: "/openai", target: "https://api.openai.com",
+				subPath: "/v1/chat/completions", model: "gpt-4o"},
+		},
+		{
+			name: "provider prefix", method: http.MethodPost, body: `{"model":"groq/llama3-70b","messages":[]}`,
+			want: routed{status: http.StatusOK, route: "/groq", target: "https://api.groq.com/openai",
+				subPath: "/v1/chat/completions", model: "llama3-70b", requestedModel: "groq/llama3-70b"},
+		},
+		{
+			name: "unknown model", method: http.MethodPost, body: `{"model":"llama3-70b"}`,
+			want: routed{status: http.StatusNotFound},
+		},
+		{
+			name: "no model", method: http.MethodPost, body: `{"messages":[]}`,
+			want: routed{status: http.StatusBadRequest},
+		},
+		{
+			name: "not JSON", method: http.MethodPost, body: `model=gpt-4o`,
+			want: routed{status: http.StatusBadRequest},
+		},
+		{
+			// GET /v1/models is served by the system
+			name: "get", method: http.MethodGet,
+			want: routed{status: http.StatusOK, subPath: "/chat/completions"},
+		},
+	}
+	for _, tt := range tests {
+		if got := routeRequest(t, tt.method, tt.body); got != tt.want {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -2,15 +2,19 @@ package middleware
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
 	"github.com/poixeai/proxify/infra/ctx"
 	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/util"
 )
 
 // ChatCompletionStreamChunk represents a streaming chunk from Chat Completions API
@@ -22,19 +26,64 @@ type ChatCompletionStreamChunk struct {
 	Choices []struct {
 		Index int `json:"index"`
 		Delta struct {
-			Role             string `json:"role,omitempty"`
-			Content          string `json:"content,omitempty"`
-			ReasoningContent string `json:"reasoning_content,omitempty"` // Zhipu GLM reasoning
+			Role             string         `json:"role,omitempty"`
+			Content          string         `json:"content,omitempty"`
+			ReasoningContent string         `json:"reasoning_content,omitempty"` // GLM / DeepSeek reasoning
+			ToolCalls        []ChatToolCall `json:"tool_calls,omitempty"`
 		} `json:"delta"`
 		FinishReason *string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *ChatUsage `json:"usage,omitempty"` // last chunk, with stream_options.include_usage
+}
+
+// ChatCompletion is a non-streaming Chat Completions API response
+type ChatCompletion struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role             string         `json:"role"`
+			Content          string         `json:"content"`
+			ReasoningContent string         `json:"reasoning_content,omitempty"`
+			ToolCalls        []ChatToolCall `json:"tool_calls,omitempty"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *ChatUsage `json:"usage,omitempty"`
+}
+
+// ChatToolCall is a (partial, when streaming) tool call of a Chat Completions message
+type ChatToolCall struct {
+	Index    int    `json:"index,omitempty"` // streaming only
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments,omitempty"`
+	} `json:"function"`
+}
+
+// ChatUsage is the token usage reported by Chat Completions API
+type ChatUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
+	CompletionTokensDetails *struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details,omitempty"`
 }
 
 // ResponseTransform transforms Chat Completions responses to Responses API format
 func ResponseTransform() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		route := ctx.GetRoute(c)
-		if route == nil || route.Transform != "responses_to_chat" {
+		if route == nil || route.Transform != config.TransformResponsesToChat {
 			c.Next()
 			return
 		}
@@ -51,23 +100,111 @@ func ResponseTransform() gin.HandlerFunc {
 		proxy := &responseProxy{
 			ResponseWriter: c.Writer,
 			transform:      true,
+			emitReasoning:  route.ReasoningSummary,
+			responseID:     "resp_" + util.RandGenerater(util.RandAlphanumeric, 24),
+			toolCalls:      make(map[int]*outputItem),
 		}
 		c.Writer = proxy
 
 		c.Next()
+
+		// upstream streams may end without [DONE], JSON bodies are converted once complete
+		proxy.finish()
+
+		// keep the conversation for previous_response_id, see ResponsesToChat
+		if history, ok := c.Get(ctx.ResponsesHistory); ok && proxy.final != nil {
+			storeResponse(c, route, history.([]ChatMessage), proxy)
+		}
+	}
+}
+
+// outputItem is an item of the Responses API `output` array
+type outputItem struct {
+	Type        string // "message", "function_call" or "reasoning"
+	ID          string
+	OutputIndex int
+	Done        bool
+
+	Text string // message text or reasoning summary
+
+	CallID    string // function_call
+	Name      string
+	Arguments string
+	ownCallID bool // CallID was generated, the upstream sent none
+}
+
+func (it *outputItem) toMap() map[string]interface{} {
+	status := "in_progress"
+	if it.Done {
+		status = "completed"
+	}
+
+	switch it.Type {
+	case "reasoning":
+		summary := []interface{}{}
+		if it.Done && it.Text != "" {
+			summary = append(summary, summaryTextPart(it.Text))
+		}
+		return map[string]interface{}{
+			"id":      it.ID,
+			"type":    "reasoning",
+			"summary": summary,
+		}
+	case "function_call":
+		return map[string]interface{}{
+			"id":        it.ID,
+			"type":      "function_call",
+			"status":    status,
+			"call_id":   it.CallID,
+			"name":      it.Name,
+			"arguments": it.Arguments,
+		}
+	default:
+		content := []interface{}{}
+		if it.Done {
+			content = append(content, outputTextPart(it.Text))
+		}
+		return map[string]interface{}{
+			"id":      it.ID,
+			"type":    "message",
+			"role":    "assistant",
+			"status":  status,
+			"content": content,
+		}
+	}
+}
+
+func outputTextPart(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "output_text",
+		"text":        text,
+		"annotations": []interface{}{},
 	}
 }
 
 type responseProxy struct {
 	gin.ResponseWriter
-	transform        bool
-	initialized      bool   // whether we've sent response.created event
-	responseID       string
-	itemID           string // message item ID for delta events
-	sequenceNumber   int    // sequence counter for all events
-	contentPartAdded bool   // whether we've added content_part for main output
-	accumulatedText  string // accumulated text content for response.output_text.done
-	completed        bool   // whether we've already sent response.completed event
+	transform      bool
+	emitReasoning  bool   // route.ReasoningSummary, reasoning_content becomes a reasoning item
+	body           []byte // non-streaming JSON body, converted by finish
+	pending        []byte // incomplete SSE line from the previous write
+	initialized    bool   // whether we've sent response.created event
+	responseID     string // generated, upstream ids are not unique across providers
+	model          string
+	createdAt      int64
+	sequenceNumber int // sequence counter for all events
+
+	items     []*outputItem       // output items in output_index order
+	reasoning *outputItem         // reasoning item, created on the first reasoning delta
+	message   *outputItem         // assistant text item, created on the first text delta
+	toolCalls map[int]*outputItem // function_call items by Chat Completions tool call index
+
+	itemsDone    bool   // whether finish_reason closed all items
+	finishReason string // Chat Completions finish_reason
+	usage        *ChatUsage
+	completed    bool // whether we've already sent response.completed event
+
+	final map[string]interface{} // completed response object, for the conversation store
 }
 
 func (p *responseProxy) Write(data []byte) (int, error) {
@@ -77,115 +214,177 @@ func (p *responseProxy) Write(data []byte) (int, error) {
 
 	// Check if this is a streaming response
 	contentType := p.Header().Get("Content-Type")
-	logger.Infof("ResponseTransform.Write: contentType=%s, dataLen=%d, dataPreview=%s", contentType, len(data), string(data)[:min(100, len(data))])
+	logger.Debugf("ResponseTransform.Write: contentType=%s, dataLen=%d", contentType, len(data))
 
 	if !strings.Contains(contentType, "text/event-stream") {
-		// Non-streaming response, pass through
+		// Successful JSON responses are buffered and converted by finish,
+		// anything else (e.g. upstream errors) is passed through
+		if strings.Contains(contentType, "application/json") && p.Status() < http.StatusMultipleChoices {
+			p.body = append(p.body, data...)
+			return len(data), nil
+		}
 		return p.ResponseWriter.Write(data)
 	}
 
 	// Transform SSE chunks
-	return p.transformSSE(data)
-}
-
-func min(a, b int) int {
-	if a < b {
-		return a
+	if _, err := p.transformSSE(data); err != nil {
+		return 0, err
 	}
-	return b
+	return len(data), nil
 }
 
 func (p *responseProxy) transformSSE(data []byte) (int, error) {
-	logger.Infof("transformSSE called with data: %s", string(data))
-
-	// Parse SSE format: "data: {...}\n\n"
-	lines := strings.Split(string(data), "\n")
+	// Parse SSE format: "data: {...}\n\n", lines may be split across writes
+	p.pending = append(p.pending, data...)
 	var totalWritten int
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+	for {
+		i := bytes.IndexByte(p.pending, '\n')
+		if i < 0 {
+			break
 		}
+		line := strings.TrimSpace(string(p.pending[:i]))
+		p.pending = p.pending[i+1:]
 
-		logger.Infof("transformSSE processing line: %s", line)
-
-		if !strings.HasPrefix(line, "data: ") {
-			// Pass through non-data lines
-			n, err := p.ResponseWriter.Write([]byte(line + "\n"))
-			totalWritten += n
-			if err != nil {
-				return totalWritten, err
-			}
-			continue
+		n, err := p.transformLine(line)
+		totalWritten += n
+		if err != nil {
+			return totalWritten, err
 		}
+	}
 
-		// Extract JSON payload
-		jsonStr := strings.TrimPrefix(line, "data: ")
-		logger.Infof("transformSSE jsonStr: %s", jsonStr)
+	return totalWritten, nil
+}
 
-		// Handle [DONE] marker - only emit if we haven't already sent response.completed
-		if jsonStr == "[DONE]" {
-			// If we already sent response.completed via finish_reason, skip this
-			if p.completed {
-				logger.Infof("transformSSE: skipping [DONE] marker, already sent response.completed")
-				continue
-			}
-			// Convert to Responses API done format
-			// SSE format with event type
-			doneEvent := "event: response.completed\ndata: {\"type\":\"response.completed\"}\n\n"
-			n, err := p.ResponseWriter.Write([]byte(doneEvent))
-			totalWritten += n
-			if err != nil {
-				return totalWritten, err
-			}
-			continue
-		}
+func (p *responseProxy) transformLine(line string) (int, error) {
+	if line == "" {
+		return 0, nil
+	}
 
-		// Parse Chat Completions chunk
-		var ccChunk ChatCompletionStreamChunk
-		if err := json.Unmarshal([]byte(jsonStr), &ccChunk); err != nil {
-			logger.Warnf("ResponseTransform: failed to parse chunk: %v", err)
-			// Pass through on error
-			n, err := p.ResponseWriter.Write([]byte(line + "\n"))
-			totalWritten += n
-			if err != nil {
-				return totalWritten, err
-			}
+	if !strings.HasPrefix(line, "data:") {
+		// Pass through non-data lines
+		return p.ResponseWriter.Write([]byte(line + "\n"))
+	}
+
+	// Extract JSON payload
+	jsonStr := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+	// [DONE] marker ends the response
+	if jsonStr == "[DONE]" {
+		return p.writeEvents(p.completeEvents())
+	}
+
+	// Parse Chat Completions chunk
+	var ccChunk ChatCompletionStreamChunk
+	if err := json.Unmarshal([]byte(jsonStr), &ccChunk); err != nil {
+		logger.Warnf("ResponseTransform: failed to parse chunk: %v", err)
+		// Pass through on error
+		return p.ResponseWriter.Write([]byte(line + "\n"))
+	}
+
+	// Convert to Responses API format
+	events := p.convertChunkToResponsesEvents(&ccChunk, !p.initialized)
+	return p.writeEvents(events)
+}
+
+// writeEvents writes events in SSE format: event line + data line + blank line
+func (p *responseProxy) writeEvents(events []ResponsesAPIEvent) (int, error) {
+	var totalWritten int
+	for _, event := range events {
+		eventBytes, err := json.Marshal(event.Data)
+		if err != nil {
+			logger.Warnf("ResponseTransform: failed to marshal response: %v", err)
 			continue
 		}
 
-		// Convert to Responses API format
-		events := p.convertChunkToResponsesEvents(&ccChunk, !p.initialized)
-		if !p.initialized && len(events) > 0 {
-			p.initialized = true
-			p.responseID = ccChunk.ID
-		}
-		logger.Infof("transformSSE: converted to %d events for chunk id=%s, initialized=%v", len(events), ccChunk.ID, p.initialized)
-		if len(events) == 0 {
-			continue
+		output := fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, string(eventBytes))
+		n, err := p.ResponseWriter.Write([]byte(output))
+		totalWritten += n
+		if err != nil {
+			return totalWritten, err
 		}
+	}
+	return totalWritten, nil
+}
 
-		// Write each event
-		for _, event := range events {
-			eventBytes, err := json.Marshal(event.Data)
-			if err != nil {
-				logger.Warnf("ResponseTransform: failed to marshal response: %v", err)
-				continue
-			}
+// finish writes the converted JSON response, or completes a stream that ended without [DONE]
+func (p *responseProxy) finish() {
+	if p.body != nil {
+		p.writeJSON()
+		return
+	}
+	if !p.initialized || p.completed {
+		return
+	}
+	if _, err := p.writeEvents(p.completeEvents()); err == nil {
+		p.Flush()
+	}
+}
 
-			// SSE format: event line + data line + blank line
-			output := fmt.Sprintf("event: %s\ndata: %s\n\n", event.Type, string(eventBytes))
-			logger.Infof("transformSSE: writing output: %s", output)
-			n, err := p.ResponseWriter.Write([]byte(output))
-			totalWritten += n
-			if err != nil {
-				return totalWritten, err
-			}
+// writeJSON converts a buffered Chat Completions response to a Responses API response object
+func (p *responseProxy) writeJSON() {
+	out := p.body
+
+	var cc ChatCompletion
+	if err := json.Unmarshal(p.body, &cc); err != nil {
+		logger.Warnf("ResponseTransform: failed to parse response (Content-Encoding=%q), sending it unconverted: %v",
+			p.Header().Get("Content-Encoding"), err)
+	} else if len(cc.Choices) == 0 {
+		logger.Warnf("ResponseTransform: response has no choices, passing through")
+	} else if converted, err := json.Marshal(p.convertCompletion(&cc)); err != nil {
+		logger.Warnf("ResponseTransform: failed to marshal response: %v", err)
+	} else {
+		out = converted
+	}
+
+	// headers are still unsent, the upstream Content-Length is for the original body
+	p.Header().Set("Content-Length", strconv.Itoa(len(out)))
+	if _, err := p.ResponseWriter.Write(out); err != nil {
+		logger.Warnf("ResponseTransform: failed to write response: %v", err)
+	}
+}
+
+// convertCompletion builds the Responses API response object of a Chat Completions response with choices
+func (p *responseProxy) convertCompletion(cc *ChatCompletion) map[string]interface{} {
+	p.model = cc.Model
+	p.createdAt = cc.Created
+	p.usage = cc.Usage
+
+	choice := cc.Choices[0]
+	msg := choice.Message
+	p.finishReason = choice.FinishReason
+
+	// same item order as streams: reasoning, message, function calls
+	if p.emitReasoning && msg.ReasoningContent != "" {
+		p.appendItem(&outputItem{Type: "reasoning", ID: p.itemID("rs"), Text: msg.ReasoningContent})
+	}
+	if msg.Content != "" {
+		p.appendItem(&outputItem{Type: "message", ID: p.itemID("msg"), Text: msg.Content})
+	}
+	for _, tc := range msg.ToolCalls {
+		callID := tc.ID
+		if callID == "" {
+			callID = "call_" + util.RandGenerater(util.RandAlphanumeric, 24)
 		}
+		p.appendItem(&outputItem{
+			Type:      "function_call",
+			ID:        "fc_" + callID,
+			CallID:    callID,
+			Name:      tc.Function.Name,
+			Arguments: tc.Function.Arguments,
+		})
 	}
 
-	return totalWritten, nil
+	for _, it := range p.items {
+		it.Done = true
+	}
+	p.final = p.responseObject(p.finalStatus())
+	return p.final
+}
+
+// itemID derives the id of an output item from the response id, like "msg_..."
+func (p *responseProxy) itemID(prefix string) string {
+	return prefix + "_" + strings.TrimPrefix(p.responseID, "resp_")
 }
 
 func (p *responseProxy) WriteHeader(code int) {
@@ -211,10 +410,82 @@ type ResponsesAPIEvent struct {
 	Data interface{}
 }
 
-// nextSequenceNumber increments and returns the sequence number for events
+// nextSequenceNumber returns the sequence number of the next event, the first one is 0 like in OpenAI streams
 func (p *responseProxy) nextSequenceNumber() int {
+	n := p.sequenceNumber
 	p.sequenceNumber++
-	return p.sequenceNumber
+	return n
+}
+
+// event builds an event of type typ, with the sequence number and the given fields
+func (p *responseProxy) event(typ string, fields map[string]interface{}) ResponsesAPIEvent {
+	data := map[string]interface{}{
+		"type":            typ,
+		"sequence_number": p.nextSequenceNumber(),
+	}
+	for k, v := range fields {
+		data[k] = v
+	}
+	return ResponsesAPIEvent{Type: typ, Data: data}
+}
+
+// responseObject is the `response` of response.created / in_progress / completed events,
+// and the body of non-streaming responses
+func (p *responseProxy) responseObject(status string) map[string]interface{} {
+	output := make([]interface{}, 0, len(p.items))
+	for _, it := range p.items {
+		output = append(output, it.toMap())
+	}
+
+	obj := map[string]interface{}{
+		"id":         p.responseID,
+		"object":     "response",
+		"created_at": p.createdAt,
+		"model":      p.model,
+		"status":     status,
+		"output":     output,
+	}
+	if status == "incomplete" {
+		obj["incomplete_details"] = map[string]interface{}{"reason": "max_output_tokens"}
+	}
+	if p.usage != nil {
+		obj["usage"] = convertUsage(p.usage)
+	}
+	return obj
+}
+
+// convertUsage maps Chat Completions usage to Responses API usage
+func convertUsage(u *ChatUsage) map[string]interface{} {
+	cached, reasoning := 0, 0
+	if u.PromptTokensDetails != nil {
+		cached = u.PromptTokensDetails.CachedTokens
+	}
+	if u.CompletionTokensDetails != nil {
+		reasoning = u.CompletionTokensDetails.ReasoningTokens
+	}
+
+	return map[string]interface{}{
+		"input_tokens":          u.PromptTokens,
+		"input_tokens_details":  map[string]interface{}{"cached_tokens": cached},
+		"output_tokens":         u.CompletionTokens,
+		"output_tokens_details": map[string]interface{}{"reasoning_tokens": reasoning},
+		"total_tokens":          u.TotalTokens,
+	}
+}
+
+// appendItem appends an output item at the next output_index
+func (p *responseProxy) appendItem(it *outputItem) {
+	it.OutputIndex = len(p.items)
+	p.items = append(p.items, it)
+}
+
+// addItem appends a new output item and returns its output_item.added event
+func (p *responseProxy) addItem(it *outputItem) ResponsesAPIEvent {
+	p.appendItem(it)
+	return p.event("response.output_item.added", map[string]interface{}{
+		"output_index": it.OutputIndex,
+		"item":         it.toMap(),
+	})
 }
 
 // convertChunkToResponsesEvents converts a Chat Completions chunk to Responses API events
@@ -222,177 +493,232 @@ func (p *responseProxy) nextSequenceNumber() int {
 func (p *responseProxy) convertChunkToResponsesEvents(ccChunk *ChatCompletionStreamChunk, isFirstChunk bool) []ResponsesAPIEvent {
 	var events []ResponsesAPIEvent
 
-	// CRITICAL: Always emit setup events on first chunk, even if no choices!
-	// This fixes the "OutputTextDelta without active item" error from Codex client
+	if ccChunk.Usage != nil {
+		p.usage = ccChunk.Usage
+	}
+
+	// Always emit response.created on the first chunk, even if it has no choices
 	if isFirstChunk {
-		// Generate message item ID
-		p.itemID = "msg_" + ccChunk.ID
-
-		// 1. Emit response.created event
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.created",
-			Data: map[string]interface{}{
-				"type":           "response.created",
-				"sequence_number": p.nextSequenceNumber(),
-				"response": map[string]interface{}{
-					"id":         ccChunk.ID,
-					"object":     "response",
-					"created_at": ccChunk.Created,
-					"status":     "in_progress",
-				},
-			},
-		})
+		p.initialized = true
+		p.model = ccChunk.Model
+		p.createdAt = ccChunk.Created
+
+		events = append(events,
+			p.event("response.created", map[string]interface{}{"response": p.responseObject("in_progress")}),
+			p.event("response.in_progress", map[string]interface{}{"response": p.responseObject("in_progress")}),
+		)
+	}
 
-		// 2. Emit response.in_progress event
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.in_progress",
-			Data: map[string]interface{}{
-				"type":           "response.in_progress",
-				"sequence_number": p.nextSequenceNumber(),
-				"response": map[string]interface{}{
-					"id":         ccChunk.ID,
-					"object":     "response",
-					"created_at": ccChunk.Created,
-					"status":     "in_progress",
-				},
-			},
-		})
+	// If no choices in this chunk (e.g. the usage chunk), there is nothing else to emit
+	if len(ccChunk.Choices) == 0 || p.itemsDone {
+		return events
+	}
 
-		// 3. Emit response.output_item.added
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.output_item.added",
-			Data: map[string]interface{}{
-				"type":           "response.output_item.added",
-				"sequence_number": p.nextSequenceNumber(),
-				"output_index":   0,
-				"item": map[string]interface{}{
-					"id":      p.itemID,
-					"type":    "message",
-					"role":    "assistant",
-					"status":  "in_progress",
-					"content": []interface{}{},
-				},
-			},
-		})
+	choice := ccChunk.Choices[0]
 
-		// 4. Emit response.content_part.added
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.content_part.added",
-			Data: map[string]interface{}{
-				"type":           "response.content_part.added",
-				"sequence_number": p.nextSequenceNumber(),
-				"item_id":        p.itemID,
-				"output_index":   0,
-				"content_index":  0,
-				"part": map[string]interface{}{
-					"type":        "output_text",
-					"text":        "",
-					"annotations": []interface{}{},
-				},
-			},
-		})
+	// Handle reasoning delta - only emitted with reasoning_summary, otherwise skipped.
+	// Reasoning precedes the answer, so the reasoning item comes first in output
+	// and is closed as soon as content or tool calls start.
+	if choice.Delta.ReasoningContent != "" && p.emitReasoning {
+		events = append(events, p.reasoningDelta(choice.Delta.ReasoningContent)...)
+	}
+	if (choice.Delta.Content != "" || len(choice.Delta.ToolCalls) > 0) && p.reasoning != nil && !p.reasoning.Done {
+		events = append(events, p.closeItem(p.reasoning)...)
+	}
+
+	// Handle content delta.
+	// The message item is opened right before its first delta, so a delta never
+	// arrives without an active item.
+	if choice.Delta.Content != "" {
+		if p.message == nil {
+			p.message = &outputItem{Type: "message", ID: p.itemID("msg")}
+			events = append(events,
+				p.addItem(p.message),
+				p.event("response.content_part.added", map[string]interface{}{
+					"item_id":       p.message.ID,
+					"output_index":  p.message.OutputIndex,
+					"content_index": 0,
+					"part":          outputTextPart(""),
+				}),
+			)
+		}
+
+		p.message.Text += choice.Delta.Content
+		events = append(events, p.event("response.output_text.delta", map[string]interface{}{
+			"item_id":       p.message.ID,
+			"output_index":  p.message.OutputIndex,
+			"content_index": 0,
+			"delta":         choice.Delta.Content,
+		}))
+	}
+
+	// Handle tool call deltas, parallel calls are told apart by their index.
+	// Some upstreams reuse an index for the next call, a new id starts a new item.
+	for _, tc := range choice.Delta.ToolCalls {
+		call := p.toolCalls[tc.Index]
+		if call != nil && tc.ID != "" && tc.ID != call.CallID && !call.ownCallID {
+			events = append(events, p.closeItem(call)...)
+			call = nil
+		}
+		if call == nil {
+			callID := tc.ID
+			if callID == "" {
+				callID = "call_" + util.RandGenerater(util.RandAlphanumeric, 24)
+			}
+			call = &outputItem{
+				Type:      "function_call",
+				ID:        "fc_" + callID,
+				CallID:    callID,
+				Name:      tc.Function.Name,
+				ownCallID: tc.ID == "",
+			}
+			p.toolCalls[tc.Index] = call
+			events = append(events, p.addItem(call))
+		} else if tc.Function.Name != "" && call.Name == "" {
+			call.Name = tc.Function.Name
+		}
 
-		p.contentPartAdded = true
+		if tc.Function.Arguments != "" {
+			call.Arguments += tc.Function.Arguments
+			events = append(events, p.event("response.function_call_arguments.delta", map[string]interface{}{
+				"item_id":      call.ID,
+				"output_index": call.OutputIndex,
+				"delta":        tc.Function.Arguments,
+			}))
+		}
+	}
+
+	// Handle finish reason, response.completed follows on [DONE] so usage can be included
+	if choice.FinishReason != nil {
+		p.finishReason = *choice.FinishReason
+		events = append(events, p.closeItems()...)
 	}
 
-	// If no choices in this chunk, return just the setup events
-	if len(ccChunk.Choices) == 0 {
+	return events
+}
+
+// reasoningDelta opens the reasoning item if needed and emits a reasoning summary delta
+func (p *responseProxy) reasoningDelta(delta string) []ResponsesAPIEvent {
+	var events []ResponsesAPIEvent
+
+	if p.reasoning == nil {
+		p.reasoning = &outputItem{Type: "reasoning", ID: p.itemID("rs")}
+		events = append(events,
+			p.addItem(p.reasoning),
+			p.event("response.reasoning_summary_part.added", map[string]interface{}{
+				"item_id":       p.reasoning.ID,
+				"output_index":  p.reasoning.OutputIndex,
+				"summary_index": 0,
+				"part":          summaryTextPart(""),
+			}),
+		)
+	}
+	if p.reasoning.Done {
+		// reasoning after the answer started cannot be reported anymore
+		logger.Debugf("ResponseTransform: dropping late reasoning delta")
 		return events
 	}
 
-	choice := ccChunk.Choices[0]
+	p.reasoning.Text += delta
+	return append(events, p.event("response.reasoning_summary_text.delta", map[string]interface{}{
+		"item_id":       p.reasoning.ID,
+		"output_index":  p.reasoning.OutputIndex,
+		"summary_index": 0,
+		"delta":         delta,
+	}))
+}
 
-	// Handle content delta - skip reasoning_content, only process actual content
-	if choice.Delta.Content != "" {
-		// Accumulate text for the done event
-		p.accumulatedText += choice.Delta.Content
-
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.output_text.delta",
-			Data: map[string]interface{}{
-				"type":           "response.output_text.delta",
-				"sequence_number": p.nextSequenceNumber(),
-				"item_id":        p.itemID,
-				"output_index":   0,
-				"content_index":  0,
-				"delta":          choice.Delta.Content,
-			},
-		})
+func summaryTextPart(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "summary_text",
+		"text": text,
 	}
+}
 
-	// Handle finish reason
-	if choice.FinishReason != nil {
-		// Emit response.content_part.done with full text
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.content_part.done",
-			Data: map[string]interface{}{
-				"type":           "response.content_part.done",
-				"sequence_number": p.nextSequenceNumber(),
-				"item_id":        p.itemID,
-				"output_index":   0,
-				"content_index":  0,
-				"part": map[string]interface{}{
-					"type":        "output_text",
-					"text":        p.accumulatedText,
-					"annotations": []interface{}{},
-				},
-			},
-		})
+// closeItems emits the done events of all open items in output_index order
+func (p *responseProxy) closeItems() []ResponsesAPIEvent {
+	var events []ResponsesAPIEvent
+	p.itemsDone = true
 
-		// Emit response.output_text.done with full text
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.output_text.done",
-			Data: map[string]interface{}{
-				"type":           "response.output_text.done",
-				"sequence_number": p.nextSequenceNumber(),
-				"item_id":        p.itemID,
-				"output_index":   0,
-				"content_index":  0,
-				"text":           p.accumulatedText,
-			},
-		})
+	for _, it := range p.items {
+		events = append(events, p.closeItem(it)...)
+	}
 
-		// Emit response.output_item.done with full content
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.output_item.done",
-			Data: map[string]interface{}{
-				"type":           "response.output_item.done",
-				"sequence_number": p.nextSequenceNumber(),
-				"output_index":   0,
-				"item": map[string]interface{}{
-					"id":     p.itemID,
-					"type":   "message",
-					"role":   "assistant",
-					"status": "completed",
-					"content": []interface{}{
-						map[string]interface{}{
-							"type":        "output_text",
-							"text":        p.accumulatedText,
-							"annotations": []interface{}{},
-						},
-					},
-				},
-			},
-		})
+	return events
+}
 
-		// Finally emit response.completed
-		events = append(events, ResponsesAPIEvent{
-			Type: "response.completed",
-			Data: map[string]interface{}{
-				"type":           "response.completed",
-				"sequence_number": p.nextSequenceNumber(),
-				"response": map[string]interface{}{
-					"id":         ccChunk.ID,
-					"object":     "response",
-					"created_at": ccChunk.Created,
-					"status":     "completed",
-				},
-			},
-		})
+// closeItem emits the done events of an open item
+func (p *responseProxy) closeItem(it *outputItem) []ResponsesAPIEvent {
+	if it.Done {
+		return nil
+	}
+	it.Done = true
 
-		// Mark as completed so we don't send duplicate response.completed on [DONE]
-		p.completed = true
+	var events []ResponsesAPIEvent
+	switch it.Type {
+	case "reasoning":
+		events = append(events,
+			p.event("response.reasoning_summary_text.done", map[string]interface{}{
+				"item_id":       it.ID,
+				"output_index":  it.OutputIndex,
+				"summary_index": 0,
+				"text":          it.Text,
+			}),
+			p.event("response.reasoning_summary_part.done", map[string]interface{}{
+				"item_id":       it.ID,
+				"output_index":  it.OutputIndex,
+				"summary_index": 0,
+				"part":          summaryTextPart(it.Text),
+			}),
+		)
+	case "function_call":
+		events = append(events, p.event("response.function_call_arguments.done", map[string]interface{}{
+			"item_id":      it.ID,
+			"output_index": it.OutputIndex,
+			"arguments":    it.Arguments,
+		}))
+	default:
+		events = append(events,
+			p.event("response.output_text.done", map[string]interface{}{
+				"item_id":       it.ID,
+				"output_index":  it.OutputIndex,
+				"content_index": 0,
+				"text":          it.Text,
+			}),
+			p.event("response.content_part.done", map[string]interface{}{
+				"item_id":       it.ID,
+				"output_index":  it.OutputIndex,
+				"content_index": 0,
+				"part":          outputTextPart(it.Text),
+			}),
+		)
 	}
 
+	events = append(events, p.event("response.output_item.done", map[string]interface{}{
+		"output_index": it.OutputIndex,
+		"item":         it.toMap(),
+	}))
 	return events
 }
+
+// completeEvents closes open items and emits response.completed (or response.incomplete) once
+func (p *responseProxy) completeEvents() []ResponsesAPIEvent {
+	if !p.initialized || p.completed {
+		return nil
+	}
+	p.completed = true
+
+	events := p.closeItems()
+
+	status := p.finalStatus()
+	p.final = p.responseObject(status)
+	return append(events, p.event("response."+status, map[string]interface{}{"response": p.final}))
+}
+
+// finalStatus is "incomplete" for responses cut off by the token limit, "completed" otherwise
+func (p *responseProxy) finalStatus() string {
+	if p.finishReason == "length" {
+		return "incomplete"
+	}
+	return "completed"
+}
@@ -0,0 +1,352 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+)
+
+// sseEventRecord is one event of a converted Responses API stream
+type sseEventRecord struct {
+	Type string
+	Data map[string]interface{}
+}
+
+// replayStream feeds a recorded Chat Completions stream through a responseProxy,
+// in small writes so events are split across lines, and returns the emitted events
+func replayStream(t *testing.T, fixture string) []sseEventRecord {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	p := &responseProxy{
+		ResponseWriter: c.Writer,
+		transform:      true,
+		responseID:     "resp_test",
+		toolCalls:      make(map[int]*outputItem),
+	}
+	p.Header().Set("Content-Type", "text/event-stream")
+	p.WriteHeader(http.StatusOK)
+
+	for len(data) > 0 {
+		n := min(17, len(data))
+		if _, err := p.Write(data[:n]); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	p.finish()
+
+	var events []sseEventRecord
+	for _, block := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		var ev sseEventRecord
+		for _, line := range strings.Split(block, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				ev.Type = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.Data); err != nil {
+					t.Fatalf("invalid event data %q: %v", line, err)
+				}
+			}
+		}
+		if ev.Type == "" || ev.Data["type"] != ev.Type {
+			t.Fatalf("malformed event %q", block)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// describe renders an event as "type@output_index", or just the type for response events
+func describe(ev sseEventRecord) string {
+	if oi, ok := ev.Data["output_index"].(float64); ok {
+		return ev.Type + "@" + strconv.Itoa(int(oi))
+	}
+	return ev.Type
+}
+
+func checkSequence(t *testing.T, events []sseEventRecord, want []string) {
+	t.Helper()
+
+	got := make([]string, len(events))
+	for i, ev := range events {
+		got[i] = describe(ev)
+		if seq := ev.Data["sequence_number"].(float64); int(seq) != i {
+			t.Errorf("event %d (%s) has sequence_number %v", i, ev.Type, seq)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("event order:\n got: %s\nwant: %s", strings.Join(got, ", "), strings.Join(want, ", "))
+	}
+}
+
+// completedOutput returns the output items of the final response.completed event
+func completedOutput(t *testing.T, events []sseEventRecord) []map[string]interface{} {
+	t.Helper()
+
+	last := events[len(events)-1]
+	if last.Type != "response.completed" {
+		t.Fatalf("last event is %s, want response.completed", last.Type)
+	}
+	var out []map[string]interface{}
+	for _, it := range last.Data["response"].(map[string]interface{})["output"].([]interface{}) {
+		out = append(out, it.(map[string]interface{}))
+	}
+	return out
+}
+
+func TestResponseTransformParallelToolCalls(t *testing.T) {
+	events := replayStream(t, "chat_parallel_tool_calls.sse")
+
+	checkSequence(t, events, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added@0",
+		"response.content_part.added@0",
+		"response.output_text.delta@0",
+		"response.output_text.delta@0",
+		"response.output_item.added@1",
+		"response.output_item.added@2",
+		"response.function_call_arguments.delta@1",
+		"response.function_call_arguments.delta@2",
+		"response.function_call_arguments.delta@1",
+		"response.function_call_arguments.delta@2",
+		"response.output_text.done@0",
+		"response.content_part.done@0",
+		"response.output_item.done@0",
+		"response.function_call_arguments.done@1",
+		"response.output_item.done@1",
+		"response.function_call_arguments.done@2",
+		"response.output_item.done@2",
+		"response.completed",
+	})
+
+	output := completedOutput(t, events)
+	if len(output) != 3 {
+		t.Fatalf("got %d output items, want 3", len(output))
+	}
+	text := output[0]["content"].([]interface{})[0].(map[string]interface{})["text"]
+	if text != "Checking both cities." {
+		t.Errorf("message text = %q", text)
+	}
+	for i, want := range []struct{ callID, args string }{
+		{"call_paris", `{"city":"Paris"}`},
+		{"call_tokyo", `{"city":"Tokyo"}`},
+	} {
+		it := output[i+1]
+		if it["call_id"] != want.callID || it["arguments"] != want.args || it["status"] != "completed" {
+			t.Errorf("output[%d] = %v, want call_id %s arguments %s", i+1, it, want.callID, want.args)
+		}
+	}
+
+	usage := events[len(events)-1].Data["response"].(map[string]interface{})["usage"].(map[string]interface{})
+	if usage["total_tokens"] != float64(123) {
+		t.Errorf("usage = %v", usage)
+	}
+}
+
+func TestResponseTransformReusedToolIndex(t *testing.T) {
+	events := replayStream(t, "chat_reused_tool_index.sse")
+
+	checkSequence(t, events, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added@0",
+		"response.function_call_arguments.delta@0",
+		"response.function_call_arguments.done@0",
+		"response.output_item.done@0",
+		"response.output_item.added@1",
+		"response.function_call_arguments.delta@1",
+		"response.function_call_arguments.done@1",
+		"response.output_item.done@1",
+		"response.completed",
+	})
+
+	output := completedOutput(t, events)
+	if len(output) != 2 {
+		t.Fatalf("got %d output items, want 2", len(output))
+	}
+	if output[0]["call_id"] != "call_a" || output[0]["name"] != "lookup" || output[0]["arguments"] != `{"q":"a"}` {
+		t.Errorf("output[0] = %v", output[0])
+	}
+	if output[1]["call_id"] != "call_b" || output[1]["name"] != "fetch" || output[1]["arguments"] != `{"url":"b"}` {
+		t.Errorf("output[1] = %v", output[1])
+	}
+}
+
+func TestResponseTransformRepeatedToolID(t *testing.T) {
+	events := replayStream(t, "chat_repeated_tool_id.sse")
+
+	checkSequence(t, events, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added@0",
+		"response.function_call_arguments.delta@0",
+		"response.function_call_arguments.delta@0",
+		"response.function_call_arguments.done@0",
+		"response.output_item.done@0",
+		"response.completed",
+	})
+
+	output := completedOutput(t, events)
+	if len(output) != 1 || output[0]["arguments"] != `{"q":"go"}` {
+		t.Errorf("output = %v", output)
+	}
+}
+
+// transformResponse runs an upstream Chat Completions response through ResponseTransform
+func transformResponse(t *testing.T, route *config.Route, respType, respBody string) converted {
+	t.Helper()
+	return conversion{
+		route:    route,
+		path:     "/v1/responses",
+		body:     `{"model":"m"}`,
+		respType: respType,
+		respBody: respBody,
+	}.run(t, ResponseTransform())
+}
+
+func TestResponseTransformJSON(t *testing.T) {
+	got := transformResponse(t, responsesToChatRoute, "application/json", `{"id":"chatcmpl-1","object":"chat.completion",
+		"created":1700000000,"model":"glm-4.6","choices":[{"index":0,"message":{"role":"assistant","content":"Checking.",
+		"tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},
+		"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15,
+		"prompt_tokens_details":{"cached_tokens":4},"completion_tokens_details":{"reasoning_tokens":2}}}`)
+
+	var resp map[string]interface{}
+	jsonUnmarshal(t, got.resp, &resp)
+	id, _ := resp["id"].(string)
+	if !strings.HasPrefix(id, "resp_") || resp["object"] != "response" || resp["status"] != "completed" ||
+		resp["model"] != "glm-4.6" || resp["created_at"] != float64(1700000000) {
+		t.Fatalf("response = %s", got.resp)
+	}
+
+	output := resp["output"].([]interface{})
+	if len(output) != 2 {
+		t.Fatalf("got %d output items, want 2: %s", len(output), got.resp)
+	}
+	msg := output[0].(map[string]interface{})
+	if msg["id"] != "msg_"+strings.TrimPrefix(id, "resp_") {
+		t.Errorf("message id = %v, response id = %s", msg["id"], id)
+	}
+	delete(msg, "id")
+	jsonEqual(t, "message", msg, `{"type":"message","role":"assistant","status":"completed",
+		"content":[{"type":"output_text","text":"Checking.","annotations":[]}]}`)
+	jsonEqual(t, "function_call", output[1], `{"id":"fc_call_1","type":"function_call","status":"completed",
+		"call_id":"call_1","name":"weather","arguments":"{\"city\":\"Paris\"}"}`)
+	jsonEqual(t, "usage", resp["usage"], `{"input_tokens":10,"input_tokens_details":{"cached_tokens":4},
+		"output_tokens":5,"output_tokens_details":{"reasoning_tokens":2},"total_tokens":15}`)
+}
+
+func TestResponseTransformJSONPassThrough(t *testing.T) {
+	for _, body := range []string{`{"error":{"message":"bad"}}`, `not json`} {
+		got := transformResponse(t, responsesToChatRoute, "application/json", body)
+		if got.resp != body {
+			t.Errorf("response = %q, want %q unchanged", got.resp, body)
+		}
+	}
+}
+
+func TestResponseTransformReasoning(t *testing.T) {
+	summary := *responsesToChatRoute
+	summary.ReasoningSummary = true
+
+	completion := `{"id":"chatcmpl-1","created":1,"model":"glm-4.6","choices":[{"index":0,
+		"message":{"role":"assistant","reasoning_content":"Think.","content":"Answer."},"finish_reason":"stop"}]}`
+	stream := sseChunks(
+		`{"id":"c","created":1,"model":"glm-4.6","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"Th"}}]}`,
+		`{"id":"c","created":1,"model":"glm-4.6","choices":[{"index":0,"delta":{"reasoning_content":"ink."}}]}`,
+		`{"id":"c","created":1,"model":"glm-4.6","choices":[{"index":0,"delta":{"content":"Answer."}}]}`,
+		`{"id":"c","created":1,"model":"glm-4.6","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	)
+
+	for _, tt := range []struct {
+		name   string
+		route  *config.Route
+		events []string // stream event types, without response.created and response.in_progress
+		output []string // output item types
+	}{
+		{
+			name:  "summary",
+			route: &summary,
+			events: []string{
+				"response.output_item.added@0",
+				"response.reasoning_summary_part.added@0",
+				"response.reasoning_summary_text.delta@0",
+				"response.reasoning_summary_text.delta@0",
+				"response.reasoning_summary_text.done@0",
+				"response.reasoning_summary_part.done@0",
+				"response.output_item.done@0",
+				"response.output_item.added@1",
+				"response.content_part.added@1",
+				"response.output_text.delta@1",
+				"response.output_text.done@1",
+				"response.content_part.done@1",
+				"response.output_item.done@1",
+				"response.completed",
+			},
+			output: []string{"reasoning", "message"},
+		},
+		{
+			name:  "no summary",
+			route: responsesToChatRoute,
+			events: []string{
+				"response.output_item.added@0",
+				"response.content_part.added@0",
+				"response.output_text.delta@0",
+				"response.output_text.done@0",
+				"response.content_part.done@0",
+				"response.output_item.done@0",
+				"response.completed",
+			},
+			output: []string{"message"},
+		},
+	} {
+		var resp map[string]interface{}
+		jsonUnmarshal(t, transformResponse(t, tt.route, "application/json", completion).resp, &resp)
+		checkReasoningOutput(t, tt.name+" (json)", resp["output"].([]interface{}), tt.output)
+
+		var events []sseEventRecord
+		for _, data := range parseSSE(t, transformResponse(t, tt.route, "text/event-stream", stream).resp) {
+			typ, _ := data["event"].(string)
+			delete(data, "event")
+			events = append(events, sseEventRecord{Type: typ, Data: data})
+		}
+		checkSequence(t, events, append([]string{"response.created", "response.in_progress"}, tt.events...))
+		final := events[len(events)-1].Data["response"].(map[string]interface{})
+		checkReasoningOutput(t, tt.name+" (stream)", final["output"].([]interface{}), tt.output)
+	}
+}
+
+// checkReasoningOutput checks the output item types, and the summary of a reasoning item
+func checkReasoningOutput(t *testing.T, what string, output []interface{}, want []string) {
+	t.Helper()
+
+	var got []string
+	for _, it := range output {
+		item := it.(map[string]interface{})
+		got = append(got, item["type"].(string))
+		if item["type"] == "reasoning" {
+			jsonEqual(t, what+" summary", item["summary"], `[{"type":"summary_text","text":"Think."}]`)
+		}
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("%s: output items %v, want %v", what, got, want)
+	}
+}
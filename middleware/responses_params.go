@@ -0,0 +1,201 @@
+package middleware
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// paramMapping maps a Responses API parameter to a Chat Completions parameter
+type paramMapping struct {
+	to string // Chat Completions field, "" if the value is never sent upstream
+
+	// optional value conversion, ok=false means the value has no Chat Completions
+	// equivalent, a nil result means nothing is sent
+	convert func(v json.RawMessage) (out json.RawMessage, ok bool)
+}
+
+// responsesParams maps Responses API parameters, nested ones by dotted path.
+// Values are copied as sent, so explicit zeros like "temperature": 0 are kept.
+// Parameters missing here are unsupported, see Route.UnsupportedParams.
+var responsesParams = map[string]paramMapping{
+	"temperature":         {to: "temperature"},
+	"top_p":               {to: "top_p"},
+	"max_output_tokens":   {to: "max_tokens"},
+	"top_logprobs":        {to: "top_logprobs"}, // also sets logprobs, see convertParams
+	"tool_choice":         {to: "tool_choice", convert: convertToolChoice},
+	"parallel_tool_calls": {to: "parallel_tool_calls"},
+	"metadata":            {to: "metadata"},
+	"user":                {to: "user"},
+	"safety_identifier":   {to: "safety_identifier"},
+	"prompt_cache_key":    {to: "prompt_cache_key"},
+	"service_tier":        {to: "service_tier"},
+	"text.format":         {to: "response_format", convert: convertTextFormat},
+	"text.verbosity":      {to: "verbosity"},
+	"reasoning.effort":    {to: "reasoning_effort"},
+	// reported through reasoning_content when the route enables reasoning_summary
+	"reasoning.summary":          {convert: dropParam},
+	"reasoning.generate_summary": {convert: dropParam}, // deprecated name of reasoning.summary
+	// extra output like reasoning.encrypted_content, sent by Codex on every request,
+	// there is nothing to include from a Chat Completions upstream
+	"include": {convert: dropParam},
+	// stream delivery options of the Responses API (include_obfuscation)
+	"stream_options": {convert: dropParam},
+	// caching hints, upstreams cache on their own terms
+	"prompt_cache_retention": {convert: dropParam},
+	// Chat Completions never truncates, which is what "disabled" asks for
+	"truncation": {convert: func(v json.RawMessage) (json.RawMessage, bool) {
+		return nil, string(v) == `"disabled"`
+	}},
+	// requests are always answered in the foreground
+	"background": {convert: func(v json.RawMessage) (json.RawMessage, bool) {
+		return nil, string(v) == "false"
+	}},
+}
+
+// fields of the Responses API request that are converted by convertResponsesToChat
+var responsesFields = map[string]bool{
+	"model":                true,
+	"input":                true,
+	"instructions":         true,
+	"tools":                true,
+	"stream":               true,
+	"store":                true, // see ResponsesHistory
+	"previous_response_id": true,
+}
+
+func dropParam(json.RawMessage) (json.RawMessage, bool) {
+	return nil, true
+}
+
+// convertParams maps the parameters of a raw Responses API request with responsesParams.
+// It returns the Chat Completions parameters and the names of unsupported parameters.
+func convertParams(raw map[string]json.RawMessage) (map[string]json.RawMessage, []string) {
+	out := make(map[string]json.RawMessage)
+	var unsupported []string
+
+	apply := func(name string, v json.RawMessage) {
+		m, ok := responsesParams[name]
+		if !ok {
+			unsupported = append(unsupported, name)
+			return
+		}
+		if m.convert != nil {
+			if v, ok = m.convert(v); !ok {
+				unsupported = append(unsupported, name)
+				return
+			}
+		}
+		if v != nil && m.to != "" {
+			out[m.to] = v
+		}
+	}
+
+	for _, key := range sortedKeys(raw) {
+		v := raw[key]
+		if responsesFields[key] || string(v) == "null" {
+			continue
+		}
+
+		// nested parameters like text.format are mapped one by one
+		if nestedParam(key) {
+			var nested map[string]json.RawMessage
+			if err := json.Unmarshal(v, &nested); err != nil {
+				unsupported = append(unsupported, key)
+				continue
+			}
+			for _, sub := range sortedKeys(nested) {
+				if string(nested[sub]) != "null" {
+					apply(key+"."+sub, nested[sub])
+				}
+			}
+			continue
+		}
+
+		apply(key, v)
+	}
+
+	// Chat Completions only returns top_logprobs together with logprobs
+	if _, ok := out["top_logprobs"]; ok {
+		out["logprobs"] = json.RawMessage("true")
+	}
+
+	return out, unsupported
+}
+
+func nestedParam(key string) bool {
+	for name := range responsesParams {
+		if strings.HasPrefix(name, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// convertTextFormat converts text.format to response_format
+// Responses API: {"type": "json_schema", "name": "x", "schema": {...}, "strict": true}
+// Chat Completions: {"type": "json_schema", "json_schema": {"name": "x", "schema": {...}, "strict": true}}
+func convertTextFormat(v json.RawMessage) (json.RawMessage, bool) {
+	var format map[string]json.RawMessage
+	if err := json.Unmarshal(v, &format); err != nil {
+		return nil, false
+	}
+
+	var typ string
+	json.Unmarshal(format["type"], &typ)
+
+	switch typ {
+	case "text":
+		// the default, nothing to send
+		return nil, true
+	case "json_object":
+		return json.RawMessage(`{"type":"json_object"}`), true
+	case "json_schema":
+		schema := make(map[string]json.RawMessage)
+		for k, fv := range format {
+			if k != "type" {
+				schema[k] = fv
+			}
+		}
+		out, err := json.Marshal(map[string]interface{}{
+			"type":        "json_schema",
+			"json_schema": schema,
+		})
+		return out, err == nil
+	}
+	return nil, false
+}
+
+// convertToolChoice converts tool_choice, function choices move into a "function" object
+// Responses API: {"type": "function", "name": "x"}
+// Chat Completions: {"type": "function", "function": {"name": "x"}}
+func convertToolChoice(v json.RawMessage) (json.RawMessage, bool) {
+	var mode string
+	if json.Unmarshal(v, &mode) == nil {
+		return v, true // "none", "auto", "required"
+	}
+
+	var choice struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(v, &choice); err != nil || choice.Type != "function" || choice.Name == "" {
+		// built-in tools are not forwarded, see convertTools
+		return nil, false
+	}
+
+	out, err := json.Marshal(map[string]interface{}{
+		"type":     "function",
+		"function": map[string]string{"name": choice.Name},
+	})
+	return out, err == nil
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/poixeai/proxify/infra/config"
+)
+
+func TestConvertParams(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		want        string // Chat Completions parameters
+		unsupported string
+	}{
+		{
+			name: "sampling and limits",
+			body: `{"model":"m","input":"x","temperature":0,"top_p":1,"max_output_tokens":100,"user":"u",
+				"metadata":{"a":"b"},"safety_identifier":"s","prompt_cache_key":"k","service_tier":"auto","parallel_tool_calls":false}`,
+			want: `{"temperature":0,"top_p":1,"max_tokens":100,"user":"u","metadata":{"a":"b"},"safety_identifier":"s",
+				"prompt_cache_key":"k","service_tier":"auto","parallel_tool_calls":false}`,
+		},
+		{
+			name: "top_logprobs also asks for logprobs",
+			body: `{"top_logprobs":3}`,
+			want: `{"top_logprobs":3,"logprobs":true}`,
+		},
+		{
+			name: "nested text and reasoning",
+			body: `{"text":{"format":{"type":"json_schema","name":"x","schema":{"type":"object"},"strict":true},"verbosity":"low"},
+				"reasoning":{"effort":"high","summary":"auto","generate_summary":null}}`,
+			want: `{"response_format":{"type":"json_schema","json_schema":{"name":"x","schema":{"type":"object"},"strict":true}},
+				"verbosity":"low","reasoning_effort":"high"}`,
+		},
+		{
+			name: "json_object and default text format",
+			body: `{"text":{"format":{"type":"json_object"}}}`,
+			want: `{"response_format":{"type":"json_object"}}`,
+		},
+		{
+			name: "text format is the default",
+			body: `{"text":{"format":{"type":"text"}}}`,
+			want: `{}`,
+		},
+		{
+			name: "tool choice",
+			body: `{"tool_choice":{"type":"function","name":"f"}}`,
+			want: `{"tool_choice":{"type":"function","function":{"name":"f"}}}`,
+		},
+		{
+			name: "tool choice mode",
+			body: `{"tool_choice":"required"}`,
+			want: `{"tool_choice":"required"}`,
+		},
+		{
+			name: "dropped parameters",
+			body: `{"include":["reasoning.encrypted_content"],"stream_options":{"include_obfuscation":false},
+				"prompt_cache_retention":"24h","truncation":"disabled","background":false,"temperature":null}`,
+			want: `{}`,
+		},
+		{
+			name:        "unsupported parameters",
+			body:        `{"truncation":"auto","background":true,"tool_choice":{"type":"web_search"},"conversation":"c","text":"x","temperature":1}`,
+			want:        `{"temperature":1}`,
+			unsupported: "background, conversation, text, tool_choice, truncation",
+		},
+	}
+
+	for _, tt := range tests {
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(tt.body), &raw); err != nil {
+			t.Fatal(err)
+		}
+		params, unsupported := convertParams(raw)
+		jsonEqual(t, tt.name, params, tt.want)
+		if got := strings.Join(unsupported, ", "); got != tt.unsupported {
+			t.Errorf("%s: unsupported = %q, want %q", tt.name, got, tt.unsupported)
+		}
+	}
+}
+
+func TestResponsesToChatUnsupportedParams(t *testing.T) {
+	body := `{"model":"m","store":false,"input":"x","temperature":0.5,"background":true}`
+
+	got := responsesRequest(t, responsesToChatRoute, body)
+	jsonEqual(t, "dropped", got.body, `{"model":"m","temperature":0.5,"messages":[{"role":"user","content":"x"}]}`)
+
+	reject := *responsesToChatRoute
+	reject.UnsupportedParams = config.UnsupportedParamsReject
+	got = conversion{route: &reject, path: "/v1/responses", body: body}.run(t, ResponsesToChat())
+	if got.status != http.StatusBadRequest || !strings.Contains(got.resp, "background") || got.body != nil {
+		t.Errorf("status = %d, response = %s", got.status, got.resp)
+	}
+}
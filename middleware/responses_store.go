@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/conversation"
+	"github.com/poixeai/proxify/infra/ctx"
+	"github.com/poixeai/proxify/infra/keys"
+	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
+)
+
+// storedResponseID returns {id} of a /responses/{id} path
+func storedResponseID(path string) (string, bool) {
+	i := strings.LastIndex(path, "/responses/")
+	if i < 0 {
+		return "", false
+	}
+	id := path[i+len("/responses/"):]
+	return id, id != "" && !strings.Contains(id, "/")
+}
+
+// handleStoredResponse answers GET and DELETE /responses/{id} from the conversation store
+func handleStoredResponse(c *gin.Context, route *config.Route, id string) {
+	rec, err := loadRecord(c, route, id)
+	if err != nil {
+		respondRecordError(c, id, err)
+		return
+	}
+
+	if c.Request.Method == http.MethodDelete {
+		if err := conversation.Default().Delete(id); err != nil && !errors.Is(err, conversation.ErrNotFound) {
+			logger.Errorf("ResponsesToChat: failed to delete response %s: %v", id, err)
+			response.RespondInternalError(c)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"id": id, "object": "response", "deleted": true})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/json", rec.Response)
+}
+
+// loadRecord returns a stored response, only visible on its route and to the API key that created it
+func loadRecord(c *gin.Context, route *config.Route, id string) (*conversation.Record, error) {
+	rec, err := conversation.Default().Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if rec.Route != route.Path || rec.KeyID != requestKeyID(c) {
+		return nil, conversation.ErrNotFound
+	}
+	return rec, nil
+}
+
+func respondRecordError(c *gin.Context, id string, err error) {
+	if errors.Is(err, conversation.ErrNotFound) {
+		response.RespondError(c, http.StatusNotFound,
+			fmt.Sprintf("Response with id '%s' not found.", id),
+			response.NOT_FOUND_ERROR)
+		return
+	}
+	logger.Errorf("ResponsesToChat: failed to load response %s: %v", id, err)
+	response.RespondInternalError(c)
+}
+
+// previousMessages returns the conversation stored for previous_response_id
+func previousMessages(c *gin.Context, route *config.Route, id string) ([]ChatMessage, error) {
+	rec, err := loadRecord(c, route, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []ChatMessage
+	if err := json.Unmarshal(rec.Messages, &messages); err != nil {
+		return nil, fmt.Errorf("stored messages: %w", err)
+	}
+	return messages, nil
+}
+
+func requestKeyID(c *gin.Context) string {
+	if v, ok := c.Get(ctx.APIKey); ok {
+		return v.(*keys.Key).ID
+	}
+	return ""
+}
+
+// storeResponse saves the request messages and the output of a completed response
+func storeResponse(c *gin.Context, route *config.Route, history []ChatMessage, p *responseProxy) {
+	if msg, ok := p.outputMessage(); ok {
+		history = append(history, msg)
+	}
+
+	messages, err := json.Marshal(history)
+	if err != nil {
+		logger.Warnf("ResponseTransform: failed to marshal history: %v", err)
+		return
+	}
+	resp, err := json.Marshal(p.final)
+	if err != nil {
+		logger.Warnf("ResponseTransform: failed to marshal response: %v", err)
+		return
+	}
+
+	rec := &conversation.Record{
+		ID:        p.responseID,
+		CreatedAt: time.Now().UTC(),
+		Route:     route.Path,
+		KeyID:     requestKeyID(c),
+		Messages:  messages,
+		Response:  resp,
+	}
+	if err := conversation.Default().Put(rec); err != nil {
+		logger.Warnf("ResponseTransform: failed to store response %s: %v", rec.ID, err)
+	}
+}
+
+// outputMessage is the assistant message of the response, as sent back in later turns.
+// Reasoning items are not part of it, like in convertResponsesToChat.
+func (p *responseProxy) outputMessage() (ChatMessage, bool) {
+	msg := ChatMessage{Role: "assistant"}
+	for _, it := range p.items {
+		switch it.Type {
+		case "message":
+			msg.Content = it.Text
+		case "function_call":
+			call := ChatToolCall{ID: it.CallID, Type: "function"}
+			call.Function.Name = it.Name
+			call.Function.Arguments = it.Arguments
+			if call.Function.Arguments == "" {
+				call.Function.Arguments = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, call)
+		}
+	}
+	return msg, msg.Content != nil || len(msg.ToolCalls) > 0
+}
@@ -3,47 +3,83 @@ package middleware
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/poixeai/proxify/infra/config"
+	"github.com/poixeai/proxify/infra/conversation"
 	"github.com/poixeai/proxify/infra/ctx"
 	"github.com/poixeai/proxify/infra/logger"
+	"github.com/poixeai/proxify/infra/response"
 )
 
-// ResponsesAPIRequest represents the OpenAI Responses API request format
+// ResponsesAPIRequest represents the OpenAI Responses API request format.
+// Sampling and other parameters are mapped by convertParams.
 type ResponsesAPIRequest struct {
-	Model             string                 `json:"model"`
-	Input             interface{}            `json:"input"` // can be string or array
-	Instructions      string                 `json:"instructions,omitempty"`
-	Store             bool                   `json:"store,omitempty"`
-	PreviousResponseID string                `json:"previous_response_id,omitempty"`
-	Tools             []interface{}          `json:"tools,omitempty"`
-	ToolChoice        interface{}            `json:"tool_choice,omitempty"`
-	MaxOutputTokens   int                    `json:"max_output_tokens,omitempty"`
-	Temperature       float64                `json:"temperature,omitempty"`
-	TopP              float64                `json:"top_p,omitempty"`
-	Truncation        string                 `json:"truncation,omitempty"`
-	Metadata          map[string]interface{} `json:"metadata,omitempty"`
-	Stream            bool                   `json:"stream,omitempty"`
+	Model              string        `json:"model"`
+	Input              interface{}   `json:"input"` // can be string or array
+	Instructions       string        `json:"instructions,omitempty"`
+	Store              *bool         `json:"store,omitempty"` // absent means true
+	PreviousResponseID string        `json:"previous_response_id,omitempty"`
+	Tools              []interface{} `json:"tools,omitempty"`
+	Stream             bool          `json:"stream,omitempty"`
 }
 
 // ChatCompletionRequest represents the OpenAI Chat Completions API request format
 type ChatCompletionRequest struct {
-	Model            string                 `json:"model"`
-	Messages         []ChatMessage          `json:"messages"`
-	MaxTokens        int                    `json:"max_tokens,omitempty"`
-	Temperature      float64                `json:"temperature,omitempty"`
-	TopP             float64                `json:"top_p,omitempty"`
-	Stream           bool                   `json:"stream,omitempty"`
-	Tools            []interface{}          `json:"tools,omitempty"`
-	ToolChoice       interface{}            `json:"tool_choice,omitempty"`
+	Model    string        `json:"model"`
+	Messages []ChatMessage `json:"messages"`
+	Stream   bool          `json:"stream,omitempty"`
+	Tools    []interface{} `json:"tools,omitempty"`
+
+	// mapped parameters like temperature, as sent by the client
+	Params map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON adds Params to the request fields
+func (r *ChatCompletionRequest) MarshalJSON() ([]byte, error) {
+	type request ChatCompletionRequest
+	base, err := json.Marshal((*request)(r))
+	if err != nil || len(r.Params) == 0 {
+		return base, err
+	}
+
+	fields := make(map[string]json.RawMessage, len(r.Params)+4)
+	for k, v := range r.Params {
+		fields[k] = v
+	}
+	if err := json.Unmarshal(base, &fields); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON collects all fields besides model, messages, stream and tools in Params
+func (r *ChatCompletionRequest) UnmarshalJSON(data []byte) error {
+	type request ChatCompletionRequest
+	if err := json.Unmarshal(data, (*request)(r)); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, &r.Params); err != nil {
+		return err
+	}
+	for _, k := range []string{"model", "messages", "stream", "tools"} {
+		delete(r.Params, k)
+	}
+	return nil
 }
 
 // ChatMessage represents a single message in chat completions
 type ChatMessage struct {
-	Role    string      `json:"role"`
-	Content interface{} `json:"content"`
+	Role       string         `json:"role"`
+	Content    interface{}    `json:"content"`
+	ToolCalls  []ChatToolCall `json:"tool_calls,omitempty"`   // assistant messages
+	ToolCallID string         `json:"tool_call_id,omitempty"` // tool messages
 }
 
 // ResponsesToChatMiddleware converts Responses API requests to Chat Completions API format
@@ -51,13 +87,21 @@ type ChatMessage struct {
 func ResponsesToChat() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		route := ctx.GetRoute(c)
-		if route == nil || route.Transform != "responses_to_chat" {
+		if route == nil || route.Transform != config.TransformResponsesToChat {
 			c.Next()
 			return
 		}
 
 		logger.Infof("ResponsesToChat: route=%s, path=%s, method=%s", route.Name, c.Request.URL.Path, c.Request.Method)
 
+		// Stored responses are served by Proxify, the upstream has no state
+		if id, ok := storedResponseID(c.Request.URL.Path); ok &&
+			(c.Request.Method == http.MethodGet || c.Request.Method == http.MethodDelete) {
+			handleStoredResponse(c, route, id)
+			c.Abort()
+			return
+		}
+
 		// Only handle POST requests to /responses endpoint
 		if c.Request.Method != "POST" || !strings.HasSuffix(c.Request.URL.Path, "/responses") {
 			logger.Infof("ResponsesToChat: skipping (not POST /responses)")
@@ -84,7 +128,49 @@ func ResponsesToChat() gin.HandlerFunc {
 		}
 
 		// Convert to Chat Completions format
-		chatReq := convertResponsesToChat(&respReq)
+		chatReq := convertResponsesToChat(&respReq, route.TextOnly)
+
+		// Map parameters, unsupported ones are dropped or rejected by route policy
+		var raw map[string]json.RawMessage
+		json.Unmarshal(bodyBytes, &raw)
+		params, unsupported := convertParams(raw)
+		if len(unsupported) > 0 {
+			if route.UnsupportedParams == config.UnsupportedParamsReject {
+				response.RespondError(c, http.StatusBadRequest,
+					fmt.Sprintf("Unsupported parameters for this route: %s.", strings.Join(unsupported, ", ")),
+					response.INVALID_REQUEST_ERROR)
+				c.Abort()
+				return
+			}
+			logger.Infof("ResponsesToChat: dropping unsupported parameters: %s", strings.Join(unsupported, ", "))
+		}
+		chatReq.Params = params
+
+		// Continue a stored conversation: instructions, then the stored messages, then the new input
+		n := 0
+		if respReq.Instructions != "" {
+			n = 1
+		}
+		history := chatReq.Messages[n:]
+		if respReq.PreviousResponseID != "" {
+			previous, err := previousMessages(c, route, respReq.PreviousResponseID)
+			if errors.Is(err, conversation.ErrNotFound) {
+				response.RespondError(c, http.StatusNotFound,
+					fmt.Sprintf("Previous response with id '%s' not found.", respReq.PreviousResponseID),
+					response.NOT_FOUND_ERROR)
+				c.Abort()
+				return
+			} else if err != nil {
+				respondRecordError(c, respReq.PreviousResponseID, err)
+				c.Abort()
+				return
+			}
+			history = append(previous, history...)
+			chatReq.Messages = append(chatReq.Messages[:n:n], history...)
+		}
+		if respReq.Store == nil || *respReq.Store {
+			c.Set(ctx.ResponsesHistory, history)
+		}
 
 		// Serialize the converted request
 		newBody, err := json.Marshal(chatReq)
@@ -103,25 +189,22 @@ func ResponsesToChat() gin.HandlerFunc {
 		c.Request.Body = io.NopCloser(bytes.NewReader(newBody))
 		c.Request.ContentLength = int64(len(newBody))
 
+		// the response is converted by ResponseTransform, so it must not be compressed
+		c.Request.Header.Del("Accept-Encoding")
+
 		logger.Infof("ResponsesToChat: converted request for model=%s", respReq.Model)
 
 		c.Next()
 	}
 }
 
-// convertResponsesToChat converts Responses API request to Chat Completions format
-func convertResponsesToChat(respReq *ResponsesAPIRequest) *ChatCompletionRequest {
+// convertResponsesToChat converts Responses API request to Chat Completions format.
+// textOnly is set for upstreams that only accept string message content.
+func convertResponsesToChat(respReq *ResponsesAPIRequest, textOnly bool) *ChatCompletionRequest {
 	chatReq := &ChatCompletionRequest{
-		Model:       respReq.Model,
-		Temperature: respReq.Temperature,
-		TopP:        respReq.TopP,
-		Stream:      respReq.Stream,
-		Tools:       convertTools(respReq.Tools), // Convert tools format
-		ToolChoice:  respReq.ToolChoice,
-	}
-
-	if respReq.MaxOutputTokens > 0 {
-		chatReq.MaxTokens = respReq.MaxOutputTokens
+		Model:  respReq.Model,
+		Stream: respReq.Stream,
+		Tools:  convertTools(respReq.Tools), // Convert tools format
 	}
 
 	// Build messages array
@@ -144,29 +227,49 @@ func convertResponsesToChat(respReq *ResponsesAPIRequest) *ChatCompletionRequest
 			Content: v,
 		})
 	case []interface{}:
-		// Array format - each item should be a message object
+		// Array format - message items, plus function_call / function_call_output items of agent turns
 		for _, item := range v {
-			if msg, ok := item.(map[string]interface{}); ok {
-				role, _ := msg["role"].(string)
-				content := msg["content"]
-
-				// Convert unsupported roles to supported ones
-				// "developer" -> "system" (Codex uses developer role)
-				if role == "developer" {
-					role = "system"
-				}
+			msg, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
 
-				// Convert content to string if it's an array
-				// Codex sends: [{"type": "input_text", "text": "actual content"}]
-				// Zhipu expects: "actual content"
-				contentStr := convertContentToString(content)
+			switch itemType, _ := msg["type"].(string); itemType {
+			case "function_call":
+				messages = appendToolCall(messages, msg)
+				continue
+			case "function_call_output":
+				callID, _ := msg["call_id"].(string)
+				messages = append(messages, ChatMessage{
+					Role:       "tool",
+					Content:    convertToolOutput(msg["output"]),
+					ToolCallID: callID,
+				})
+				continue
+			case "reasoning":
+				// reasoning items cannot be sent back to Chat Completions upstreams
+				continue
+			}
 
-				if role != "" && contentStr != "" {
-					messages = append(messages, ChatMessage{
-						Role:    role,
-						Content: contentStr,
-					})
-				}
+			role, _ := msg["role"].(string)
+			content := msg["content"]
+
+			// Convert unsupported roles to supported ones
+			// "developer" -> "system" (Codex uses developer role)
+			if role == "developer" {
+				role = "system"
+			}
+
+			// Convert content parts
+			// Codex sends: [{"type": "input_text", "text": "actual content"}]
+			// Zhipu expects: "actual content"
+			converted := convertContent(content, textOnly)
+
+			if role != "" && converted != nil {
+				messages = append(messages, ChatMessage{
+					Role:    role,
+					Content: converted,
+				})
 			}
 		}
 	default:
@@ -184,6 +287,61 @@ func convertResponsesToChat(respReq *ResponsesAPIRequest) *ChatCompletionRequest
 	return chatReq
 }
 
+// appendToolCall adds a function_call input item as an assistant tool call.
+// Consecutive calls, and calls right after an assistant message, share one assistant message.
+func appendToolCall(messages []ChatMessage, item map[string]interface{}) []ChatMessage {
+	callID, _ := item["call_id"].(string)
+	if callID == "" {
+		callID, _ = item["id"].(string)
+	}
+	name, _ := item["name"].(string)
+	arguments, _ := item["arguments"].(string)
+	if arguments == "" {
+		arguments = "{}"
+	}
+
+	call := ChatToolCall{ID: callID, Type: "function"}
+	call.Function.Name = name
+	call.Function.Arguments = arguments
+
+	if n := len(messages); n > 0 && messages[n-1].Role == "assistant" {
+		messages[n-1].ToolCalls = append(messages[n-1].ToolCalls, call)
+		return messages
+	}
+	return append(messages, ChatMessage{
+		Role:      "assistant",
+		Content:   nil,
+		ToolCalls: []ChatToolCall{call},
+	})
+}
+
+// convertToolOutput converts a function_call_output output to tool message content.
+// Outputs are usually strings, content part arrays are joined, anything else is sent as JSON.
+func convertToolOutput(output interface{}) string {
+	switch o := output.(type) {
+	case nil:
+		return ""
+	case string:
+		return o
+	case []interface{}:
+		var texts []string
+		for _, part := range o {
+			if p, ok := part.(map[string]interface{}); ok {
+				if text, ok := p["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+
+	b, err := json.Marshal(output)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
 // convertTools converts tools from Responses API format to Chat Completions format
 // Responses API: {"type": "function", "name": "xxx", "parameters": {...}}
 // Chat Completions: {"type": "function", "function": {"name": "xxx", "parameters": {...}}}
@@ -248,26 +406,119 @@ func convertTools(tools []interface{}) []interface{} {
 	return converted
 }
 
-// convertContentToString converts content to string format
-// Handles both string content and array content with type/text fields
-func convertContentToString(content interface{}) string {
+// placeholders sent to text_only upstreams instead of images and files
+const (
+	imagePlaceholder = "[image]"
+	filePlaceholder  = "[file: %s]"
+)
+
+// convertContent converts Responses API content to Chat Completions content.
+// Text-only content becomes a string (text parts joined by newlines), content with
+// images or files becomes an array of parts. With textOnly, images and files are
+// replaced by placeholders so the result is always a string.
+// Returns nil if there is nothing to send.
+func convertContent(content interface{}, textOnly bool) interface{} {
 	switch c := content.(type) {
 	case string:
+		if c == "" {
+			return nil
+		}
 		return c
 	case []interface{}:
-		// Array of content parts - extract text from each
 		var texts []string
+		var parts []interface{}
+		multimodal := false
+
 		for _, part := range c {
-			if p, ok := part.(map[string]interface{}); ok {
-				// Handle {"type": "input_text", "text": "content"}
+			p, ok := part.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			partType, _ := p["type"].(string)
+			switch partType {
+			case "input_image":
+				if textOnly {
+					texts = append(texts, imagePlaceholder)
+					continue
+				}
+				if image := convertImagePart(p); image != nil {
+					multimodal = true
+					parts = append(parts, image)
+				}
+			case "input_file":
+				if textOnly {
+					texts = append(texts, fmt.Sprintf(filePlaceholder, fileName(p)))
+					continue
+				}
+				if file := convertFilePart(p); file != nil {
+					multimodal = true
+					parts = append(parts, file)
+				}
+			default:
+				// input_text, output_text, text
 				if text, ok := p["text"].(string); ok {
 					texts = append(texts, text)
+					parts = append(parts, map[string]interface{}{"type": "text", "text": text})
 				}
 			}
 		}
+
+		if multimodal {
+			return parts
+		}
 		if len(texts) > 0 {
-			return texts[0] // Return first text for simplicity
+			return strings.Join(texts, "\n")
+		}
+	}
+	return nil
+}
+
+// convertImagePart converts an input_image part to an image_url part.
+// image_url is either an http(s) URL or a base64 data URL, both are passed on as is.
+func convertImagePart(p map[string]interface{}) map[string]interface{} {
+	url, _ := p["image_url"].(string)
+	if url == "" {
+		// file_id references only exist on OpenAI
+		logger.Warnf("ResponsesToChat: skipping input_image without image_url")
+		return nil
+	}
+
+	imageURL := map[string]interface{}{"url": url}
+	if detail, _ := p["detail"].(string); detail != "" {
+		imageURL["detail"] = detail
+	}
+	return map[string]interface{}{
+		"type":      "image_url",
+		"image_url": imageURL,
+	}
+}
+
+// convertFilePart converts an input_file part to a file part, for upstreams that accept files
+// as file_id or base64 file_data. file_url has no Chat Completions equivalent.
+func convertFilePart(p map[string]interface{}) map[string]interface{} {
+	file := make(map[string]interface{})
+	for _, key := range []string{"file_id", "file_data", "filename"} {
+		if v, _ := p[key].(string); v != "" {
+			file[key] = v
+		}
+	}
+	if file["file_id"] == nil && file["file_data"] == nil {
+		logger.Warnf("ResponsesToChat: skipping input_file without file_id or file_data")
+		return nil
+	}
+	return map[string]interface{}{
+		"type": "file",
+		"file": file,
+	}
+}
+
+// fileName names an input_file part in placeholders
+func fileName(p map[string]interface{}) string {
+	for _, key := range []string{"filename", "file_url", "file_id"} {
+		if v, _ := p[key].(string); v != "" {
+			return v
 		}
 	}
-	return ""
+	return "attachment"
 }
@@ -0,0 +1,113 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/poixeai/proxify/infra/config"
+)
+
+var responsesToChatRoute = &config.Route{Name: "glm", Path: "/glm", Transform: config.TransformResponsesToChat}
+
+// responsesRequest runs a Responses API request through ResponsesToChat and returns
+// the Chat Completions body sent upstream
+func responsesRequest(t *testing.T, route *config.Route, body string) converted {
+	t.Helper()
+	return conversion{route: route, path: "/v1/responses", body: body}.run(t, ResponsesToChat())
+}
+
+func TestResponsesToChatFunctionCalls(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string // upstream Chat Completions body
+	}{
+		{
+			name: "string input and instructions",
+			body: `{"model":"glm-4","instructions":"be brief","input":"hi","store":false}`,
+			want: `{"model":"glm-4","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi"}]}`,
+		},
+		{
+			name: "function call round trip",
+			body: `{"model":"glm-4","store":false,"tools":[
+					{"type":"function","name":"get_weather","description":"Weather","parameters":{"type":"object"},"strict":true},
+					{"type":"web_search"}],
+				"input":[
+					{"role":"developer","content":"use tools"},
+					{"role":"user","content":[{"type":"input_text","text":"weather in Paris and Tokyo?"}]},
+					{"type":"reasoning","id":"rs_1","summary":[]},
+					{"role":"assistant","content":[{"type":"output_text","text":"checking"}]},
+					{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"},
+					{"type":"function_call","id":"fc_2","name":"get_weather","arguments":""},
+					{"type":"function_call_output","call_id":"call_1","output":"sunny"},
+					{"type":"function_call_output","call_id":"fc_2","output":[{"type":"input_text","text":"rain"},{"type":"input_text","text":"cold"}]}]}`,
+			want: `{"model":"glm-4",
+				"tools":[{"type":"function","function":{"name":"get_weather","description":"Weather","parameters":{"type":"object"},"strict":true}}],
+				"messages":[
+					{"role":"system","content":"use tools"},
+					{"role":"user","content":"weather in Paris and Tokyo?"},
+					{"role":"assistant","content":"checking","tool_calls":[
+						{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}},
+						{"id":"fc_2","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},
+					{"role":"tool","content":"sunny","tool_call_id":"call_1"},
+					{"role":"tool","content":"rain\ncold","tool_call_id":"fc_2"}]}`,
+		},
+		{
+			name: "function call without a preceding assistant message",
+			body: `{"model":"m","store":false,"input":[
+					{"role":"user","content":"x"},
+					{"type":"function_call","call_id":"c1","name":"f","arguments":"{}"},
+					{"type":"function_call_output","call_id":"c1","output":{"ok":true}}]}`,
+			want: `{"model":"m","messages":[{"role":"user","content":"x"},
+					{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"f","arguments":"{}"}}]},
+					{"role":"tool","content":"{\"ok\":true}","tool_call_id":"c1"}]}`,
+		},
+		{
+			name: "chat completions tools are kept",
+			body: `{"model":"m","store":false,"input":"x","tools":[{"type":"function","function":{"name":"f"}}]}`,
+			want: `{"model":"m","tools":[{"type":"function","function":{"name":"f"}}],"messages":[{"role":"user","content":"x"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		got := responsesRequest(t, responsesToChatRoute, tt.body)
+		jsonEqual(t, tt.name, got.body, tt.want)
+		if got.subPath != "/v1/chat/completions" {
+			t.Errorf("%s: sub path = %q", tt.name, got.subPath)
+		}
+	}
+}
+
+func TestResponsesToChatContentParts(t *testing.T) {
+	input := `[{"role":"user","content":[
+		{"type":"input_text","text":"compare"},
+		{"type":"input_image","image_url":"https://example.com/a.png","detail":"low"},
+		{"type":"input_image","image_url":"data:image/png;base64,AAAA"},
+		{"type":"input_image","file_id":"file-img"},
+		{"type":"input_file","file_id":"file-1","filename":"a.pdf"},
+		{"type":"input_file","file_data":"data:application/pdf;base64,BBBB","filename":"b.pdf"},
+		{"type":"input_file","file_url":"https://example.com/c.pdf"}]},
+		{"role":"assistant","content":[{"type":"output_text","text":"ok"}]},
+		{"role":"user","content":[]}]`
+
+	got := responsesRequest(t, responsesToChatRoute, `{"model":"m","store":false,"input":`+input+`}`)
+	jsonEqual(t, "multimodal", got.body, `{"model":"m","messages":[
+		{"role":"user","content":[
+			{"type":"text","text":"compare"},
+			{"type":"image_url","image_url":{"url":"https://example.com/a.png","detail":"low"}},
+			{"type":"image_url","image_url":{"url":"data:image/png;base64,AAAA"}},
+			{"type":"file","file":{"file_id":"file-1","filename":"a.pdf"}},
+			{"type":"file","file":{"file_data":"data:application/pdf;base64,BBBB","filename":"b.pdf"}}]},
+		{"role":"assistant","content":"ok"}]}`)
+
+	textOnly := *responsesToChatRoute
+	textOnly.TextOnly = true
+	got = responsesRequest(t, &textOnly, `{"model":"m","store":false,"input":`+input+`}`)
+	jsonEqual(t, "text_only", got.body, `{"model":"m","messages":[
+		{"role":"user","content":"compare\n[image]\n[image]\n[image]\n[file: a.pdf]\n[file: b.pdf]\n[file: https://example.com/c.pdf]"},
+		{"role":"assistant","content":"ok"}]}`)
+
+	// text parts alone are joined into a string
+	got = responsesRequest(t, responsesToChatRoute, `{"model":"m","store":false,"input":[{"role":"user","content":[
+		{"type":"input_text","text":"a"},{"type":"input_text","text":"b"}]}]}`)
+	jsonEqual(t, "text parts", got.body, `{"model":"m","messages":[{"role":"user","content":"a\nb"}]}`)
+}
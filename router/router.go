@@ -4,6 +4,7 @@ package router
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/poixeai/proxify/controller"
+	"github.com/poixeai/proxify/infra/config"
 	"github.com/poixeai/proxify/middleware"
 )
 
@@ -14,14 +15,49 @@ func SetRoutes(r *gin.Engine) {
 	r.Use(middleware.GinRequestLogger())
 	r.Use(middleware.Extractor())
 	r.Use(middleware.Auth())
-	r.Use(middleware.ResponsesToChat())  // Convert Responses API to Chat Completions (request)
+	r.Use(middleware.ModelRouter())     // Resolve /v1 requests to a route by model
+	r.Use(middleware.ModelPolicy())     // Reject models not allowed for the route or API key
+	r.Use(middleware.ResponsesToChat()) // Convert Responses API to Chat Completions (request)
+	r.Use(middleware.AnthropicToChat()) // Convert Anthropic Messages to Chat Completions (request and response)
+	r.Use(middleware.ChatToAnthropic()) // Convert Chat Completions to Anthropic Messages (request and response)
+	r.Use(middleware.ChatToGemini())    // Convert Chat Completions to Gemini generateContent (request and response)
 	r.Use(middleware.ModelRewrite())
+	r.Use(middleware.BodyRules())         // Apply route body_rules to the upstream body
 	r.Use(middleware.ResponseTransform()) // Convert Chat Completions to Responses API (response)
 
+	// ==== unified endpoint ====
+	r.GET("/v1/models", controller.ModelsHandler)
+
 	// ==== routes.json ====
-	apiGroup := r.Group("/api")
+	viewer := middleware.AdminAuth(config.RoleViewer)
+	operator := middleware.AdminAuth(config.RoleOperator)
+	admin := middleware.AdminAuth(config.RoleAdmin)
+
+	apiGroup := r.Group("/api", middleware.AdminAudit())
 	{
-		apiGroup.GET("/", controller.ShowPathHandler)
-		apiGroup.GET("/routes", controller.RoutesHandler)
+		apiGroup.GET("/", viewer, controller.ShowPathHandler)
+		apiGroup.GET("/routes", viewer, controller.RoutesHandler)
+
+		// ==== admin ====
+		adminGroup := apiGroup.Group("/admin")
+		{
+			adminGroup.GET("/routes", viewer, controller.AdminListRoutesHandler)
+			adminGroup.POST("/routes", admin, controller.AdminCreateRouteHandler)
+			adminGroup.GET("/routes/:name", viewer, controller.AdminGetRouteHandler)
+			adminGroup.PUT("/routes/:name", admin, controller.AdminUpdateRouteHandler)
+			adminGroup.DELETE("/routes/:name", admin, controller.AdminDeleteRouteHandler)
+			adminGroup.POST("/routes/:name/enable", operator, controller.AdminEnableRouteHandler)
+			adminGroup.POST("/routes/:name/disable", operator, controller.AdminDisableRouteHandler)
+
+			adminGroup.GET("/history", viewer, controller.AdminListHistoryHandler)
+			adminGroup.GET("/history/diff", viewer, controller.AdminDiffHistoryHandler)
+			adminGroup.GET("/history/:id", viewer, controller.AdminGetRevisionHandler)
+			adminGroup.POST("/history/:id/rollback", admin, controller.AdminRollbackHandler)
+
+			adminGroup.GET("/keys", viewer, controller.AdminListKeysHandler)
+			adminGroup.POST("/keys", operator, controller.AdminCreateKeyHandler)
+			adminGroup.DELETE("/keys/:id", operator, controller.AdminRevokeKeyHandler)
+			adminGroup.POST("/keys/:id/rotate", operator, controller.AdminRotateKeyHandler)
+		}
 	}
 }
@@ -43,7 +43,7 @@ func MountFrontend(r *gin.Engine) {
 		}
 
 		topRoute := c.GetString(ctx.TopRoute)
-		if config.ReservedTopRoutes[topRoute] {
+		if config.ReservedTopRoutes[topRoute] || topRoute == config.UnifiedTopRoute {
 			logger.Warnf("404 Not Found: %s", topRoute)
 			response.RespondSystemRouteNotFoundError(c)
 			return
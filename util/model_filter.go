@@ -0,0 +1,37 @@
+package util
+
+import "path"
+
+// ModelAllowed reports whether a model passes allow / deny glob lists.
+// Deny wins over allow, and an empty allow list allows everything.
+// Several names of one model (e.g. an alias and its model_map target) are denied
+// if any of them is denied, and allowed if any of them is allowed.
+func ModelAllowed(allow, deny []string, names ...string) bool {
+	for _, name := range names {
+		if MatchAnyModel(deny, name) {
+			return false
+		}
+	}
+	if len(allow) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if MatchAnyModel(allow, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAnyModel reports whether model equals or matches any of the glob patterns
+func MatchAnyModel(patterns []string, model string) bool {
+	for _, p := range patterns {
+		if p == model {
+			return true
+		}
+		if ok, _ := path.Match(p, model); ok {
+			return true
+		}
+	}
+	return false
+}
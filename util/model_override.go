@@ -1,6 +1,11 @@
 package util
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+)
 
 // RewriteChatCompletionModel rewrites the `model` field in request body
 // if modelMap contains a mapping for the original model.
@@ -14,6 +19,19 @@ func RewriteChatCompletionModel(
 		return requestBody, false, nil
 	}
 
+	return RewriteChatCompletionModelFunc(requestBody, func(model string) (string, bool) {
+		newModel, ok := modelMap[model]
+		return newModel, ok
+	})
+}
+
+// RewriteChatCompletionModelFunc is like RewriteChatCompletionModel but asks
+// mapModel for the new model name, e.g. to support pattern mappings.
+func RewriteChatCompletionModelFunc(
+	requestBody []byte,
+	mapModel func(model string) (string, bool),
+) ([]byte, bool, error) {
+
 	var body map[string]interface{}
 	if err := json.Unmarshal(requestBody, &body); err != nil {
 		return requestBody, false, err
@@ -29,7 +47,7 @@ func RewriteChatCompletionModel(
 		return requestBody, false, nil
 	}
 
-	newModel, ok := modelMap[model]
+	newModel, ok := mapModel(model)
 	if !ok || newModel == "" || newModel == model {
 		return requestBody, false, nil
 	}
@@ -44,3 +62,58 @@ func RewriteChatCompletionModel(
 
 	return newBody, true, nil
 }
+
+// models embedded in request paths:
+// Gemini "/v1beta/models/{model}:generateContent", Azure "/openai/deployments/{name}/chat/completions"
+var pathModelRes = []*regexp.Regexp{
+	regexp.MustCompile(`(/models/)([^/:?]+)`),
+	regexp.MustCompile(`(/deployments/)([^/?]+)`),
+}
+
+// PathModel returns the model embedded in a request path, or "" if there is none
+func PathModel(path string) string {
+	model, _, _ := findPathModel(path)
+	return model
+}
+
+// RewritePathModel rewrites the model embedded in a request path (with optional query).
+// It returns the new path and the original model when a rewrite happened.
+func RewritePathModel(
+	path string,
+	mapModel func(model string) (string, bool),
+) (string, string, bool) {
+
+	model, start, end := findPathModel(path)
+	if model == "" {
+		return path, "", false
+	}
+
+	newModel, ok := mapModel(model)
+	if !ok || newModel == "" || newModel == model {
+		return path, "", false
+	}
+
+	return path[:start] + url.PathEscape(newModel) + path[end:], model, true
+}
+
+// findPathModel returns the unescaped model and its position in path.
+// The query is not searched, "?next=/models/x" names no model.
+func findPathModel(path string) (string, int, int) {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+
+	for _, re := range pathModelRes {
+		m := re.FindStringSubmatchIndex(path)
+		if m == nil {
+			continue
+		}
+
+		model := path[m[4]:m[5]]
+		if unescaped, err := url.PathUnescape(model); err == nil {
+			model = unescaped
+		}
+		return model, m[4], m[5]
+	}
+	return "", 0, 0
+}
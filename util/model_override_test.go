@@ -0,0 +1,132 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func mapTo(models map[string]string) func(string) (string, bool) {
+	return func(model string) (string, bool) {
+		newModel, ok := models[model]
+		return newModel, ok
+	}
+}
+
+func TestRewritePathModel(t *testing.T) {
+	mapModel := mapTo(map[string]string{
+		"gemini-pro": "gemini-2.5-pro",
+		"gpt-4o":     "prod-gpt4o",
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		want     string
+		original string
+		ok       bool
+	}{
+		{
+			name:     "gemini generateContent",
+			path:     "/v1beta/models/gemini-pro:generateContent",
+			want:     "/v1beta/models/gemini-2.5-pro:generateContent",
+			original: "gemini-pro",
+			ok:       true,
+		},
+		{
+			name:     "gemini stream keeps query",
+			path:     "/v1beta/models/gemini-pro:streamGenerateContent?alt=sse&key=abc",
+			want:     "/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse&key=abc",
+			original: "gemini-pro",
+			ok:       true,
+		},
+		{
+			name:     "azure deployment",
+			path:     "/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21",
+			want:     "/openai/deployments/prod-gpt4o/chat/completions?api-version=2024-10-21",
+			original: "gpt-4o",
+			ok:       true,
+		},
+		{
+			name:     "query is not rewritten",
+			path:     "/v1beta/models/gemini-pro:generateContent?next=/models/gemini-pro",
+			want:     "/v1beta/models/gemini-2.5-pro:generateContent?next=/models/gemini-pro",
+			original: "gemini-pro",
+			ok:       true,
+		},
+		{
+			name: "model only in query",
+			path: "/v1/chat/completions?next=/models/gemini-pro",
+			want: "/v1/chat/completions?next=/models/gemini-pro",
+		},
+		{
+			name: "deployment only in query",
+			path: "/v1/chat/completions?x=/deployments/gpt-4o/",
+			want: "/v1/chat/completions?x=/deployments/gpt-4o/",
+		},
+		{
+			name: "unmapped model",
+			path: "/v1beta/models/gemini-flash:generateContent",
+			want: "/v1beta/models/gemini-flash:generateContent",
+		},
+		{
+			name: "no model in path",
+			path: "/v1/chat/completions",
+			want: "/v1/chat/completions",
+		},
+		{
+			name: "model list",
+			path: "/v1beta/models",
+			want: "/v1beta/models",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, original, ok := RewritePathModel(tt.path, mapModel)
+			if got != tt.want || original != tt.original || ok != tt.ok {
+				t.Errorf("RewritePathModel(%q) = %q, %q, %v, want %q, %q, %v",
+					tt.path, got, original, ok, tt.want, tt.original, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRewritePathModelEscapes(t *testing.T) {
+	got, _, ok := RewritePathModel("/openai/deployments/gpt-4o/embeddings", mapTo(map[string]string{"gpt-4o": "my deployment"}))
+	if !ok || got != "/openai/deployments/my%20deployment/embeddings" {
+		t.Errorf("got %q, %v", got, ok)
+	}
+}
+
+func TestPathModel(t *testing.T) {
+	tests := map[string]string{
+		"/v1beta/models/gemini-2.5-flash:generateContent":                         "gemini-2.5-flash",
+		"/v1beta/models/gemini-2.5-flash:streamGenerateContent?alt=sse":           "gemini-2.5-flash",
+		"/openai/deployments/gpt-4o-mini/chat/completions?api-version=2024-10-21": "gpt-4o-mini",
+		"/openai/deployments/my%20deployment/chat/completions":                    "my deployment",
+		"/v1/chat/completions": "",
+	}
+
+	for path, want := range tests {
+		if got := PathModel(path); got != want {
+			t.Errorf("PathModel(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestRewriteChatCompletionModel(t *testing.T) {
+	body := []byte(`{"model":"gpt-4o","max_tokens":4096}`)
+
+	got, ok, err := RewriteChatCompletionModel(body, map[string]string{"gpt-4o": "prod-gpt4o"})
+	if err != nil || !ok {
+		t.Fatalf("ok = %v, err = %v", ok, err)
+	}
+	if !strings.Contains(string(got), `"model":"prod-gpt4o"`) || !strings.Contains(string(got), `"max_tokens":4096`) {
+		t.Errorf("body = %s", got)
+	}
+
+	got, ok, _ = RewriteChatCompletionModel(body, map[string]string{"gpt-4": "x"})
+	if ok || string(got) != string(body) {
+		t.Errorf("unmapped model changed the body: %s", got)
+	}
+}
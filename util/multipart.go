@@ -0,0 +1,111 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+)
+
+// maximum size of a "model" form field, larger values are passed through untouched
+const maxModelFieldSize = 1 << 10
+
+// maximum number of bytes MultipartModel buffers to find the "model" field,
+// a model sent after a large file part is not found
+const maxModelScanSize = 64 << 10
+
+// RewriteMultipartModel rewrites the "model" field of a multipart/form-data body
+// while it is streamed, so large uploads such as audio files are never buffered.
+// The output keeps the original boundary, so the Content-Type header stays valid.
+func RewriteMultipartModel(
+	body io.Reader,
+	boundary string,
+	mapModel func(model string) (string, bool),
+) io.ReadCloser {
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		pw.CloseWithError(copyMultipart(pw, body, boundary, mapModel))
+	}()
+
+	return pr
+}
+
+func copyMultipart(
+	dst io.Writer,
+	src io.Reader,
+	boundary string,
+	mapModel func(model string) (string, bool),
+) error {
+
+	mr := multipart.NewReader(src, boundary)
+	mw := multipart.NewWriter(dst)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+
+	for {
+		// raw parts keep their transfer encoding untouched
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			return mw.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		w, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+
+		if part.FormName() == "model" && part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxModelFieldSize+1))
+			if err != nil {
+				return err
+			}
+			if len(value) <= maxModelFieldSize {
+				model := string(bytes.TrimSpace(value))
+				if newModel, ok := mapModel(model); ok && newModel != "" {
+					value = []byte(newModel)
+				}
+			}
+			if _, err := w.Write(value); err != nil {
+				return err
+			}
+		}
+
+		if _, err := io.Copy(w, part); err != nil {
+			return err
+		}
+	}
+}
+
+// MultipartModel returns the "model" field of a multipart/form-data body, or "" if it
+// is missing, the body is malformed or the field is not within the first maxModelScanSize
+// bytes. Parts are only read until the field is found, the returned reader yields the
+// complete original body.
+func MultipartModel(body io.Reader, boundary string) (string, io.Reader) {
+	var read bytes.Buffer
+	mr := multipart.NewReader(io.TeeReader(io.LimitReader(body, maxModelScanSize), &read), boundary)
+
+	model := ""
+	for {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			break
+		}
+		if part.FormName() == "model" && part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxModelFieldSize+1))
+			if err == nil && len(value) <= maxModelFieldSize {
+				model = string(bytes.TrimSpace(value))
+			}
+			break
+		}
+		if _, err := io.Copy(io.Discard, part); err != nil {
+			break
+		}
+	}
+
+	return model, io.MultiReader(&read, body)
+}
@@ -0,0 +1,161 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"testing"
+)
+
+// audioBytes returns binary content with CR/LF runs and bytes that look like a boundary
+func audioBytes() []byte {
+	data := make([]byte, 0, 70000)
+	for i := 0; i < 65536; i++ {
+		data = append(data, byte(i*31))
+	}
+	return append(data, []byte("\r\n--not-the-boundary\r\n\x00\xff")...)
+}
+
+// transcriptionForm builds an audio transcription upload like the OpenAI SDKs send it
+func transcriptionForm(t *testing.T, model string, audio []byte) ([]byte, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", `form-data; name="file"; filename="speech.mp3"`)
+	h.Set("Content-Type", "audio/mpeg")
+	fw, err := mw.CreatePart(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(audio)
+
+	mw.WriteField("model", model)
+	mw.WriteField("response_format", "json")
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes(), mw.Boundary()
+}
+
+type formPart struct {
+	header textproto.MIMEHeader
+	data   []byte
+}
+
+func readForm(t *testing.T, body []byte, boundary string) map[string]formPart {
+	t.Helper()
+
+	parts := make(map[string]formPart)
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("body does not parse with boundary %q: %v", boundary, err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[part.FormName()] = formPart{header: part.Header, data: data}
+	}
+}
+
+func TestRewriteMultipartModel(t *testing.T) {
+	audio := audioBytes()
+	body, boundary := transcriptionForm(t, "whisper", audio)
+
+	out, err := io.ReadAll(RewriteMultipartModel(bytes.NewReader(body), boundary,
+		mapTo(map[string]string{"whisper": "whisper-large-v3"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the client's Content-Type header is forwarded, so the boundary must not change
+	if !bytes.HasPrefix(out, []byte("--"+boundary+"\r\n")) {
+		t.Fatalf("body does not start with the original boundary: %q", out[:min(len(out), 80)])
+	}
+
+	parts := readForm(t, out, boundary)
+	if got := string(parts["model"].data); got != "whisper-large-v3" {
+		t.Errorf("model = %q, want whisper-large-v3", got)
+	}
+	if got := string(parts["response_format"].data); got != "json" {
+		t.Errorf("response_format = %q", got)
+	}
+
+	file := parts["file"]
+	if !bytes.Equal(file.data, audio) {
+		t.Errorf("file part changed: got %d bytes, want %d", len(file.data), len(audio))
+	}
+	if file.header.Get("Content-Type") != "audio/mpeg" ||
+		file.header.Get("Content-Disposition") != `form-data; name="file"; filename="speech.mp3"` {
+		t.Errorf("file part headers changed: %v", file.header)
+	}
+}
+
+func TestRewriteMultipartModelUnmapped(t *testing.T) {
+	body, boundary := transcriptionForm(t, "whisper-1", audioBytes())
+
+	out, err := io.ReadAll(RewriteMultipartModel(bytes.NewReader(body), boundary,
+		mapTo(map[string]string{"whisper": "whisper-large-v3"})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, body) {
+		t.Errorf("body changed although the model is not mapped")
+	}
+}
+
+func TestRewriteMultipartModelMalformed(t *testing.T) {
+	_, err := io.ReadAll(RewriteMultipartModel(bytes.NewReader([]byte("not a form")), "xyz",
+		mapTo(nil)))
+	if err == nil {
+		t.Error("expected an error for a malformed body")
+	}
+}
+
+func TestMultipartModel(t *testing.T) {
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	mw.WriteField("model", "whisper-1")
+	fw, _ := mw.CreateFormFile("file", "speech.mp3")
+	fw.Write(audioBytes())
+	mw.Close()
+	body := form.Bytes()
+
+	model, replay := MultipartModel(bytes.NewReader(body), mw.Boundary())
+	if model != "whisper-1" {
+		t.Errorf("model = %q, want whisper-1", model)
+	}
+	got, err := io.ReadAll(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("replayed body differs from the original")
+	}
+}
+
+func TestMultipartModelAfterLargeFile(t *testing.T) {
+	// the model comes after a file larger than the scan limit, so it is not searched for
+	body, boundary := transcriptionForm(t, "whisper-1", bytes.Repeat(audioBytes(), 2))
+
+	model, replay := MultipartModel(bytes.NewReader(body), boundary)
+	if model != "" {
+		t.Errorf("model = %q, want none", model)
+	}
+	got, err := io.ReadAll(replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, body) {
+		t.Errorf("replayed body differs from the original")
+	}
+}
@@ -1,6 +1,10 @@
 package util
 
-import "strings"
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
 
 // ExtractRoute splits "/openai/v1/chat" → "openai", "v1/chat"
 func ExtractRoute(path string) (string, string) {
@@ -19,3 +23,20 @@ func JoinURL(base, sub string) string {
 	sub = strings.TrimLeft(sub, "/")
 	return base + "/" + sub
 }
+
+// RedactURLError renders a request error without the request URL, whose query or host
+// may hold interpolated secrets. The host is also removed from causes like DNS errors.
+func RedactURLError(err error, rawURL string) string {
+	msg := err.Error()
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		msg = ue.Op + ": " + ue.Err.Error()
+	}
+
+	msg = strings.ReplaceAll(msg, rawURL, "<upstream>")
+	if u, perr := url.Parse(rawURL); perr == nil && u.Host != "" {
+		msg = strings.ReplaceAll(msg, u.Host, "<upstream>")
+		msg = strings.ReplaceAll(msg, u.Hostname(), "<upstream>")
+	}
+	return msg
+}
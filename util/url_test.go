@@ -0,0 +1,29 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedactURLError(t *testing.T) {
+	const target = "https://tenant-secret.invalid/v1/chat/completions?key=sk-secret"
+
+	req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, target, nil)
+	_, err := (&http.Client{}).Do(req)
+	if err == nil {
+		t.Fatal("expected a request error")
+	}
+
+	for _, e := range []error{err, errors.New(`parse "` + target + `": invalid`)} {
+		got := RedactURLError(e, target)
+		if strings.Contains(got, "secret") {
+			t.Errorf("secret leaked: %s", got)
+		}
+		if got == "" {
+			t.Error("empty message")
+		}
+	}
+}